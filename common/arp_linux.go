@@ -0,0 +1,61 @@
+//go:build linux
+
+package common
+
+import (
+	"bufio"
+	"dddd/common/ratelimit"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arpProbe 向直连主机发送UDP报文触发内核ARP解析，等待后从邻居表读取已解析的主机
+// 不需要原始套接字权限
+func arpProbe(hosts []string, wait time.Duration) ([]string, error) {
+	for _, host := range hosts {
+		address := net.JoinHostPort(host, "9")
+		release := ratelimit.Acquire(address)
+		conn, err := net.Dial("udp4", address)
+		if err != nil {
+			release()
+			continue
+		}
+		conn = ratelimit.WrapConn(conn, release)
+		_, _ = conn.Write([]byte{})
+		conn.Close()
+	}
+	time.Sleep(wait)
+
+	file, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	targets := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		targets[canonicalIP(host)] = struct{}{}
+	}
+	var results []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		if _, ok := targets[fields[0]]; !ok {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[2], 0, 32)
+		// 0x2为已完成解析
+		if err != nil || flags&0x2 == 0 || fields[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		results = append(results, fields[0])
+	}
+	return results, scanner.Err()
+}
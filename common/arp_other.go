@@ -0,0 +1,13 @@
+//go:build !linux
+
+package common
+
+import (
+	"errors"
+	"time"
+)
+
+// arpProbe 非Linux系统不支持读取邻居表
+func arpProbe(hosts []string, wait time.Duration) ([]string, error) {
+	return nil, errors.New("ARP探测仅支持Linux")
+}
@@ -1,12 +1,17 @@
 package callnuclei
 
 import (
+	"dddd/common/report"
+	ddddconfig "dddd/config"
+	"dddd/structs"
 	"fmt"
 	"github.com/projectdiscovery/nuclei/v3/pkg/output"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"sync"
 	"time"
 
 	"github.com/projectdiscovery/goflags"
@@ -15,6 +20,7 @@ import (
 	"github.com/projectdiscovery/nuclei/v3/pkg/catalog/config"
 	"github.com/projectdiscovery/nuclei/v3/pkg/exportrunner"
 	"github.com/projectdiscovery/nuclei/v3/pkg/operators/common/dsl"
+	"github.com/projectdiscovery/nuclei/v3/pkg/progress"
 	"github.com/projectdiscovery/nuclei/v3/pkg/types"
 	"github.com/projectdiscovery/nuclei/v3/pkg/utils/monitor"
 	errorutil "github.com/projectdiscovery/utils/errors"
@@ -27,15 +33,26 @@ var (
 	options    = &types.Options{}
 )
 
-func CallNuclei(TargetAndPocsName map[string][]string,
+// nucleiLock Nuclei的参数、结果回调与进度均为全局变量，同一进程内的多个扫描依次调用Nuclei
+var nucleiLock sync.Mutex
+
+func CallNuclei(scan *structs.Scan,
+	TargetAndPocsName map[string][]string,
 	proxy string,
-	callBack func(result output.ResultEvent),
-	nameForSearch string) []output.ResultEvent {
+	nameForSearch string) ([]output.ResultEvent, error) {
+	nucleiLock.Lock()
+	defer nucleiLock.Unlock()
 
 	// 设置结果回调
-	output.AddResultCallback = callBack
+	output.Results = nil
+	output.AddResultCallback = func(result output.ResultEvent) {
+		report.AddResultByResultEvent(scan, result)
+	}
+	defer func() {
+		output.AddResultCallback = nil
+	}()
 	if err := exportrunner.ExportRunnerConfigureOptions(); err != nil {
-		gologger.Fatal().Msgf("Could not initialize options: %s\n", err)
+		return nil, fmt.Errorf("Could not initialize options: %s", err)
 	}
 
 	readConfig(TargetAndPocsName, proxy, nameForSearch)
@@ -44,14 +61,14 @@ func CallNuclei(TargetAndPocsName map[string][]string,
 	if options.ListDslSignatures {
 		gologger.Info().Msgf("The available custom DSL functions are:")
 		fmt.Println(dsl.GetPrintableDslFunctionSignatures(options.NoColor))
-		return []output.ResultEvent{}
+		return []output.ResultEvent{}, nil
 	}
 
 	// Profiling related code
 	if memProfile != "" {
 		f, err := os.Create(memProfile)
 		if err != nil {
-			gologger.Fatal().Msgf("profile: could not create memory profile %q: %v", memProfile, err)
+			return nil, fmt.Errorf("profile: could not create memory profile %q: %v", memProfile, err)
 		}
 		old := runtime.MemProfileRate
 		runtime.MemProfileRate = 4096
@@ -74,10 +91,10 @@ func CallNuclei(TargetAndPocsName map[string][]string,
 
 	nucleiRunner, err := exportrunner.ExportRunnerNew(options)
 	if err != nil {
-		gologger.Fatal().Msgf("Could not create runner: %s\n", err)
+		return nil, fmt.Errorf("Could not create runner: %s", err)
 	}
 	if nucleiRunner == nil {
-		return []output.ResultEvent{}
+		return []output.ResultEvent{}, nil
 	}
 
 	// Setup graceful exits
@@ -93,25 +110,33 @@ func CallNuclei(TargetAndPocsName map[string][]string,
 		}
 	}()
 
+	stage := scan.Progress.Start("Nuclei", 0)
+	progress.StatsHook = func(requests int64, total int64) {
+		stage.AddTotal(total)
+		stage.Add(requests)
+	}
+	defer func() {
+		progress.StatsHook = nil
+		stage.Finish()
+	}()
+
 	if err := nucleiRunner.RunEnumeration(TargetAndPocsName); err != nil {
+		nucleiRunner.Close()
 		if options.Validate {
-			gologger.Fatal().Msgf("Could not validate templates: %s\n", err)
-		} else {
-			gologger.Fatal().Msgf("Could not run nuclei: %s\n", err)
+			return nil, fmt.Errorf("Could not validate templates: %s", err)
 		}
+		return nil, fmt.Errorf("Could not run nuclei: %s", err)
 	}
 	nucleiRunner.Close()
 	// on successful execution remove the resume file in case it exists
 	if fileutil.FileExists(resumeFileName) {
 		os.Remove(resumeFileName)
 	}
-	return output.Results
+	return output.Results, nil
 }
 
 func readConfig(TargetAndPocsName map[string][]string, proxy string, nameForSearch string) {
 
-	pwd, _ := os.Getwd()
-
 	// target URLs/hosts to scan
 	// 扫描目标
 	var targets []string
@@ -152,8 +177,14 @@ func readConfig(TargetAndPocsName map[string][]string, proxy string, nameForSear
 
 	// list of template or template directory to run (comma-separated, file)
 	// 要运行的模板或模板目录列表(逗号分隔，文件)   -t 指定的模板目录
-	// 不嵌入可执行文件是为了方便增删poc。内网版本嵌入
-	options.Templates = []string{pwd + "/config/pocs/"}
+	// 默认不嵌入可执行文件是为了方便增删poc。使用 -tags embed 构建时从内置配置中读取
+	if ddddconfig.Embedded() {
+		exportrunner.ExportRunnerSetTemplatesFS(ddddconfig.FS())
+		options.Templates = []string{"pocs"}
+	} else {
+		exportrunner.ExportRunnerSetTemplatesFS(nil)
+		options.Templates = []string{filepath.Join(ddddconfig.Dir(), "pocs")}
+	}
 
 	// list of template urls to run (comma-separated, file)
 	// 要运行的模板url列表(逗号分隔，文件)
@@ -508,7 +539,7 @@ func readConfig(TargetAndPocsName map[string][]string, proxy string, nameForSear
 	// 更新Nuclei模板到最新版
 	options.UpdateTemplates = false
 	// 覆盖安装模板
-	options.NewTemplatesDirectory = pwd + "/config/pocs/nuclei-templates/"
+	options.NewTemplatesDirectory = filepath.Join(ddddconfig.Dir(), "pocs", "nuclei-templates")
 
 	// 显示正在扫描的统计信息
 	options.EnableProgressBar = true
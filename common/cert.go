@@ -0,0 +1,307 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"dddd/common/report"
+	"dddd/structs"
+	"dddd/utils"
+	"encoding/binary"
+	"errors"
+	"github.com/logrusorgru/aurora"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// certTimeout 获取证书的连接与握手超时
+const certTimeout = 10 * time.Second
+
+// startTLS 需要先发送STARTTLS等命令再进行TLS握手的服务，返回用于握手的连接
+var startTLS = map[string]func(conn net.Conn) (net.Conn, error){
+	"smtp":           smtpStartTLS,
+	"submission":     smtpStartTLS,
+	"imap":           imapStartTLS,
+	"pop3":           pop3StartTLS,
+	"ftp":            ftpStartTLS,
+	"ldap":           ldapStartTLS,
+	"postgresql":     postgresStartTLS,
+	"ms-wbt-server":  rdpStartTLS,
+	"ms-sql-s":       mssqlStartTLS,
+	"microsoft-rdp":  rdpStartTLS,
+	"ms-term-server": rdpStartTLS,
+}
+
+// collectCertificate 获取协议识别出的TLS服务的证书，同一IP:Port只获取一次
+// Web服务的证书由Web探测获取
+func collectCertificate(scan *structs.Scan, ip string, port int, service string, isTLS bool) {
+	if service == "https" {
+		return
+	}
+	handshake, ok := startTLS[service]
+	if isTLS || service == "ssl" || strings.HasPrefix(service, "ssl/") {
+		handshake, ok = nil, true
+	}
+	if !ok {
+		return
+	}
+	hostPort := net.JoinHostPort(ip, strconv.Itoa(port))
+	if scan.GetCertificate(hostPort).SHA256 != "" {
+		return
+	}
+	info, err := grabCertificate(hostPort, handshake)
+	if err != nil {
+		gologger.Debug().Msgf("%s://%s 获取证书失败: %v", service, hostPort, err)
+		return
+	}
+	if !scan.SetCertificate(hostPort, info) {
+		return
+	}
+	target := service + "://" + hostPort
+	gologger.Silent().Msgf("[Cert] %s [%s]", target, aurora.Cyan(info.Summary()))
+	report.AddRecord(scan, report.RecordCert, structs.CertRecord{Target: target, IP: ip, Port: port, CertInfo: info})
+}
+
+// grabCertificate 连接hostPort并完成TLS握手，handshake不为nil时先协商升级为TLS
+func grabCertificate(hostPort string, handshake func(conn net.Conn) (net.Conn, error)) (structs.CertInfo, error) {
+	conn, err := WrapperTcpWithTimeout("tcp", hostPort, certTimeout)
+	if err != nil {
+		return structs.CertInfo{}, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(certTimeout))
+
+	tlsConn := conn
+	if handshake != nil {
+		if tlsConn, err = handshake(conn); err != nil {
+			return structs.CertInfo{}, err
+		}
+	}
+	// 只需要证书，收到证书后即可结束，服务端要求客户端证书等导致握手失败时同样保留
+	var leaf *x509.Certificate
+	client := tls.Client(tlsConn, &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		CipherSuites:       allCipherSuites(),
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) > 0 {
+				leaf, _ = x509.ParseCertificate(rawCerts[0])
+			}
+			return nil
+		},
+	})
+	err = client.Handshake()
+	if leaf == nil {
+		if err == nil {
+			err = errors.New("服务端未发送证书")
+		}
+		return structs.CertInfo{}, err
+	}
+	return utils.NewCertInfo(leaf, client.ConnectionState().Version), nil
+}
+
+// allCipherSuites 包含不安全的加密套件，兼容仍在使用RSA密钥交换等的旧服务
+func allCipherSuites() []uint16 {
+	var ids []uint16
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids = append(ids, suite.ID)
+	}
+	return ids
+}
+
+// readReply 读取以code开头的应答，多行应答读取到最后一行
+func readReply(reader *bufio.Reader, code string) error {
+	multiline := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, code) {
+			// FTP多行应答的中间行可以不以应答码开头
+			if multiline {
+				continue
+			}
+			return errors.New("应答不正确: " + strings.TrimSpace(line))
+		}
+		// SMTP与FTP的多行应答以 "250-" 形式续行
+		if len(line) <= len(code) || line[len(code)] != '-' {
+			return nil
+		}
+		multiline = true
+	}
+}
+
+// commandStartTLS 读取欢迎信息后依次发送命令，每条命令的应答需以对应前缀开头
+func commandStartTLS(conn net.Conn, greeting string, commands ...string) (net.Conn, error) {
+	reader := bufio.NewReader(conn)
+	if err := readReply(reader, greeting); err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(commands); i += 2 {
+		if _, err := conn.Write([]byte(commands[i] + "\r\n")); err != nil {
+			return nil, err
+		}
+		if err := readReply(reader, commands[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func smtpStartTLS(conn net.Conn) (net.Conn, error) {
+	return commandStartTLS(conn, "220", "EHLO dddd", "250", "STARTTLS", "220")
+}
+
+func imapStartTLS(conn net.Conn) (net.Conn, error) {
+	return commandStartTLS(conn, "* OK", "a001 STARTTLS", "a001 OK")
+}
+
+func pop3StartTLS(conn net.Conn) (net.Conn, error) {
+	return commandStartTLS(conn, "+OK", "STLS", "+OK")
+}
+
+func ftpStartTLS(conn net.Conn) (net.Conn, error) {
+	return commandStartTLS(conn, "220", "AUTH TLS", "234")
+}
+
+// ldapStartTLS 发送StartTLS扩展操作(1.3.6.1.4.1.1466.20037)
+func ldapStartTLS(conn net.Conn) (net.Conn, error) {
+	oid := "1.3.6.1.4.1.1466.20037"
+	request := append([]byte{0x30, byte(len(oid) + 7), 0x02, 0x01, 0x01, 0x77, byte(len(oid) + 2), 0x80, byte(len(oid))}, oid...)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 512)
+	n, err := conn.Read(reply)
+	if err != nil {
+		return nil, err
+	}
+	// ExtendedResponse中resultCode为success(0)
+	if !bytes.Contains(reply[:n], []byte{0x78}) || !bytes.Contains(reply[:n], []byte{0x0a, 0x01, 0x00}) {
+		return nil, errors.New("LDAP服务不支持StartTLS")
+	}
+	return conn, nil
+}
+
+// postgresStartTLS 发送SSLRequest，服务端应答S时开始TLS握手
+func postgresStartTLS(conn net.Conn) (net.Conn, error) {
+	if _, err := conn.Write([]byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != 'S' {
+		return nil, errors.New("PostgreSQL服务未启用SSL")
+	}
+	return conn, nil
+}
+
+// rdpStartTLS 发送X.224连接请求，请求TLS与CredSSP，服务端选择其一时开始TLS握手
+func rdpStartTLS(conn net.Conn) (net.Conn, error) {
+	request := []byte{
+		0x03, 0x00, 0x00, 0x13, // TPKT
+		0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, // X.224 Connection Request
+		0x01, 0x00, 0x08, 0x00, 0x03, 0x00, 0x00, 0x00, // RDP_NEG_REQ PROTOCOL_SSL|PROTOCOL_HYBRID
+	}
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(header[2:]))
+	if size < len(header) {
+		return nil, errors.New("RDP应答不正确")
+	}
+	reply := make([]byte, size-len(header))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	// 不支持协商的旧版本只返回X.224连接确认
+	if len(reply) < 15 || reply[7] != 0x02 || binary.LittleEndian.Uint32(reply[11:]) == 0 {
+		return nil, errors.New("RDP服务未启用TLS")
+	}
+	return conn, nil
+}
+
+// mssqlStartTLS 发送PRELOGIN，服务端支持加密时TLS握手数据包含在TDS PRELOGIN包中
+func mssqlStartTLS(conn net.Conn) (net.Conn, error) {
+	payload := []byte{
+		0x00, 0x00, 0x0b, 0x00, 0x06, // VERSION
+		0x01, 0x00, 0x11, 0x00, 0x01, // ENCRYPTION
+		0xff,
+		0x0f, 0x00, 0x07, 0xd0, 0x00, 0x00,
+		0x00, // ENCRYPT_OFF，仅登录过程加密
+	}
+	tds := &tdsConn{Conn: conn}
+	if _, err := tds.Write(payload); err != nil {
+		return nil, err
+	}
+	reply, err := tds.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+5 <= len(reply) && reply[i] != 0xff; i += 5 {
+		if reply[i] != 0x01 {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint16(reply[i+1:]))
+		// ENCRYPT_NOT_SUP
+		if offset < len(reply) && reply[offset] == 0x02 {
+			return nil, errors.New("MSSQL服务未启用加密")
+		}
+		return tds, nil
+	}
+	return nil, errors.New("MSSQL PRELOGIN应答不正确")
+}
+
+// tdsConn 收发TDS PRELOGIN包，用于MSSQL的TLS握手
+type tdsConn struct {
+	net.Conn
+	buf []byte
+}
+
+func (c *tdsConn) Write(b []byte) (int, error) {
+	header := []byte{0x12, 0x01, 0, 0, 0, 0, 0x01, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(b)+len(header)))
+	if _, err := c.Conn.Write(append(header, b...)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *tdsConn) Read(b []byte) (int, error) {
+	for len(c.buf) == 0 {
+		packet, err := c.readPacket()
+		if err != nil {
+			return 0, err
+		}
+		c.buf = packet
+	}
+	n := copy(b, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func (c *tdsConn) readPacket() ([]byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.Conn, header); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(header[2:]))
+	if size < len(header) {
+		return nil, errors.New("TDS包长度不正确")
+	}
+	packet := make([]byte, size-len(header))
+	_, err := io.ReadFull(c.Conn, packet)
+	return packet, err
+}
@@ -0,0 +1,309 @@
+package common
+
+import (
+	"dddd/structs"
+	"dddd/utils"
+	"encoding/json"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/hmap/store/hybrid"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 扫描流程的各个阶段
+const (
+	StageInput     = "input"
+	StageDiscovery = "discovery"
+	StagePortScan  = "portscan"
+	StageProtocol  = "protocol"
+	StageWeb       = "web"
+	StageDirBrute  = "dirbrute"
+	StageFinger    = "finger"
+	StageNuclei    = "nuclei"
+	StageGoPoc     = "gopoc"
+)
+
+const checkpointFileName = "checkpoint.json"
+
+// 缓存数据库在断点目录下的位置，随扫描写入，不需要在保存断点时导出
+var cacheDirs = []string{"cache/body", "cache/header", "cache/banner"}
+
+// LoadCheckpoint 初始化断点目录并在其中打开缓存数据库，若目录中存在断点文件则将资产恢复到scan中
+func LoadCheckpoint(scan *structs.Scan) (*structs.CheckpointState, error) {
+	state := &structs.CheckpointState{}
+
+	err := checkpointDir(scan)
+	if err != nil {
+		gologger.Error().Msgf("断点目录创建失败: %v", err)
+		return state, openCaches(scan, "")
+	}
+	if err = openCaches(scan, scan.Config.ResumeDir); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(scan.Config.ResumeDir, checkpointFileName))
+	if err != nil {
+		if scan.CheckpointTemp {
+			gologger.Info().Msgf("断点保存目录: %s (中断后可使用 -resume %s 继续扫描，扫描完成后删除)",
+				scan.Config.ResumeDir, scan.Config.ResumeDir)
+		} else {
+			gologger.Info().Msgf("断点保存目录: %s", scan.Config.ResumeDir)
+		}
+		return state, nil
+	}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("断点文件解析失败: %v", err)
+	}
+
+	restoreCheckpoint(scan, state)
+	gologger.Info().Msgf("从断点恢复扫描，已完成阶段: %v", state.FinishedStages)
+	return state, nil
+}
+
+// checkpointDir 未指定-resume时在checkpoints下新建以时间开头的目录，同时开始的扫描不会使用同一目录
+// 新建的目录在扫描成功结束后由RemoveTempCheckpoint删除
+func checkpointDir(scan *structs.Scan) error {
+	if scan.Config.ResumeDir != "" {
+		return os.MkdirAll(scan.Config.ResumeDir, 0755)
+	}
+	err := os.MkdirAll("checkpoints", 0755)
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("checkpoints", strconv.FormatInt(time.Now().Unix(), 10)+"-")
+	if err != nil {
+		return err
+	}
+	scan.Config.ResumeDir = dir
+	scan.CheckpointTemp = true
+	return nil
+}
+
+// RemoveTempCheckpoint 扫描成功结束后关闭缓存数据库并删除自动创建的断点目录，指定了-resume的目录保留
+func RemoveTempCheckpoint(scan *structs.Scan) {
+	if !scan.CheckpointTemp {
+		return
+	}
+	closeCaches(scan)
+	if err := os.RemoveAll(scan.Config.ResumeDir); err != nil {
+		gologger.Error().Msgf("删除断点目录 %s 失败: %v", scan.Config.ResumeDir, err)
+		return
+	}
+	// checkpoints下没有其他扫描的目录时一并删除
+	_ = os.Remove(filepath.Dir(scan.Config.ResumeDir))
+	scan.CheckpointTemp = false
+}
+
+// closeCaches 关闭缓存数据库，可重复调用
+func closeCaches(scan *structs.Scan) {
+	for _, hm := range []**hybrid.HybridMap{&scan.HttpBodyHMap, &scan.HttpHeaderHMap, &scan.BannerHMap} {
+		if *hm != nil {
+			_ = (*hm).Close()
+			*hm = nil
+		}
+	}
+}
+
+// openCaches 在dir下打开响应体、响应头与Banner缓存数据库，dir为空时使用扫描结束后删除的临时数据库
+func openCaches(scan *structs.Scan, dir string) error {
+	names := []string{"Web响应体", "Web响应头", "Banner"}
+	maps := []**hybrid.HybridMap{&scan.HttpBodyHMap, &scan.HttpHeaderHMap, &scan.BannerHMap}
+	for i, cacheDir := range cacheDirs {
+		options := hybrid.DefaultDiskOptions
+		if dir != "" {
+			options.Path = filepath.Join(dir, filepath.FromSlash(cacheDir))
+			options.Cleanup = false
+			if err := os.MkdirAll(options.Path, 0755); err != nil {
+				return fmt.Errorf("%s缓存数据库初始化失败: %v", names[i], err)
+			}
+		}
+		hm, err := hybrid.New(options)
+		if err != nil {
+			return fmt.Errorf("%s缓存数据库初始化失败: %v", names[i], err)
+		}
+		*maps[i] = hm
+	}
+	return nil
+}
+
+// importCaches 将其他断点目录中的缓存数据库复制到本次扫描
+func importCaches(scan *structs.Scan, dir string) {
+	maps := []*hybrid.HybridMap{scan.HttpBodyHMap, scan.HttpHeaderHMap, scan.BannerHMap}
+	for i, cacheDir := range cacheDirs {
+		path := filepath.Join(dir, filepath.FromSlash(cacheDir))
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		options := hybrid.DefaultDiskOptions
+		options.Path = path
+		options.Cleanup = false
+		hm, err := hybrid.New(options)
+		if err != nil {
+			gologger.Error().Msgf("导入断点缓存 %s 失败: %v", path, err)
+			continue
+		}
+		hm.Scan(func(k []byte, v []byte) error {
+			_ = maps[i].Set(string(k), v)
+			return nil
+		})
+		_ = hm.Close()
+	}
+}
+
+// restoreCheckpoint 将断点中的资产写回scan
+func restoreCheckpoint(scan *structs.Scan, state *structs.CheckpointState) {
+	if scan.Config.ReportName == "" {
+		scan.Config.ReportName = state.ReportName
+	}
+	for k, v := range state.IPPortMap {
+		scan.IPPortMap[k] = v
+	}
+	for k, v := range state.ServiceMap {
+		scan.ServiceMap[k] = v
+	}
+	for k, v := range state.CertMap {
+		scan.CertMap[k] = v
+	}
+	for k, v := range state.IPDomainMap {
+		scan.IPDomainMap[k] = v
+	}
+	for k, v := range state.URLMap {
+		scan.URLMap[k] = v
+	}
+	for k, v := range state.ResultMap {
+		scan.ResultMap[k] = v
+	}
+	for k, v := range state.FlaggedHosts {
+		scan.FlaggedHosts[k] = v
+	}
+}
+
+// StageFinished 判断阶段是否已在断点中完成
+func StageFinished(state *structs.CheckpointState, stage string) bool {
+	for _, s := range state.FinishedStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// AllStages 可通过-stages选择的阶段，poc为nuclei与gopoc的简写
+var AllStages = []string{StageDiscovery, StagePortScan, StageProtocol, StageWeb, StageDirBrute,
+	StageFinger, StageNuclei, StageGoPoc}
+
+// ParseStages 解析-stages参数
+func ParseStages(s string) ([]string, error) {
+	var stages []string
+	for _, each := range strings.Split(s, ",") {
+		each = strings.ToLower(strings.TrimSpace(each))
+		if each == "" {
+			continue
+		}
+		if each == "poc" {
+			stages = append(stages, StageNuclei, StageGoPoc)
+			continue
+		}
+		if utils.GetItemInArray(AllStages, each) == -1 {
+			return nil, fmt.Errorf("未知阶段: %s 可选: %s,poc", each, strings.Join(AllStages, ","))
+		}
+		stages = append(stages, each)
+	}
+	return utils.RemoveDuplicateElement(stages), nil
+}
+
+// ShouldRunStage 阶段被选中且未在断点中完成时返回true
+func ShouldRunStage(scan *structs.Scan, state *structs.CheckpointState, stage string) bool {
+	if StageFinished(state, stage) {
+		return false
+	}
+	if len(scan.Config.Stages) == 0 {
+		return true
+	}
+	return utils.GetItemInArray(scan.Config.Stages, stage) != -1
+}
+
+// ImportCheckpoint 从其他断点目录导入资产，不继承其已完成阶段
+func ImportCheckpoint(scan *structs.Scan, state *structs.CheckpointState, dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFileName))
+	if err != nil {
+		return fmt.Errorf("读取导入的断点文件失败: %v", err)
+	}
+	imported := &structs.CheckpointState{}
+	err = json.Unmarshal(data, imported)
+	if err != nil {
+		return fmt.Errorf("导入的断点文件解析失败: %v", err)
+	}
+	// 报告仍输出到本次扫描的文件
+	reportName := scan.Config.ReportName
+	restoreCheckpoint(scan, imported)
+	scan.Config.ReportName = reportName
+	importCaches(scan, dir)
+
+	state.Domains = scan.FilterExcluded(utils.RemoveDuplicateElement(append(state.Domains, imported.Domains...)))
+	state.DomainPort = scan.FilterExcluded(utils.RemoveDuplicateElement(append(state.DomainPort, imported.DomainPort...)))
+	state.URLs = scan.FilterExcluded(utils.RemoveDuplicateElement(append(state.URLs, imported.URLs...)))
+	state.IPs = scan.FilterExcluded(utils.RemoveDuplicateElement(append(state.IPs, imported.IPs...)))
+	state.IPRanges = utils.RemoveDuplicateElement(append(state.IPRanges, imported.IPRanges...))
+	state.IPPort = scan.FilterExcluded(utils.RemoveDuplicateElement(append(state.IPPort, imported.IPPort...)))
+	state.AliveURLs = scan.FilterExcluded(utils.RemoveDuplicateElement(append(state.AliveURLs, imported.AliveURLs...)))
+	state.NucleiResults = append(state.NucleiResults, imported.NucleiResults...)
+	gologger.Info().Msgf("已导入断点 %s 中的资产，端口服务: %d Web: %d",
+		dir, len(imported.IPPortMap), len(imported.URLMap))
+	return nil
+}
+
+// SaveCheckpoint 标记阶段完成并将当前资产状态写入断点目录
+func SaveCheckpoint(scan *structs.Scan, state *structs.CheckpointState, stage string) {
+	if !StageFinished(state, stage) {
+		state.FinishedStages = append(state.FinishedStages, stage)
+	}
+	if scan.Config.ResumeDir == "" {
+		return
+	}
+	state.ReportName = scan.Config.ReportName
+
+	state.IPPortMap = scan.IPPortMap
+	state.ServiceMap = scan.ServiceMap
+	state.CertMap = scan.CertMap
+	state.IPDomainMap = scan.IPDomainMap
+	state.URLMap = scan.URLMap
+	state.ResultMap = scan.ResultMap
+	state.FlaggedHosts = scan.FlaggedHosts
+
+	// 流水线中其他阶段可能同时写入资产
+	scan.IPPortMapLock.Lock()
+	scan.IPDomainMapLock.Lock()
+	scan.URLMapLock.Lock()
+	scan.ResultMapLock.Lock()
+	scan.FlaggedHostsLock.Lock()
+	data, err := json.Marshal(state)
+	scan.FlaggedHostsLock.Unlock()
+	scan.ResultMapLock.Unlock()
+	scan.URLMapLock.Unlock()
+	scan.IPDomainMapLock.Unlock()
+	scan.IPPortMapLock.Unlock()
+	if err != nil {
+		gologger.Error().Msgf("断点序列化失败: %v", err)
+		return
+	}
+
+	// 先写临时文件再重命名，避免中断时损坏断点
+	fileName := filepath.Join(scan.Config.ResumeDir, checkpointFileName)
+	err = os.WriteFile(fileName+".tmp", data, 0644)
+	if err != nil {
+		gologger.Error().Msgf("断点写入失败: %v", err)
+		return
+	}
+	err = os.Rename(fileName+".tmp", fileName)
+	if err != nil {
+		gologger.Error().Msgf("断点写入失败: %v", err)
+		return
+	}
+	gologger.Debug().Msgf("阶段 %s 已完成，断点已保存", stage)
+}
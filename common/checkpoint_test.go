@@ -0,0 +1,36 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStages(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{"web", []string{StageWeb}},
+		{" Web , finger ,", []string{StageWeb, StageFinger}},
+		{"poc", []string{StageNuclei, StageGoPoc}},
+		{"nuclei,poc,nuclei", []string{StageNuclei, StageGoPoc}},
+		{"discovery,portscan,protocol,web,dirbrute,finger,nuclei,gopoc", AllStages},
+	}
+	for _, tt := range tests {
+		got, err := ParseStages(tt.input)
+		if err != nil {
+			t.Errorf("ParseStages(%q) error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseStages(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"input", "web,unknown"} {
+		if _, err := ParseStages(input); err == nil {
+			t.Errorf("ParseStages(%q) 应返回错误", input)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package common
+
+import (
+	"dddd/common/ratelimit"
+	"dddd/common/report"
+	"dddd/common/rtt"
+	"dddd/common/synscan"
+	"dddd/structs"
+	"errors"
+	"github.com/projectdiscovery/gologger"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 主机发现的探测方式，记录存活主机首个成功的探测
+const (
+	ProbeICMPEcho      = "icmp-echo"
+	ProbeICMPTimestamp = "icmp-timestamp"
+	ProbePing          = "ping"
+	ProbeTCPSYN        = "tcp-syn"
+	ProbeTCPACK        = "tcp-ack"
+	ProbeUDP           = "udp"
+	ProbeARP           = "arp"
+)
+
+// PortTCPPingDefault 默认TCP主机发现端口
+var PortTCPPingDefault = "80,443,3389,445,22"
+
+// PortUDPPingDefault 默认UDP主机发现端口，通常关闭的高端口会回复ICMP端口不可达
+var PortUDPPingDefault = "40125"
+
+// DiscoveryOptions 主机发现参数，端口为空的TCP/UDP探测不执行
+type DiscoveryOptions struct {
+	ICMPEcho      bool
+	ICMPTimestamp bool
+	ARP           bool          // 对直连网段的IPv4主机进行ARP探测
+	TCPSYNPorts   []int         // TCP全连接探测，收到SYN/ACK或RST即存活
+	TCPACKPorts   []int         // TCP ACK探测，需要原始套接字
+	UDPPorts      []int         // UDP探测，收到响应或端口不可达即存活
+	Timeout       time.Duration // TCP/UDP单次探测的最大超时时间
+	Threads       int
+	Rate          int                  // ACK探测每秒发包数
+	OnAlive       func(host AliveHost) // 确认存活时调用，阻塞期间应答在系统接收缓冲区中等待，长时间阻塞会丢失ICMP应答
+	Scan          *structs.Scan        // 存活主机与探测进度记录到此扫描中，为nil时不记录
+}
+
+// AliveHost 存活主机，Probe为首个成功的探测方式，RTT为该探测测得的往返时延
+type AliveHost struct {
+	IP    string
+	Probe string
+	RTT   time.Duration
+}
+
+// DefaultDiscoveryOptions 按命令行参数生成主机发现参数
+func DefaultDiscoveryOptions(scan *structs.Scan) DiscoveryOptions {
+	config := scan.Config
+	opts := DiscoveryOptions{
+		ICMPEcho:      !config.NoICMPPing,
+		ICMPTimestamp: config.ICMPTimestamp,
+		ARP:           !config.NoARPPing,
+		Timeout:       time.Duration(config.TCPPortScanTimeout) * time.Second,
+		Threads:       config.TCPPortScanThreads,
+		Rate:          synRate(scan),
+		Scan:          scan,
+	}
+	tcpPorts := config.TCPPingPorts
+	if tcpPorts == "" {
+		tcpPorts = PortTCPPingDefault
+	}
+	if config.TCPPing {
+		opts.TCPSYNPorts = ParsePort(tcpPorts)
+	}
+	if config.TCPACKPing {
+		opts.TCPACKPorts = ParsePort(tcpPorts)
+	}
+	if config.UDPPing {
+		udpPorts := config.UDPPingPorts
+		if udpPorts == "" {
+			udpPorts = PortUDPPingDefault
+		}
+		opts.UDPPorts = ParsePort(udpPorts)
+	}
+	return opts
+}
+
+// DiscoverHosts 依次使用ARP、ICMP、TCP、UDP探测主机存活，每种探测只针对尚未确认存活的主机
+// 每次调用的结果互相独立，可重复及并发调用
+func DiscoverHosts(hosts []string, opts DiscoveryOptions) []AliveHost {
+	if opts.Scan == nil {
+		opts.Scan = structs.NewScan(structs.Config{})
+	}
+	r := &discoveryRun{
+		opts:    opts,
+		targets: make(map[string]string),
+		alive:   make(map[string]struct{}),
+	}
+	for _, host := range hosts {
+		r.targets[canonicalIP(host)] = host
+	}
+	if len(r.targets) == 0 {
+		return nil
+	}
+
+	if opts.ARP {
+		r.arpSweep(r.pending(hosts))
+	}
+	if opts.ICMPEcho {
+		r.icmpSweep(r.pending(hosts), icmpEcho)
+	}
+	if opts.ICMPTimestamp {
+		r.icmpSweep(r.pending(hosts), icmpTimestamp)
+	}
+	if len(opts.TCPSYNPorts) > 0 {
+		r.connectSweep(r.pending(hosts), opts.TCPSYNPorts, "TCP存活探测", ProbeTCPSYN, tcpPing)
+	}
+	if len(opts.TCPACKPorts) > 0 {
+		r.ackSweep(r.pending(hosts))
+	}
+	if len(opts.UDPPorts) > 0 {
+		r.connectSweep(r.pending(hosts), opts.UDPPorts, "UDP存活探测", ProbeUDP, udpPing)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.results
+}
+
+// discoveryRun 一次主机发现的状态
+type discoveryRun struct {
+	opts    DiscoveryOptions
+	targets map[string]string // 规范化的IP : 输入的IP
+
+	lock    sync.Mutex
+	alive   map[string]struct{}
+	results []AliveHost
+}
+
+// canonicalIP 统一IP的文本形式，使响应地址能与输入对应
+func canonicalIP(host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+// found 记录存活主机，同一主机只记录首个成功的探测
+func (r *discoveryRun) found(ip string, probe string, elapsed time.Duration) {
+	r.lock.Lock()
+	host, ok := r.targets[canonicalIP(ip)]
+	if !ok {
+		r.lock.Unlock()
+		return
+	}
+	if _, ok = r.alive[host]; ok {
+		r.lock.Unlock()
+		return
+	}
+	r.alive[host] = struct{}{}
+	r.results = append(r.results, AliveHost{IP: host, Probe: probe, RTT: elapsed})
+	r.lock.Unlock()
+
+	record := structs.HostRecord{IP: host, Probe: probe}
+	if elapsed > 0 {
+		rtt.Record(host, elapsed)
+		record.RTT = float64(elapsed.Microseconds()) / 1000
+		gologger.Silent().Msgf("[%s-Alive] %v (%v %v)", probeTag(probe), host, probe, elapsed.Round(10*time.Microsecond))
+	} else {
+		gologger.Silent().Msgf("[%s-Alive] %v (%v)", probeTag(probe), host, probe)
+	}
+	report.AddRecord(r.opts.Scan, report.RecordHost, record)
+	if r.opts.OnAlive != nil {
+		r.opts.OnAlive(AliveHost{IP: host, Probe: probe, RTT: elapsed})
+	}
+}
+
+// probeTag 输出中的探测类别
+func probeTag(probe string) string {
+	switch probe {
+	case ProbeTCPSYN, ProbeTCPACK:
+		return "TCP"
+	case ProbeUDP:
+		return "UDP"
+	case ProbeARP:
+		return "ARP"
+	default:
+		return "ICMP"
+	}
+}
+
+func (r *discoveryRun) isAlive(host string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, ok := r.alive[host]
+	return ok
+}
+
+// pending 返回尚未确认存活的主机
+func (r *discoveryRun) pending(hosts []string) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var results []string
+	for _, host := range hosts {
+		if _, ok := r.alive[host]; !ok {
+			results = append(results, host)
+		}
+	}
+	return results
+}
+
+// wait 等待hosts全部存活或超时
+func (r *discoveryRun) wait(hosts []string, timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+			if len(r.pending(hosts)) == 0 {
+				return
+			}
+		}
+	}
+}
+
+// connectSweep 对每个主机的端口逐个探测，主机确认存活后跳过其余端口
+func (r *discoveryRun) connectSweep(hosts []string, ports []int, stageName string, probe string,
+	ping func(addr Addr, timeout time.Duration) (time.Duration, bool)) {
+	if len(hosts) == 0 {
+		return
+	}
+	targets := make([]Addr, 0, len(hosts)*len(ports))
+	for _, port := range ports {
+		for _, host := range hosts {
+			targets = append(targets, Addr{host, port})
+		}
+	}
+	rand.Shuffle(len(targets), func(i, j int) {
+		targets[i], targets[j] = targets[j], targets[i]
+	})
+
+	workers := r.opts.Threads
+	if workers > len(targets) || workers <= 0 {
+		workers = len(targets)
+	}
+
+	gologger.Info().Msg(stageName)
+	stage := r.opts.Scan.Progress.Start(stageName, len(targets))
+	defer stage.Finish()
+
+	var wg sync.WaitGroup
+	addrs := make(chan Addr, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range addrs {
+				if !r.isAlive(addr.ip) {
+					if elapsed, ok := ping(addr, rtt.Timeout(addr.ip, r.opts.Timeout)); ok {
+						r.found(addr.ip, probe, elapsed)
+					}
+				}
+				stage.Add(1)
+			}
+		}()
+	}
+	for _, addr := range targets {
+		addrs <- addr
+	}
+	close(addrs)
+	wg.Wait()
+}
+
+// tcpPing 连接建立或被拒绝都说明主机存活
+func tcpPing(addr Addr, timeout time.Duration) (time.Duration, bool) {
+	state, elapsed := dialPort(addr, timeout)
+	return elapsed, state == PortOpen || state == PortClosed
+}
+
+// udpPing 发送空UDP报文，收到任意响应或ICMP端口不可达即存活
+func udpPing(addr Addr, timeout time.Duration) (time.Duration, bool) {
+	address := net.JoinHostPort(addr.ip, strconv.Itoa(addr.port))
+	release := ratelimit.Acquire(address)
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		release()
+		return 0, false
+	}
+	conn = ratelimit.WrapConn(conn, release)
+	defer conn.Close()
+	start := time.Now()
+	_ = conn.SetDeadline(start.Add(timeout))
+	if _, err = conn.Write([]byte{}); err != nil {
+		return 0, false
+	}
+	buf := make([]byte, 512)
+	_, err = conn.Read(buf)
+	if err == nil || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return time.Since(start), true
+	}
+	return 0, false
+}
+
+// ackSweep 使用原始套接字发送TCP ACK，不支持或权限不足时跳过
+func (r *discoveryRun) ackSweep(hosts []string) {
+	if len(hosts) == 0 {
+		return
+	}
+	gologger.Info().Msg("TCP ACK存活探测")
+	err := synscan.ACKPing(synscan.PingOptions{
+		IPs:   hosts,
+		Ports: r.opts.TCPACKPorts,
+		Rate:  r.opts.Rate,
+		Wait:  r.opts.Timeout,
+		OnAlive: func(ip string, elapsed time.Duration) {
+			r.found(ip, ProbeTCPACK, elapsed)
+		},
+	})
+	if err != nil {
+		gologger.Error().Msgf("TCP ACK存活探测不可用: %v", err)
+	}
+}
+
+// arpSweep 对直连网段的IPv4主机进行ARP探测，可发现拦截全部IP层探测的主机
+func (r *discoveryRun) arpSweep(hosts []string) {
+	networks := localNetworks()
+	var local []string
+	for _, host := range hosts {
+		if onLink(host, networks) {
+			local = append(local, host)
+		}
+	}
+	if len(local) == 0 {
+		return
+	}
+	gologger.Info().Msg("ARP存活探测")
+	alive, err := arpProbe(local, 2*time.Second)
+	if err != nil {
+		gologger.Debug().Msgf("ARP存活探测不可用: %v", err)
+		return
+	}
+	for _, ip := range alive {
+		r.found(ip, ProbeARP, 0)
+	}
+}
+
+// localNetworks 本机已启用的非回环网卡上的IPv4网段
+func localNetworks() []*net.IPNet {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var networks []*net.IPNet
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if ok && ipNet.IP.To4() != nil {
+				networks = append(networks, ipNet)
+			}
+		}
+	}
+	return networks
+}
+
+// onLink host是否位于本机网卡直连的IPv4网段，本机地址除外
+func onLink(host string, networks []*net.IPNet) bool {
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range networks {
+		if ipNet.IP.Equal(ip) {
+			return false
+		}
+	}
+	for _, ipNet := range networks {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package common
+
+import (
+	"dddd/common/report"
+	"dddd/common/rtt"
+	"dddd/structs"
+	"dddd/utils"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"math/rand"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tarpitProbePorts 每个主机探测的随机高端口数量，全部开放则视为tarpit
+const tarpitProbePorts = 3
+
+// TarpitCheck 端口扫描前对每个主机连接几个随机高端口，全部开放的主机为tarpit或对所有端口应答的防火墙
+// 标记并返回其余主机，避免对这些主机进行完整的端口扫描
+func TarpitCheck(scan *structs.Scan, IPs []string, timeout int) []string {
+	if len(IPs) == 0 {
+		return IPs
+	}
+	workers := scan.Config.TCPPortScanThreads / tarpitProbePorts
+	if workers > len(IPs) || workers <= 0 {
+		workers = len(IPs)
+	}
+
+	gologger.Info().Msg("Tarpit检测")
+	stage := scan.Progress.Start("Tarpit检测", len(IPs))
+	defer stage.Finish()
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	flagged := make(map[string]struct{})
+	hosts := make(chan string, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range hosts {
+				if IsTarpit(scan, host, timeout) {
+					lock.Lock()
+					flagged[host] = struct{}{}
+					lock.Unlock()
+				}
+				stage.Add(1)
+			}
+		}()
+	}
+	for _, host := range IPs {
+		hosts <- host
+	}
+	close(hosts)
+	wg.Wait()
+
+	if len(flagged) == 0 {
+		return IPs
+	}
+	var results []string
+	for _, host := range IPs {
+		if _, ok := flagged[host]; !ok {
+			results = append(results, host)
+		}
+	}
+	return results
+}
+
+// IsTarpit 检测单个主机，判定为tarpit时标记主机
+func IsTarpit(scan *structs.Scan, host string, timeout int) bool {
+	if !allPortsOpen(host, time.Duration(timeout)*time.Second) {
+		return false
+	}
+	flagHost(scan, host, "tarpit", fmt.Sprintf("随机高端口 %d/%d 开放", tarpitProbePorts, tarpitProbePorts))
+	return true
+}
+
+// allPortsOpen 并发连接host的随机高端口，全部连接成功时返回true
+func allPortsOpen(host string, maxTimeout time.Duration) bool {
+	var wg sync.WaitGroup
+	var open sync.Map
+	used := make(map[int]struct{})
+	for len(used) < tarpitProbePorts {
+		used[40000+rand.Intn(25535)] = struct{}{}
+	}
+	for port := range used {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			address := net.JoinHostPort(host, strconv.Itoa(port))
+			conn, err := WrapperTcpWithTimeout("tcp", address, rtt.Timeout(host, maxTimeout))
+			if err == nil {
+				conn.Close()
+				open.Store(port, struct{}{})
+			}
+		}(port)
+	}
+	wg.Wait()
+
+	count := 0
+	open.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count == tarpitProbePorts
+}
+
+// flagHost 标记主机并输出
+func flagHost(scan *structs.Scan, host string, kind string, reason string) {
+	if !scan.FlagHost(host, kind+": "+reason) {
+		return
+	}
+	if kind == "tarpit" {
+		gologger.Silent().Msgf("[Tarpit] %s %s，跳过端口扫描与漏洞探测", host, reason)
+	} else {
+		gologger.Silent().Msgf("[Honeypot] %s %s，跳过漏洞探测", host, reason)
+	}
+	report.AddRecord(scan, report.RecordFlag, structs.HostFlagRecord{Host: host, Kind: kind, Reason: reason})
+}
+
+// reportOSConflict 输出Banner操作系统冲突的主机。NAT或端口转发后的多台主机也会出现这种情况，只作提示，不跳过漏洞探测
+func reportOSConflict(scan *structs.Scan, host string, reason string) {
+	gologger.Silent().Msgf("[OS-Conflict] %s %s", host, reason)
+	report.AddRecord(scan, report.RecordFlag, structs.HostFlagRecord{Host: host, Kind: "os-conflict", Reason: reason})
+}
+
+// osFamilies Banner与Server头中的操作系统特征
+var osFamilies = []struct {
+	name   string
+	regexp *regexp.Regexp
+}{
+	{"Windows", regexp.MustCompile(`(?i)windows|win32|win64|microsoft-iis|microsoft-httpapi`)},
+	{"Linux", regexp.MustCompile(`(?i)ubuntu|debian|centos|red ?hat|fedora|suse|linux`)},
+	{"BSD", regexp.MustCompile(`(?i)freebsd|openbsd|netbsd`)},
+	{"Cisco IOS", regexp.MustCompile(`(?i)cisco ios`)},
+}
+
+// bannerHeadSize 只检查Banner开头的部分，避免正文内容误判
+const bannerHeadSize = 512
+
+// HoneypotCheck 指纹识别后按主机汇总，同一主机匹配的产品过多时判定为疑似蜜罐；
+// 不同端口的Banner来自互相冲突的操作系统时只输出提示
+func HoneypotCheck(scan *structs.Scan) {
+	products := make(map[string]map[string]struct{})
+	scan.ResultMapLock.Lock()
+	for target, results := range scan.ResultMap {
+		host := utils.TargetHost(target)
+		if host == "" {
+			continue
+		}
+		if products[host] == nil {
+			products[host] = make(map[string]struct{})
+		}
+		for _, product := range results {
+			products[host][product] = struct{}{}
+		}
+	}
+	scan.ResultMapLock.Unlock()
+
+	// 非Web服务的Banner与Web的Server头
+	texts := make(map[string][]string)
+	scan.IPPortMapLock.Lock()
+	for key, protocol := range scan.IPPortMap {
+		if protocol == "http" || protocol == "https" {
+			continue
+		}
+		_, hostPort := structs.SplitServiceKey(key)
+		host, _, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			continue
+		}
+		banner, ok := scan.BannerHMap.Get(key)
+		if !ok {
+			continue
+		}
+		if len(banner) > bannerHeadSize {
+			banner = banner[:bannerHeadSize]
+		}
+		texts[host] = append(texts[host], string(banner))
+	}
+	scan.IPPortMapLock.Unlock()
+	scan.URLMapLock.Lock()
+	for rootURL, entity := range scan.URLMap {
+		host := entity.IP
+		if host == "" {
+			host = utils.TargetHost(rootURL)
+		}
+		for _, pathEntity := range entity.WebPaths {
+			if pathEntity.Server != "" {
+				texts[host] = append(texts[host], pathEntity.Server)
+			}
+		}
+	}
+	scan.URLMapLock.Unlock()
+
+	threshold := scan.Config.HoneypotFingerThreshold
+	for host, set := range products {
+		if threshold > 0 && len(set) >= threshold {
+			flagHost(scan, host, "honeypot", fmt.Sprintf("匹配 %d 个不同产品指纹", len(set)))
+		}
+	}
+	for host, list := range texts {
+		families := make(map[string]struct{})
+		for _, text := range list {
+			for _, family := range osFamilies {
+				if family.regexp.MatchString(text) {
+					families[family.name] = struct{}{}
+				}
+			}
+		}
+		if len(families) < 2 {
+			continue
+		}
+		var names []string
+		for name := range families {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		reportOSConflict(scan, host, "Banner操作系统冲突: "+strings.Join(names, ","))
+	}
+}
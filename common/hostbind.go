@@ -4,55 +4,191 @@ import (
 	"dddd/common/http"
 	"dddd/structs"
 	"dddd/utils"
+	"dddd/utils/cdn"
 	"fmt"
+	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/httpx"
+	"github.com/projectdiscovery/httpx/runner"
+	"net"
 	"net/url"
+	"sort"
 	"strings"
 )
 
-func HostBindCheck() {
+// HostBindCheck 以IP绑定的域名访问该IP上的Web服务，把只允许域名访问的资产扒拉出来
+// 返回证书中属于-cert-root根域名的域名解析出的IP，由调用方进行端口扫描
+func HostBindCheck(scan *structs.Scan) []string {
+	rootNames := certDomains(scan)
+
+	// 每个URL只连接其来源的IP，同一域名绑定在多个IP上时分别访问
 	var urls []string
-	for rootURL, _ := range structs.GlobalURLMap {
+	hostIPs := make(map[string][]string)
+	scan.URLMapLock.Lock()
+	rootURLs := make([]string, 0, len(scan.URLMap))
+	for rootURL := range scan.URLMap {
+		rootURLs = append(rootURLs, rootURL)
+	}
+	scan.URLMapLock.Unlock()
+	scan.IPDomainMapLock.Lock()
+	for _, rootURL := range rootURLs {
 		URL, err := url.Parse(rootURL)
 		if err != nil {
 			continue
 		}
 
-		if strings.Contains(URL.Host, ":") {
-			tmp := strings.Split(URL.Host, ":")
-			if len(tmp) != 2 {
-				continue
+		// IPv4与IPv6
+		ip, port := net.ParseIP(URL.Hostname()), URL.Port()
+		if ip == nil {
+			continue
+		}
+		for _, domain := range scan.IPDomainMap[ip.String()] {
+			host := domain
+			if port != "" {
+				host = net.JoinHostPort(domain, port)
 			}
-			ip, port := tmp[0], tmp[1]
-			if !utils.IsIPv4(ip) {
+			u := fmt.Sprintf("%v://%v", URL.Scheme, host)
+			if scan.IsExcluded(u) {
 				continue
 			}
-			domains, ok := structs.GlobalIPDomainMap[ip]
-			if !ok {
+			urls = append(urls, u)
+			key := net.JoinHostPort(domain, defaultPort(URL.Scheme, port))
+			if utils.GetItemInArray(hostIPs[key], ip.String()) == -1 {
+				hostIPs[key] = append(hostIPs[key], ip.String())
+			}
+		}
+	}
+	scan.IPDomainMapLock.Unlock()
+
+	urls = utils.RemoveDuplicateElement(urls)
+	sort.Strings(urls)
+	httpx.DirBruteWithHosts(urls, hostIPs, func(resp runner.Result) {
+		http.HostBindHTTPxCallBack(scan, resp)
+	}, nil,
+		scan.Config.HTTPProxy,
+		scan.Config.WebThreads,
+		scan.Config.WebTimeout)
+
+	if len(rootNames) == 0 {
+		return nil
+	}
+	return certRootDomains(scan, rootNames)
+}
+
+// defaultPort URL未指定端口时返回协议的默认端口
+func defaultPort(scheme string, port string) string {
+	if port != "" {
+		return port
+	}
+	if scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// certDomains 将证书SAN与CN中的域名绑定到证书所在的IP，写入IPDomainMap，返回属于-cert-root根域名的域名
+func certDomains(scan *structs.Scan) []string {
+	scan.IPPortMapLock.Lock()
+	certs := make(map[string]structs.CertInfo, len(scan.CertMap))
+	for hostPort, cert := range scan.CertMap {
+		certs[hostPort] = cert
+	}
+	scan.IPPortMapLock.Unlock()
+
+	hostPorts := make([]string, 0, len(certs))
+	for hostPort := range certs {
+		hostPorts = append(hostPorts, hostPort)
+	}
+	sort.Strings(hostPorts)
+
+	scan.IPDomainMapLock.Lock()
+	defer scan.IPDomainMapLock.Unlock()
+	var rootNames []string
+	for _, hostPort := range hostPorts {
+		host, _, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		cert := certs[hostPort]
+		for _, name := range append(append([]string{}, cert.DNSNames...), cert.SubjectCN) {
+			name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+			wildcard := strings.HasPrefix(name, "*.")
+			name = strings.TrimPrefix(name, "*.")
+			if !utils.IsDomain(name) || scan.IsExcluded(name) {
 				continue
 			}
-			for _, domain := range domains {
-				urls = append(urls, fmt.Sprintf("%v://%v:%v", URL.Scheme, domain, port))
+			if certRootDomain(scan, name) {
+				rootNames = append(rootNames, name)
 			}
-		} else {
-			ip := URL.Host
-			if !utils.IsIPv4(ip) {
+			// 通配符证书无法确定具体的域名
+			if wildcard {
 				continue
 			}
-			domains, ok := structs.GlobalIPDomainMap[ip]
-			if !ok {
+			if !addIPDomain(scan, ip.String(), name) {
 				continue
 			}
-			for _, domain := range domains {
-				urls = append(urls, fmt.Sprintf("%v://%v", URL.Scheme, domain))
-			}
+			gologger.Silent().Msgf("[Cert-Domain] %s => %s", name, ip.String())
 		}
 	}
-	urls = utils.RemoveDuplicateElement(urls)
+	return utils.RemoveDuplicateElement(rootNames)
+}
 
-	httpx.DirBrute(urls, http.HostBindHTTPxCallBack,
-		structs.GlobalConfig.HTTPProxy,
-		structs.GlobalConfig.WebThreads,
-		structs.GlobalConfig.WebTimeout)
+// addIPDomain 记录IP绑定的域名，已记录时返回false，调用方持有IPDomainMapLock
+func addIPDomain(scan *structs.Scan, ip string, domain string) bool {
+	for _, each := range scan.IPDomainMap[ip] {
+		if each == domain {
+			return false
+		}
+	}
+	scan.IPDomainMap[ip] = append(scan.IPDomainMap[ip], domain)
+	return true
+}
 
+// certRootDomain 域名属于-cert-root指定的根域名时返回true
+func certRootDomain(scan *structs.Scan, name string) bool {
+	for _, root := range scan.Config.CertRootDomains {
+		if name == root || strings.HasSuffix(name, "."+root) {
+			return true
+		}
+	}
+	return false
+}
+
+// certRootDomains 证书中属于根域名的域名重新进行CDN识别与真实IP解析，并直接探测其Web服务，返回解析出的真实IP
+func certRootDomains(scan *structs.Scan, names []string) []string {
+	cdnDomains, normalDomains, realIPs := cdn.CheckCDNs(scan, names, scan.Config.SubdomainBruteForceThreads)
+	var ips []string
+	for _, ip := range utils.RemoveDuplicateElement(realIPs) {
+		if scan.Config.AllowLocalAreaDomain && utils.IsLocalIP(ip) {
+			continue
+		}
+		if !scan.IsExcluded(ip) {
+			ips = append(ips, ip)
+		}
+	}
+
+	var urls []string
+	scan.URLMapLock.Lock()
+	for _, domain := range append(cdnDomains, normalDomains...) {
+		for _, scheme := range []string{"http", "https"} {
+			u := scheme + "://" + domain
+			if _, ok := scan.URLMap[u]; !ok {
+				urls = append(urls, u)
+			}
+		}
+	}
+	scan.URLMapLock.Unlock()
+	urls = scan.FilterExcluded(urls)
+	if len(urls) > 0 {
+		scan.HTTPx.CallHTTPx(urls, func(resp runner.Result) {
+			http.UrlCallBack(scan, resp)
+		}, nil,
+			scan.Config.HTTPProxy,
+			scan.Config.WebThreads,
+			scan.Config.WebTimeout)
+	}
+	return ips
 }
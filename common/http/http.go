@@ -1,18 +1,22 @@
 package http
 
 import (
+	"crypto/tls"
+	"dddd/common/report"
 	"dddd/lib/ddfinger"
 	"dddd/structs"
 	"dddd/utils"
 	"fmt"
+	"github.com/logrusorgru/aurora"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/httpx/runner"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
 )
 
-func UrlCallBack(resp runner.Result) {
+func UrlCallBack(scan *structs.Scan, resp runner.Result) {
 
 	finalUrl := ""
 	if resp.FinalURL != "" {
@@ -27,22 +31,22 @@ func UrlCallBack(resp runner.Result) {
 		pth = "/"
 	}
 	rootURL := fmt.Sprintf("%s://%s", url.Scheme, url.Host)
-	structs.GlobalURLMapLock.Lock()
-	_, rootURLOK := structs.GlobalURLMap[rootURL]
-	structs.GlobalURLMapLock.Unlock()
+	scan.URLMapLock.Lock()
+	_, rootURLOK := scan.URLMap[rootURL]
+	scan.URLMapLock.Unlock()
 	if rootURLOK {
 		// 有这个root，查看这个path，如果没这个path再加
-		structs.GlobalURLMapLock.Lock()
-		_, pathOK := structs.GlobalURLMap[rootURL].WebPaths[url.Path]
-		structs.GlobalURLMapLock.Unlock()
+		scan.URLMapLock.Lock()
+		_, pathOK := scan.URLMap[rootURL].WebPaths[url.Path]
+		scan.URLMapLock.Unlock()
 		if !pathOK {
 			// 没有这个path
 			md5 := resp.Hashes["body_md5"].(string)
 			headerMd5 := resp.Hashes["header_md5"].(string)
-			_ = structs.GlobalHttpBodyHMap.Set(md5, []byte(resp.Body))
-			_ = structs.GlobalHttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
-			structs.GlobalURLMapLock.Lock()
-			structs.GlobalURLMap[rootURL].WebPaths[pth] = structs.UrlPathEntity{
+			_ = scan.HttpBodyHMap.Set(md5, []byte(resp.Body))
+			_ = scan.HttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
+			scan.URLMapLock.Lock()
+			scan.URLMap[rootURL].WebPaths[pth] = structs.UrlPathEntity{
 				Hash:             md5,
 				Title:            resp.Title,
 				StatusCode:       resp.StatusCode,
@@ -52,13 +56,14 @@ func UrlCallBack(resp runner.Result) {
 				HeaderHashString: headerMd5,
 				IconHash:         resp.FavIconMMH3,
 			}
-			structs.GlobalURLMapLock.Unlock()
+			scan.URLMapLock.Unlock()
 
 			if resp.Title != "" {
 				gologger.Silent().Msgf("[Web] [%v] %s [%s]\n", resp.StatusCode, resp.URL, resp.Title)
 			} else {
 				gologger.Silent().Msgf("[Web] [%v] %s\n", resp.StatusCode, resp.URL)
 			}
+			addWebRecord(scan, "web", resp.URL, rootURL, pth)
 		}
 	} else {
 		// 没有这个url
@@ -70,8 +75,8 @@ func UrlCallBack(resp runner.Result) {
 
 		md5 := resp.Hashes["body_md5"].(string)
 		headerMd5 := resp.Hashes["header_md5"].(string)
-		_ = structs.GlobalHttpBodyHMap.Set(md5, []byte(resp.Body))
-		_ = structs.GlobalHttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
+		_ = scan.HttpBodyHMap.Set(md5, []byte(resp.Body))
+		_ = scan.HttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
 
 		webPath := structs.UrlPathEntity{
 			Hash:             md5,
@@ -88,44 +93,70 @@ func UrlCallBack(resp runner.Result) {
 			IP:       resp.Host,
 			Port:     port,
 			WebPaths: nil,
-			Cert:     getTLSString(resp),
+			Cert:     getTLSString(scan, resp),
 		}
 
 		urlE.WebPaths = make(map[string]structs.UrlPathEntity)
 		urlE.WebPaths[pth] = webPath
 
-		structs.GlobalURLMapLock.Lock()
-		structs.GlobalURLMap[rootURL] = urlE
-		structs.GlobalURLMapLock.Unlock()
+		scan.URLMapLock.Lock()
+		scan.URLMap[rootURL] = urlE
+		scan.URLMapLock.Unlock()
 
 		if resp.Title != "" {
 			gologger.Silent().Msgf("[Web] [%v] %s [%s]\n", resp.StatusCode, resp.URL, resp.Title)
 		} else {
 			gologger.Silent().Msgf("[Web] [%v] %s\n", resp.StatusCode, resp.URL)
 		}
+		addWebRecord(scan, "web", resp.URL, rootURL, pth)
 	}
 
 }
 
-func getTLSString(resp runner.Result) string {
-	result := ""
-	if resp.TLSData == nil {
-		return result
-	}
-
-	result += "SubjectCN: " + resp.TLSData.SubjectCN + "\n"
-	result += "SubjectDN: " + resp.TLSData.SubjectDN + "\n"
+// addWebRecord 将新增的Web路径写入JSONL结果
+func addWebRecord(scan *structs.Scan, source string, fullURL string, rootURL string, path string) {
+	scan.URLMapLock.Lock()
+	entity := scan.URLMap[rootURL].WebPaths[path]
+	scan.URLMapLock.Unlock()
+	report.AddRecord(scan, report.RecordWeb, structs.WebRecord{
+		URL:           fullURL,
+		RootURL:       rootURL,
+		Path:          path,
+		Source:        source,
+		UrlPathEntity: entity,
+	})
+}
 
-	result += "IssuerCN: " + resp.TLSData.IssuerCN + "\n"
-	result += "IssuerDN: " + resp.TLSData.IssuerDN + "\n"
+// tlsVersions httpx记录的TLS版本
+var tlsVersions = map[string]uint16{
+	"tls10": tls.VersionTLS10,
+	"tls11": tls.VersionTLS11,
+	"tls12": tls.VersionTLS12,
+	"tls13": tls.VersionTLS13,
+}
 
-	result += "IssuerOrg: \n"
-	for _, v := range resp.TLSData.IssuerOrg {
-		result += "    - " + v + "\n"
+// getTLSString 返回供cert=规则匹配的证书文本，同一IP:Port首次获取的证书同时输出并写入结果
+func getTLSString(scan *structs.Scan, resp runner.Result) string {
+	if resp.TLSData == nil || resp.TLSData.CertificateResponse == nil {
+		return ""
 	}
-
-	return result
-
+	info, ok := utils.ParsePEMCertInfo(resp.TLSData.Certificate, tlsVersions[resp.TLSData.Version])
+	if !ok {
+		return ""
+	}
+	host := resp.Host
+	if host == "" {
+		// 经代理访问时没有解析得到的IP
+		host = URLParse(resp.URL).Hostname()
+	}
+	hostPort := net.JoinHostPort(host, resp.Port)
+	if scan.SetCertificate(hostPort, info) {
+		port, _ := strconv.Atoi(resp.Port)
+		target := resp.Scheme + "://" + hostPort
+		gologger.Silent().Msgf("[Cert] %s [%s]", target, aurora.Cyan(info.Summary()))
+		report.AddRecord(scan, report.RecordCert, structs.CertRecord{Target: target, IP: resp.Host, Port: port, CertInfo: info})
+	}
+	return info.String()
 }
 
 func URLParse(URLRaw string) *url.URL {
@@ -161,7 +192,7 @@ func addPocs(target string, result *map[string][]string, workflowEntity structs.
 	}
 }
 
-func GetPocs(workflowDB map[string]structs.WorkFlowEntity) (map[string][]string, int) {
+func GetPocs(scan *structs.Scan, workflowDB map[string]structs.WorkFlowEntity) (map[string][]string, int) {
 	result := make(map[string][]string)
 	count := 0
 
@@ -175,7 +206,18 @@ func GetPocs(workflowDB map[string]structs.WorkFlowEntity) (map[string][]string,
 		}
 	}
 
-	for target, fingerprints := range structs.GlobalResultMap {
+	scan.ResultMapLock.Lock()
+	resultMap := make(map[string][]string, len(scan.ResultMap))
+	for target, fingerprints := range scan.ResultMap {
+		resultMap[target] = fingerprints
+	}
+	scan.ResultMapLock.Unlock()
+
+	for target, fingerprints := range resultMap {
+		// 疑似蜜罐与tarpit主机不进行漏洞探测
+		if scan.HostFlagged(utils.TargetHost(target)) {
+			continue
+		}
 		for _, finger := range fingerprints {
 			workflowEntity, ok := workflowDB[finger]
 			if !ok || len(workflowEntity.PocsName) == 0 {
@@ -262,7 +304,7 @@ func GetPocs(workflowDB map[string]structs.WorkFlowEntity) (map[string][]string,
 	return result, count
 }
 
-func DirBruteCallBack(resp runner.Result) {
+func DirBruteCallBack(scan *structs.Scan, resp runner.Result) {
 	var Paths []string
 	for dbPath, _ := range structs.DirDB {
 		if strings.HasSuffix(resp.Path, dbPath) {
@@ -283,8 +325,9 @@ func DirBruteCallBack(resp runner.Result) {
 					if err != nil {
 						portInt = -1
 					}
-					r := ddfinger.SingleCheck(v, resp.Scheme, resp.Header, resp.Body, resp.WebServer, resp.Title, getTLSString(resp),
-						portInt, resp.Path, "0", "0", resp.StatusCode, resp.ContentType, "")
+					r := ddfinger.SingleCheck(v, resp.Scheme, resp.Header, resp.Body, resp.WebServer, resp.Title, getTLSString(scan, resp),
+						portInt, resp.Path, "0", "0", resp.StatusCode, resp.ContentType, "",
+						scan.GetServiceFingerprint(net.JoinHostPort(resp.Host, resp.Port)))
 					// 满足这个products的要求
 					if r {
 						success = true
@@ -292,23 +335,23 @@ func DirBruteCallBack(resp runner.Result) {
 						Url := URLParse(resp.URL)
 						rootURL := fmt.Sprintf("%s://%s", Url.Scheme, Url.Host)
 
-						structs.GlobalURLMapLock.Lock()
-						_, rootURLOk := structs.GlobalURLMap[rootURL]
-						structs.GlobalURLMapLock.Unlock()
+						scan.URLMapLock.Lock()
+						_, rootURLOk := scan.URLMap[rootURL]
+						scan.URLMapLock.Unlock()
 						if rootURLOk {
 							// 如果爆破来源上一步验活，那这里必然存在rootURL.
 							// 有这个root，查看这个path，如果没这个path再加
-							structs.GlobalURLMapLock.Lock()
-							_, pathOK := structs.GlobalURLMap[rootURL].WebPaths[Url.Path]
-							structs.GlobalURLMapLock.Unlock()
+							scan.URLMapLock.Lock()
+							_, pathOK := scan.URLMap[rootURL].WebPaths[Url.Path]
+							scan.URLMapLock.Unlock()
 							if !pathOK {
 								// 没有这个path
 								md5 := resp.Hashes["body_md5"].(string)
 								headerMd5 := resp.Hashes["header_md5"].(string)
-								_ = structs.GlobalHttpBodyHMap.Set(md5, []byte(resp.Body))
-								_ = structs.GlobalHttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
-								structs.GlobalURLMapLock.Lock()
-								structs.GlobalURLMap[rootURL].WebPaths[Url.Path] = structs.UrlPathEntity{
+								_ = scan.HttpBodyHMap.Set(md5, []byte(resp.Body))
+								_ = scan.HttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
+								scan.URLMapLock.Lock()
+								scan.URLMap[rootURL].WebPaths[Url.Path] = structs.UrlPathEntity{
 									Hash:             md5,
 									Title:            resp.Title,
 									StatusCode:       resp.StatusCode,
@@ -318,7 +361,8 @@ func DirBruteCallBack(resp runner.Result) {
 									HeaderHashString: headerMd5,
 									IconHash:         resp.FavIconMMH3,
 								}
-								structs.GlobalURLMapLock.Unlock()
+								scan.URLMapLock.Unlock()
+								addWebRecord(scan, "active-finger", resp.URL, rootURL, Url.Path)
 							}
 
 							gologger.Silent().Msgf("[Active-Finger] %s [%s]", resp.URL, productName)
@@ -330,13 +374,17 @@ func DirBruteCallBack(resp runner.Result) {
 	}
 }
 
-func HostBindHTTPxCallBack(resp runner.Result) {
+func HostBindHTTPxCallBack(scan *structs.Scan, resp runner.Result) {
 	ips := resp.A
+	if resp.Host != "" {
+		// 证书中的域名连接指定的IP，不在解析结果中
+		ips = append(append([]string{}, resp.A...), resp.Host)
+	}
 	path := resp.Path
 	newWeb := false
 	for _, ip := range ips {
-		structs.GlobalURLMapLock.Lock()
-		for rootURL, urlEntry := range structs.GlobalURLMap {
+		scan.URLMapLock.Lock()
+		for rootURL, urlEntry := range scan.URLMap {
 			URL, err := url.Parse(rootURL)
 			if err != nil {
 				continue
@@ -362,7 +410,7 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 			}
 
 		}
-		structs.GlobalURLMapLock.Unlock()
+		scan.URLMapLock.Unlock()
 	}
 
 	if !newWeb {
@@ -383,22 +431,22 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 
 	urlFinal := URLParse(finalUrl)
 	rootURL := fmt.Sprintf("%s://%s", urlFinal.Scheme, urlFinal.Host)
-	structs.GlobalURLMapLock.Lock()
-	_, rootURLOK := structs.GlobalURLMap[rootURL]
-	structs.GlobalURLMapLock.Unlock()
+	scan.URLMapLock.Lock()
+	_, rootURLOK := scan.URLMap[rootURL]
+	scan.URLMapLock.Unlock()
 	if rootURLOK {
 		// 有这个root，查看这个path，如果没这个path再加
-		structs.GlobalURLMapLock.Lock()
-		_, pathOK := structs.GlobalURLMap[rootURL].WebPaths[urlFinal.Path]
-		structs.GlobalURLMapLock.Unlock()
+		scan.URLMapLock.Lock()
+		_, pathOK := scan.URLMap[rootURL].WebPaths[urlFinal.Path]
+		scan.URLMapLock.Unlock()
 		if !pathOK {
 			// 没有这个path
 			md5 := resp.Hashes["body_md5"].(string)
 			headerMd5 := resp.Hashes["header_md5"].(string)
-			_ = structs.GlobalHttpBodyHMap.Set(md5, []byte(resp.Body))
-			_ = structs.GlobalHttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
-			structs.GlobalURLMapLock.Lock()
-			structs.GlobalURLMap[rootURL].WebPaths[urlFinal.Path] = structs.UrlPathEntity{
+			_ = scan.HttpBodyHMap.Set(md5, []byte(resp.Body))
+			_ = scan.HttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
+			scan.URLMapLock.Lock()
+			scan.URLMap[rootURL].WebPaths[urlFinal.Path] = structs.UrlPathEntity{
 				Hash:             md5,
 				Title:            resp.Title,
 				StatusCode:       resp.StatusCode,
@@ -408,7 +456,8 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 				HeaderHashString: headerMd5,
 				IconHash:         resp.FavIconMMH3,
 			}
-			structs.GlobalURLMapLock.Unlock()
+			scan.URLMapLock.Unlock()
+			addWebRecord(scan, "domain-bind", resp.URL, rootURL, urlFinal.Path)
 		}
 	} else {
 		// 没有这个url
@@ -420,8 +469,8 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 
 		md5 := resp.Hashes["body_md5"].(string)
 		headerMd5 := resp.Hashes["header_md5"].(string)
-		_ = structs.GlobalHttpBodyHMap.Set(md5, []byte(resp.Body))
-		_ = structs.GlobalHttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
+		_ = scan.HttpBodyHMap.Set(md5, []byte(resp.Body))
+		_ = scan.HttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
 
 		webPath := structs.UrlPathEntity{
 			Hash:             md5,
@@ -438,15 +487,36 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 			IP:       resp.Host,
 			Port:     port,
 			WebPaths: nil,
-			Cert:     getTLSString(resp),
+			Cert:     getTLSString(scan, resp),
 		}
 
 		urlE.WebPaths = make(map[string]structs.UrlPathEntity)
 		urlE.WebPaths[urlFinal.Path] = webPath
 
-		structs.GlobalURLMapLock.Lock()
-		structs.GlobalURLMap[rootURL] = urlE
-		structs.GlobalURLMapLock.Unlock()
+		scan.URLMapLock.Lock()
+		scan.URLMap[rootURL] = urlE
+		scan.URLMapLock.Unlock()
+		addWebRecord(scan, "domain-bind", resp.URL, rootURL, urlFinal.Path)
 	}
 
 }
+
+// TrackProgress 统计httpx探测进度，onProgress在每个目标探测结束后调用，finish在探测结束后调用
+func TrackProgress(scan *structs.Scan, name string, total int) (onProgress func(), finish func()) {
+	stage := scan.Progress.Start(name, total)
+	return func() {
+		stage.Add(1)
+	}, stage.Finish
+}
+
+// TrackStreamProgress 统计流式httpx探测进度，每提交一个目标调用add，探测结束后调用finish
+func TrackStreamProgress(scan *structs.Scan, name string) (add func(), onProgress func(), finish func()) {
+	stage := scan.Progress.Start(name, 0)
+	add = func() {
+		stage.AddTotal(1)
+	}
+	onProgress = func() {
+		stage.Add(1)
+	}
+	return add, onProgress, stage.Finish
+}
@@ -2,9 +2,14 @@ package common
 
 import (
 	"bytes"
+	"dddd/utils"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
 	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 	"net"
 	"os/exec"
 	"runtime"
@@ -13,111 +18,136 @@ import (
 	"time"
 )
 
+var OS = runtime.GOOS
+
+// icmpRequest ICMP请求的构造方式与对应的应答类型
+type icmpRequest struct {
+	probe string
+	make  func(host string) []byte
+	reply icmp.Type
+}
+
 var (
-	AliveHosts []string
-	OS         = runtime.GOOS
-	ExistHosts = make(map[string]struct{})
-	livewg     sync.WaitGroup
+	icmpEcho      = icmpRequest{ProbeICMPEcho, makemsg, ipv4.ICMPTypeEchoReply}
+	icmpTimestamp = icmpRequest{ProbeICMPTimestamp, makeTimestamp, ipv4.ICMPTypeTimestampReply}
 )
 
-func IsContain(items []string, item string) bool {
-	for _, eachItem := range items {
-		if eachItem == item {
-			return true
+// icmpSweep 对hosts发送一种ICMP请求，IPv6只支持Echo
+func (r *discoveryRun) icmpSweep(hosts []string, request icmpRequest) {
+	var hosts4, hosts6 []string
+	for _, host := range hosts {
+		if utils.IsIPv6(host) {
+			hosts6 = append(hosts6, host)
+		} else {
+			hosts4 = append(hosts4, host)
 		}
 	}
-	return false
+	if len(hosts4) > 0 {
+		r.checkLiveIPv4(hosts4, request)
+	}
+	if len(hosts6) > 0 && request.probe == ProbeICMPEcho {
+		r.checkLiveIPv6(hosts6)
+	}
 }
 
-func CheckLive(hostslist []string, Ping bool) []string {
-	chanHosts := make(chan string, len(hostslist))
-	go func() {
-		for ip := range chanHosts {
-			if _, ok := ExistHosts[ip]; !ok && IsContain(hostslist, ip) {
-				ExistHosts[ip] = struct{}{}
-				gologger.Silent().Msgf("[ICMP-Alive] %v", ip)
-				AliveHosts = append(AliveHosts, ip)
-			}
-			livewg.Done()
-		}
-	}()
-
-	if Ping == true {
+func (r *discoveryRun) checkLiveIPv4(hostslist []string, request icmpRequest) {
+	//优先尝试监听本地icmp,批量探测
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err == nil {
+		r.runIcmpListen(hostslist, conn, 1, request.make, request.reply, request.probe)
+		return
+	}
+	//尝试无监听icmp探测
+	testConn, err := net.DialTimeout("ip4:icmp", "127.0.0.1", 6*time.Second)
+	if testConn != nil {
+		testConn.Close()
+	}
+	if err == nil {
+		r.runIcmpDial(hostslist, request)
+	} else if request.probe == ProbeICMPEcho {
+		gologger.Error().Msgf("尝试ICMP探测失败，转为Ping探测存活")
 		//使用ping探测
-		RunPing(hostslist, chanHosts)
+		r.runPing(hostslist)
 	} else {
-		//优先尝试监听本地icmp,批量探测
-		conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-		if err == nil {
-			RunIcmp1(hostslist, conn, chanHosts)
-		} else {
-			//尝试无监听icmp探测
-			conn, err := net.DialTimeout("ip4:icmp", "127.0.0.1", 6*time.Second)
-			defer func() {
-				if conn != nil {
-					conn.Close()
-				}
-			}()
-			if err == nil {
-				RunIcmp2(hostslist, chanHosts)
-			} else {
-				gologger.Error().Msgf("尝试ICMP探测失败，转为Ping探测存活")
-				//使用ping探测
-				RunPing(hostslist, chanHosts)
-			}
-		}
+		gologger.Error().Msgf("ICMP时间戳探测需要root或CAP_NET_RAW权限，跳过")
 	}
+}
 
-	livewg.Wait()
-	close(chanHosts)
-
-	return AliveHosts
+func (r *discoveryRun) checkLiveIPv6(hostslist []string) {
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err == nil {
+		r.runIcmpListen(hostslist, conn, ipv6.ICMPTypeEchoReply.Protocol(), makemsg6,
+			ipv6.ICMPTypeEchoReply, ProbeICMPEcho)
+		return
+	}
+	gologger.Error().Msgf("尝试ICMPv6探测失败，转为Ping探测存活")
+	r.runPing(hostslist)
 }
 
-func RunIcmp1(hostslist []string, conn *icmp.PacketConn, chanHosts chan string) {
-	endflag := false
+// runIcmpListen 通过监听的ICMP套接字批量发送请求，只统计对应类型的应答，全部应答或超时后结束
+func (r *discoveryRun) runIcmpListen(hostslist []string, conn *icmp.PacketConn, proto int,
+	request func(host string) []byte, reply icmp.Type, probe string) {
+	var sent sync.Map
+	stop := make(chan struct{})
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
+		msg := make([]byte, 1500)
 		for {
-			if endflag == true {
-				return
+			n, sourceIP, err := conn.ReadFrom(msg)
+			if err != nil {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				continue
+			}
+			message, err := icmp.ParseMessage(proto, msg[:n])
+			if err != nil || message.Type != reply {
+				continue
 			}
-			msg := make([]byte, 100)
-			_, sourceIP, _ := conn.ReadFrom(msg)
-			if sourceIP != nil {
-				livewg.Add(1)
-				chanHosts <- sourceIP.String()
+			ip := sourceIP.String()
+			if ipAddr, ok := sourceIP.(*net.IPAddr); ok {
+				ip = ipAddr.IP.String()
 			}
+			var elapsed time.Duration
+			if value, ok := sent.Load(ip); ok {
+				elapsed = time.Since(value.(time.Time))
+			}
+			r.found(ip, probe, elapsed)
 		}
 	}()
 
+	network := "ip4"
+	if proto != 1 {
+		network = "ip6"
+	}
 	for _, host := range hostslist {
-		dst, _ := net.ResolveIPAddr("ip", host)
-		IcmpByte := makemsg(host)
-		conn.WriteTo(IcmpByte, dst)
+		dst, err := net.ResolveIPAddr(network, host)
+		if err != nil {
+			continue
+		}
+		sent.Store(dst.IP.String(), time.Now())
+		conn.WriteTo(request(host), dst)
 	}
+
 	//根据hosts数量修改icmp监听时间
-	start := time.Now()
-	for {
-		if len(AliveHosts) == len(hostslist) {
-			break
-		}
-		since := time.Now().Sub(start)
-		var wait time.Duration
-		switch {
-		case len(hostslist) <= 256:
-			wait = time.Second * 3
-		default:
-			wait = time.Second * 6
-		}
-		if since > wait {
-			break
-		}
+	wait := time.Second * 3
+	if len(hostslist) > 256 {
+		wait = time.Second * 6
 	}
-	endflag = true
+	r.wait(hostslist, wait)
+	close(stop)
 	conn.Close()
+	<-done
 }
 
-func RunIcmp2(hostslist []string, chanHosts chan string) {
+// runIcmpDial 无法监听时逐个连接发送ICMP请求
+func (r *discoveryRun) runIcmpDial(hostslist []string, request icmpRequest) {
 	num := 1000
 	if len(hostslist) < num {
 		num = len(hostslist)
@@ -128,9 +158,8 @@ func RunIcmp2(hostslist []string, chanHosts chan string) {
 		wg.Add(1)
 		limiter <- struct{}{}
 		go func(host string) {
-			if icmpalive(host) {
-				livewg.Add(1)
-				chanHosts <- host
+			if elapsed, ok := icmpalive(host, request.make(host)); ok {
+				r.found(host, request.probe, elapsed)
 			}
 			<-limiter
 			wg.Done()
@@ -140,7 +169,7 @@ func RunIcmp2(hostslist []string, chanHosts chan string) {
 	close(limiter)
 }
 
-func icmpalive(host string) bool {
+func icmpalive(host string, msg []byte) (time.Duration, bool) {
 	startTime := time.Now()
 	conn, err := net.DialTimeout("ip4:icmp", host, 6*time.Second)
 	defer func() {
@@ -149,25 +178,23 @@ func icmpalive(host string) bool {
 		}
 	}()
 	if err != nil {
-		return false
+		return 0, false
 	}
 	if err := conn.SetDeadline(startTime.Add(6 * time.Second)); err != nil {
-		return false
+		return 0, false
 	}
-	msg := makemsg(host)
 	if _, err := conn.Write(msg); err != nil {
-		return false
+		return 0, false
 	}
 
 	receive := make([]byte, 60)
 	if _, err := conn.Read(receive); err != nil {
-		return false
+		return 0, false
 	}
-
-	return true
+	return time.Since(startTime), true
 }
 
-func RunPing(hostslist []string, chanHosts chan string) {
+func (r *discoveryRun) runPing(hostslist []string) {
 	var bsenv = ""
 	if OS != "windows" {
 		bsenv = "/bin/bash"
@@ -179,8 +206,7 @@ func RunPing(hostslist []string, chanHosts chan string) {
 		limiter <- struct{}{}
 		go func(host string) {
 			if ExecCommandPing(host, bsenv) {
-				livewg.Add(1)
-				chanHosts <- host
+				r.found(host, ProbePing, 0)
 			}
 			<-limiter
 			wg.Done()
@@ -196,7 +222,11 @@ func ExecCommandPing(ip string, bsenv string) bool {
 	} else if OS == "linux" {
 		command = exec.Command(bsenv, "-c", "ping -c 1 -w 1 "+ip+" >/dev/null && echo true || echo false") //ping -c 1 -i 0.5 -t 4 -W 2 -w 5 "+ip+" >/dev/null && echo true || echo false"
 	} else if OS == "darwin" {
-		command = exec.Command(bsenv, "-c", "ping -c 1 -W 1 "+ip+" >/dev/null && echo true || echo false") //ping -c 1 -i 0.5 -t 4 -W 2 -w 5 "+ip+" >/dev/null && echo true || echo false"
+		ping := "ping"
+		if utils.IsIPv6(ip) {
+			ping = "ping6"
+		}
+		command = exec.Command(bsenv, "-c", ping+" -c 1 -W 1 "+ip+" >/dev/null && echo true || echo false") //ping -c 1 -i 0.5 -t 4 -W 2 -w 5 "+ip+" >/dev/null && echo true || echo false"
 	}
 	outinfo := bytes.Buffer{}
 	command.Stdout = &outinfo
@@ -230,6 +260,33 @@ func makemsg(host string) []byte {
 	return msg
 }
 
+// makemsg6 ICMPv6 Echo请求，校验和由内核计算
+func makemsg6(host string) []byte {
+	id0, id1 := genIdentifier(host)
+	request := icmp.Message{
+		Type: ipv6.ICMPTypeEchoRequest,
+		Body: &icmp.Echo{ID: int(id0)<<8 | int(id1), Seq: 1, Data: make([]byte, 32)},
+	}
+	msg, _ := request.Marshal(nil)
+	return msg
+}
+
+// makeTimestamp ICMP时间戳请求，部分只拦截Echo的防火墙会放行
+func makeTimestamp(host string) []byte {
+	msg := make([]byte, 20)
+	id0, id1 := genIdentifier(host)
+	msg[0] = 13
+	msg[4], msg[5] = id0, id1
+	msg[6], msg[7] = genSequence(1)
+	// 发起时间戳为UTC零点以来的毫秒数
+	now := time.Now().UTC()
+	binary.BigEndian.PutUint32(msg[8:], uint32(now.Sub(now.Truncate(24*time.Hour))/time.Millisecond))
+	check := checkSum(msg)
+	msg[2] = byte(check >> 8)
+	msg[3] = byte(check & 255)
+	return msg
+}
+
 func checkSum(msg []byte) uint16 {
 	sum := 0
 	length := len(msg)
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"dddd/structs"
+	"dddd/utils"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"os"
+	"strings"
+)
+
+// ImportServices 从文件导入端口服务，每行格式为以下之一
+//
+//	ssh://192.168.0.1:22
+//	192.168.0.1:22 ssh
+//	192.168.0.1:22
+//	http://example.com/
+//
+// 带协议的服务直接写入IPPortMap，不带协议的端口交由协议识别阶段处理，URL作为Web探测目标
+func ImportServices(scan *structs.Scan, state *structs.CheckpointState, fileName string) error {
+	fileBytes, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("读取导入的服务文件失败: %v", err)
+	}
+
+	var services, ipPorts, urls int
+	content := strings.ReplaceAll(string(fileBytes), "\r\n", "\n")
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		hostPort, protocol := line, ""
+		if strings.Contains(line, "://") {
+			t := strings.SplitN(line, "://", 2)
+			protocol, hostPort = strings.ToLower(t[0]), t[1]
+			// 带路径或不带端口的视为URL
+			if (protocol == "http" || protocol == "https") && isWebURL(hostPort) {
+				if scan.IsExcluded(line) {
+					continue
+				}
+				state.URLs = append(state.URLs, line)
+				urls++
+				continue
+			}
+			hostPort = strings.TrimSuffix(hostPort, "/")
+		} else if fields := strings.Fields(line); len(fields) == 2 {
+			hostPort, protocol = fields[0], strings.ToLower(fields[1])
+		}
+
+		if scan.IsExcluded(hostPort) {
+			continue
+		}
+		inputType := utils.GetInputType(hostPort)
+		if inputType != structs.TypeIPPort && inputType != structs.TypeDomainPort {
+			gologger.Error().Msgf("不支持的格式: %s", line)
+			continue
+		}
+
+		if protocol == "" {
+			if inputType == structs.TypeIPPort {
+				state.IPPort = append(state.IPPort, hostPort)
+			} else {
+				state.DomainPort = append(state.DomainPort, hostPort)
+			}
+			ipPorts++
+			continue
+		}
+
+		scan.IPPortMapLock.Lock()
+		scan.IPPortMap[hostPort] = protocol
+		scan.IPPortMapLock.Unlock()
+		services++
+	}
+
+	state.IPPort = utils.RemoveDuplicateElement(state.IPPort)
+	state.DomainPort = utils.RemoveDuplicateElement(state.DomainPort)
+	state.URLs = utils.RemoveDuplicateElement(state.URLs)
+	gologger.Info().Msgf("已导入服务 %d 个，待识别端口 %d 个，URL %d 个", services, ipPorts, urls)
+	return nil
+}
+
+// isWebURL 去掉协议后的http/https目标带有路径或不带端口时作为URL导入
+func isWebURL(hostPort string) bool {
+	hostPort = strings.TrimSuffix(hostPort, "/")
+	if strings.Contains(hostPort, "/") {
+		return true
+	}
+	_, _, err := net.SplitHostPort(hostPort)
+	return err != nil
+}
@@ -0,0 +1,361 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"dddd/common/report"
+	"dddd/lib/masscan"
+	"dddd/structs"
+	"dddd/utils"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/lcvvvv/gonmap"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 支持导入的端口扫描结果格式
+const (
+	ScanFormatNmapXML     = "nmap-xml"
+	ScanFormatMasscanXML  = "masscan-xml"
+	ScanFormatMasscanJSON = "masscan-json"
+	ScanFormatMasscanList = "masscan-list"
+	ScanFormatNaabuJSON   = "naabu-json"
+)
+
+// DetectScanFormat 根据文件内容判断是否为端口扫描工具的输出，普通目标列表返回空
+func DetectScanFormat(content []byte) string {
+	content = bytes.TrimSpace(content)
+	switch {
+	case bytes.HasPrefix(content, []byte("<?xml")) || bytes.HasPrefix(content, []byte("<nmaprun")):
+		head := content
+		if len(head) > 4096 {
+			head = head[:4096]
+		}
+		if bytes.Contains(head, []byte(`scanner="masscan"`)) {
+			return ScanFormatMasscanXML
+		}
+		if bytes.Contains(head, []byte("<nmaprun")) {
+			return ScanFormatNmapXML
+		}
+	case bytes.HasPrefix(content, []byte("#masscan")) || bytes.HasPrefix(content, []byte("open tcp ")):
+		return ScanFormatMasscanList
+	case bytes.HasPrefix(content, []byte("[")) || bytes.HasPrefix(content, []byte("{")):
+		line := content
+		if i := bytes.IndexByte(content, '\n'); i > 0 {
+			line = content[:i]
+		}
+		// masscan -oJ 首行为"["，-oD 每行一个带ports数组的对象
+		if bytes.Equal(bytes.TrimSpace(line), []byte("[")) || bytes.Contains(line, []byte(`"ports"`)) {
+			return ScanFormatMasscanJSON
+		}
+		if bytes.Contains(line, []byte(`"port"`)) {
+			return ScanFormatNaabuJSON
+		}
+	}
+	return ""
+}
+
+// scannedPort 从扫描结果中解析出的开放端口，Service为空时交由协议识别
+type scannedPort struct {
+	IP      string
+	Port    int
+	Service string
+	Record  structs.ServiceRecord
+}
+
+// ImportScanResults 导入nmap、masscan、naabu的端口扫描结果
+// nmap已识别的服务直接写入IPPortMap，其余开放端口进入协议识别阶段
+func ImportScanResults(scan *structs.Scan, state *structs.CheckpointState, fileName string) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("读取端口扫描结果失败: %v", err)
+	}
+
+	format := DetectScanFormat(content)
+	var ports []scannedPort
+	switch format {
+	case ScanFormatNmapXML:
+		ports, err = parseNmapXML(content)
+	case ScanFormatMasscanXML:
+		ports, err = parseMasscanXML(content)
+	case ScanFormatMasscanJSON:
+		ports, err = parseMasscanJSON(content)
+	case ScanFormatMasscanList:
+		ports = parseMasscanList(content)
+	case ScanFormatNaabuJSON:
+		ports, err = parseNaabuJSON(content)
+	default:
+		err = fmt.Errorf("无法识别的格式")
+	}
+	if err != nil {
+		return fmt.Errorf("解析端口扫描结果 %s 失败: %v", fileName, err)
+	}
+
+	var services, ipPorts int
+	for _, each := range ports {
+		hostPort := net.JoinHostPort(each.IP, strconv.Itoa(each.Port))
+		if scan.IsExcluded(hostPort) {
+			continue
+		}
+		if each.Service == "" {
+			switch utils.GetInputType(hostPort) {
+			case structs.TypeIPPort:
+				state.IPPort = append(state.IPPort, hostPort)
+			case structs.TypeDomainPort:
+				state.DomainPort = append(state.DomainPort, hostPort)
+			default:
+				continue
+			}
+			ipPorts++
+			continue
+		}
+
+		key, prefix := hostPort, ""
+		if each.Record.Transport == "udp" {
+			key, prefix = structs.UDPServiceKey(hostPort), structs.UDPServicePrefix
+		}
+		scan.IPPortMapLock.Lock()
+		scan.IPPortMap[key] = each.Service
+		scan.IPPortMapLock.Unlock()
+		detail := structs.ServiceFingerprint{
+			ProductName:     each.Record.ProductName,
+			Version:         each.Record.Version,
+			Info:            each.Record.Info,
+			Hostname:        each.Record.Hostname,
+			OperatingSystem: each.Record.OperatingSystem,
+			DeviceType:      each.Record.DeviceType,
+			CPE:             each.Record.CPE,
+		}
+		scan.SetServiceFingerprint(key, detail)
+		logService(prefix+each.Service+"://"+hostPort, detail)
+		report.AddRecord(scan, report.RecordService, each.Record)
+		services++
+	}
+	state.IPPort = utils.RemoveDuplicateElement(state.IPPort)
+	state.DomainPort = utils.RemoveDuplicateElement(state.DomainPort)
+	gologger.Info().Msgf("已从%s导入服务 %d 个，待识别端口 %d 个", format, services, ipPorts)
+	return nil
+}
+
+type nmapRun struct {
+	Hosts []nmapHost `xml:"host"`
+}
+
+type nmapHost struct {
+	Addresses []masscan.Address `xml:"address"`
+	Ports     []nmapPort        `xml:"ports>port"`
+}
+
+type nmapPort struct {
+	Protocol string        `xml:"protocol,attr"`
+	PortID   int           `xml:"portid,attr"`
+	State    masscan.State `xml:"state"`
+	Service  nmapService   `xml:"service"`
+}
+
+type nmapService struct {
+	Name       string   `xml:"name,attr"`
+	Product    string   `xml:"product,attr"`
+	Version    string   `xml:"version,attr"`
+	ExtraInfo  string   `xml:"extrainfo,attr"`
+	Hostname   string   `xml:"hostname,attr"`
+	OSType     string   `xml:"ostype,attr"`
+	DeviceType string   `xml:"devicetype,attr"`
+	Tunnel     string   `xml:"tunnel,attr"`
+	Method     string   `xml:"method,attr"`
+	CPE        []string `xml:"cpe"`
+}
+
+// nmapServiceName 只采用nmap实际探测得到的服务名，按端口号猜测的服务仍需重新识别
+func nmapServiceName(service nmapService) string {
+	name := strings.TrimSuffix(service.Name, "?")
+	if service.Method != "probed" || name != service.Name {
+		return ""
+	}
+	switch name {
+	case "", "unknown", "tcpwrapped", "ssl":
+		return ""
+	}
+	if service.Tunnel == "ssl" {
+		name = "ssl/" + name
+	}
+	return gonmap.FixProtocol(name)
+}
+
+func parseNmapXML(content []byte) ([]scannedPort, error) {
+	var run nmapRun
+	if err := xml.Unmarshal(content, &run); err != nil {
+		return nil, err
+	}
+
+	var results []scannedPort
+	for _, host := range run.Hosts {
+		var ip string
+		for _, address := range host.Addresses {
+			if address.AddrType == "ipv4" || address.AddrType == "ipv6" {
+				ip = address.Addr
+				break
+			}
+		}
+		if ip == "" {
+			continue
+		}
+		for _, port := range host.Ports {
+			if (port.Protocol != "tcp" && port.Protocol != "udp") || port.State.State != "open" {
+				continue
+			}
+			service := nmapServiceName(port.Service)
+			transport := ""
+			if port.Protocol == "udp" {
+				// UDP端口不经过协议识别，只导入已识别的服务
+				if service == "" {
+					continue
+				}
+				transport = "udp"
+			}
+			results = append(results, scannedPort{
+				IP:      ip,
+				Port:    port.PortID,
+				Service: service,
+				Record: structs.ServiceRecord{
+					IP:              ip,
+					Port:            port.PortID,
+					Service:         service,
+					ProductName:     port.Service.Product,
+					Version:         port.Service.Version,
+					Info:            port.Service.ExtraInfo,
+					Hostname:        port.Service.Hostname,
+					OperatingSystem: port.Service.OSType,
+					DeviceType:      port.Service.DeviceType,
+					CPE:             port.Service.CPE,
+					TLS:             port.Service.Tunnel == "ssl",
+					Transport:       transport,
+				},
+			})
+		}
+	}
+	return results, nil
+}
+
+func parseMasscanXML(content []byte) ([]scannedPort, error) {
+	ms := masscan.Masscan{Result: content}
+	hosts, err := ms.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []scannedPort
+	for _, host := range hosts {
+		for _, port := range host.Ports {
+			portID, err := strconv.Atoi(port.Portid)
+			if err != nil || port.Protocol != "tcp" || port.State.State != "open" {
+				continue
+			}
+			results = append(results, scannedPort{IP: host.Address.Addr, Port: portID})
+		}
+	}
+	return results, nil
+}
+
+// masscanJSONHost masscan -oJ/-oD 的一条记录
+type masscanJSONHost struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port   int    `json:"port"`
+		Proto  string `json:"proto"`
+		Status string `json:"status"`
+	} `json:"ports"`
+}
+
+// parseMasscanJSON 逐行解析，兼容masscan -oJ 末尾多余逗号的输出
+func parseMasscanJSON(content []byte) ([]scannedPort, error) {
+	var results []scannedPort
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(strings.TrimSpace(scanner.Text()), ",")
+		if line == "" || line == "[" || line == "]" {
+			continue
+		}
+		var host masscanJSONHost
+		if err := json.Unmarshal([]byte(line), &host); err != nil {
+			return nil, err
+		}
+		for _, port := range host.Ports {
+			if port.Proto != "tcp" || (port.Status != "" && port.Status != "open") {
+				continue
+			}
+			results = append(results, scannedPort{IP: host.IP, Port: port.Port})
+		}
+	}
+	return results, scanner.Err()
+}
+
+// parseMasscanList 解析masscan -oL，每行: open tcp 80 192.168.0.1 1700000000
+func parseMasscanList(content []byte) []scannedPort {
+	var results []scannedPort
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "open" || fields[1] != "tcp" {
+			continue
+		}
+		port, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		results = append(results, scannedPort{IP: fields[3], Port: port})
+	}
+	return results
+}
+
+// naabuResult naabu -json 的一行，旧版本的port为对象
+type naabuResult struct {
+	Host     string          `json:"host"`
+	IP       string          `json:"ip"`
+	Port     json.RawMessage `json:"port"`
+	Protocol interface{}     `json:"protocol"`
+}
+
+func parseNaabuJSON(content []byte) ([]scannedPort, error) {
+	var results []scannedPort
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var result naabuResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			return nil, err
+		}
+		if protocol, ok := result.Protocol.(string); ok && protocol != "tcp" {
+			continue
+		}
+
+		var port int
+		if err := json.Unmarshal(result.Port, &port); err != nil {
+			var legacy struct {
+				Port int `json:"Port"`
+			}
+			if err := json.Unmarshal(result.Port, &legacy); err != nil {
+				continue
+			}
+			port = legacy.Port
+		}
+		ip := result.IP
+		if ip == "" {
+			ip = result.Host
+		}
+		if ip == "" || port == 0 {
+			continue
+		}
+		results = append(results, scannedPort{IP: ip, Port: port})
+	}
+	return results, scanner.Err()
+}
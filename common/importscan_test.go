@@ -0,0 +1,164 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+const nmapXMLSample = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE nmaprun>
+<nmaprun scanner="nmap" args="nmap -sV 192.168.0.1" start="1700000000" version="7.94">
+<host>
+<address addr="192.168.0.1" addrtype="ipv4"/>
+<address addr="00:11:22:33:44:55" addrtype="mac"/>
+<ports>
+<port protocol="tcp" portid="22"><state state="open" reason="syn-ack"/><service name="ssh" product="OpenSSH" version="8.9p1" method="probed" conf="10"><cpe>cpe:/a:openbsd:openssh:8.9p1</cpe></service></port>
+<port protocol="tcp" portid="443"><state state="open" reason="syn-ack"/><service name="http" tunnel="ssl" method="probed" conf="10"/></port>
+<port protocol="tcp" portid="8080"><state state="open" reason="syn-ack"/><service name="http-proxy" method="table" conf="3"/></port>
+<port protocol="tcp" portid="9999"><state state="open" reason="syn-ack"/><service name="abyss?" method="probed" conf="3"/></port>
+<port protocol="tcp" portid="3306"><state state="closed" reason="reset"/><service name="mysql" method="probed" conf="10"/></port>
+<port protocol="udp" portid="53"><state state="open" reason="udp-response"/><service name="domain" method="probed" conf="10"/></port>
+<port protocol="udp" portid="161"><state state="open" reason="udp-response"/><service name="snmp" method="table" conf="3"/></port>
+</ports>
+</host>
+<host>
+<address addr="00:11:22:33:44:66" addrtype="mac"/>
+<ports><port protocol="tcp" portid="80"><state state="open"/></port></ports>
+</host>
+</nmaprun>`
+
+const masscanXMLSample = `<?xml version="1.0"?>
+<nmaprun scanner="masscan" start="1700000000" version="1.0-BETA" xmloutputversion="1.03">
+<host endtime="1700000000"><address addr="10.0.0.1" addrtype="ipv4"/><ports><port protocol="tcp" portid="80"><state state="open" reason="syn-ack" reason_ttl="64"/></port></ports></host>
+<host endtime="1700000000"><address addr="10.0.0.2" addrtype="ipv4"/><ports><port protocol="tcp" portid="443"><state state="open" reason="syn-ack" reason_ttl="64"/></port></ports></host>
+<runstats><finished time="1700000001" timestr="2023-11-14 22:13:21" elapsed="1" /></runstats>
+</nmaprun>`
+
+const masscanJSONSample = `[
+{   "ip": "10.0.0.1",   "timestamp": "1700000000", "ports": [ {"port": 80, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "10.0.0.2",   "timestamp": "1700000000", "ports": [ {"port": 53, "proto": "udp", "status": "open"} ] },
+{   "ip": "10.0.0.3",   "timestamp": "1700000000", "ports": [ {"port": 22, "proto": "tcp", "status": "open"} ] },
+]`
+
+const masscanListSample = `#masscan
+open tcp 80 10.0.0.1 1700000000
+open udp 53 10.0.0.2 1700000000
+open tcp 22 10.0.0.3 1700000000
+# end
+`
+
+const naabuJSONSample = `{"host":"example.com","ip":"93.184.216.34","port":443,"protocol":"tcp","timestamp":"2023-11-14T22:13:20Z"}
+{"ip":"10.0.0.1","port":{"Port":8080,"Protocol":0,"TLS":false},"timestamp":"2023-11-14T22:13:20Z"}
+{"host":"10.0.0.2","port":53,"protocol":"udp"}
+{"host":"10.0.0.3","port":0}
+`
+
+func TestDetectScanFormat(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{nmapXMLSample, ScanFormatNmapXML},
+		{"<nmaprun scanner=\"nmap\"></nmaprun>", ScanFormatNmapXML},
+		{masscanXMLSample, ScanFormatMasscanXML},
+		{masscanJSONSample, ScanFormatMasscanJSON},
+		{`{"ip": "10.0.0.1", "ports": [{"port": 80}]}`, ScanFormatMasscanJSON},
+		{masscanListSample, ScanFormatMasscanList},
+		{"open tcp 80 10.0.0.1 1700000000\n", ScanFormatMasscanList},
+		{naabuJSONSample, ScanFormatNaabuJSON},
+		{"\n  192.168.0.1\n192.168.0.0/24\nexample.com\n", ""},
+		{"http://example.com/", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectScanFormat([]byte(tt.content)); got != tt.want {
+			t.Errorf("DetectScanFormat(%.30q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+// portsOf 只比较IP、端口与服务
+func portsOf(ports []scannedPort) []scannedPort {
+	var results []scannedPort
+	for _, port := range ports {
+		results = append(results, scannedPort{IP: port.IP, Port: port.Port, Service: port.Service})
+	}
+	return results
+}
+
+func TestParseNmapXML(t *testing.T) {
+	ports, err := parseNmapXML([]byte(nmapXMLSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []scannedPort{
+		{IP: "192.168.0.1", Port: 22, Service: "ssh"},
+		{IP: "192.168.0.1", Port: 443, Service: "https"},
+		{IP: "192.168.0.1", Port: 8080},
+		{IP: "192.168.0.1", Port: 9999},
+		{IP: "192.168.0.1", Port: 53, Service: "dns"},
+	}
+	if got := portsOf(ports); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseNmapXML = %+v, want %+v", got, want)
+	}
+
+	ssh := ports[0].Record
+	if ssh.ProductName != "OpenSSH" || ssh.Version != "8.9p1" ||
+		!reflect.DeepEqual(ssh.CPE, []string{"cpe:/a:openbsd:openssh:8.9p1"}) {
+		t.Errorf("ssh服务详情 = %+v", ssh)
+	}
+	if !ports[1].Record.TLS {
+		t.Errorf("443端口应标记为TLS")
+	}
+	if ports[4].Record.Transport != "udp" {
+		t.Errorf("53端口应为UDP服务")
+	}
+
+	if _, err := parseNmapXML([]byte("<nmaprun><host>")); err == nil {
+		t.Errorf("不完整的XML应返回错误")
+	}
+}
+
+func TestParseMasscanXML(t *testing.T) {
+	ports, err := parseMasscanXML([]byte(masscanXMLSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []scannedPort{{IP: "10.0.0.1", Port: 80}, {IP: "10.0.0.2", Port: 443}}
+	if got := portsOf(ports); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMasscanXML = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseMasscanJSON(t *testing.T) {
+	ports, err := parseMasscanJSON([]byte(masscanJSONSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []scannedPort{{IP: "10.0.0.1", Port: 80}, {IP: "10.0.0.3", Port: 22}}
+	if got := portsOf(ports); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMasscanJSON = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseMasscanJSON([]byte("[\n{\"ip\": \n]")); err == nil {
+		t.Errorf("不完整的JSON应返回错误")
+	}
+}
+
+func TestParseMasscanList(t *testing.T) {
+	want := []scannedPort{{IP: "10.0.0.1", Port: 80}, {IP: "10.0.0.3", Port: 22}}
+	if got := portsOf(parseMasscanList([]byte(masscanListSample))); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMasscanList = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseNaabuJSON(t *testing.T) {
+	ports, err := parseNaabuJSON([]byte(naabuJSONSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []scannedPort{{IP: "93.184.216.34", Port: 443}, {IP: "10.0.0.1", Port: 8080}}
+	if got := portsOf(ports); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNaabuJSON = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"dddd/common/ratelimit"
+	"dddd/common/report"
+	"dddd/common/rtt"
+	"dddd/config"
+	"dddd/lib/ddfinger"
+	"dddd/structs"
+	"dddd/utils"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"strings"
+)
+
+// InitRuntime 初始化进程内所有扫描共享的配置目录、数据库、代理、SSH跳板与限速，与CloseRuntime成对调用
+func InitRuntime(c structs.Config) error {
+	rtt.Reset()
+	ratelimit.Init(c.RateLimit, c.HostRateLimit, c.HostConcurrency)
+	if ratelimit.Enabled() {
+		gologger.Info().Msgf("限速: 全局 %d/s 单主机 %d/s 单主机并发 %d (0为不限制)",
+			c.RateLimit, c.HostRateLimit, c.HostConcurrency)
+	}
+
+	err := InitProxy(c.TCPProxy)
+	if err != nil {
+		return fmt.Errorf("代理参数(-tcp-proxy)错误: %v", err)
+	}
+	if c.SSHJump != "" {
+		if err = InitSSHJump(c); err != nil {
+			return fmt.Errorf("SSH跳板(-ssh-jump)连接失败: %v", err)
+		}
+	}
+
+	err = config.Init(c.ConfigDir)
+	if err != nil {
+		CloseRuntime()
+		return err
+	}
+	gologger.Info().Msgf("配置目录: %s", config.Source())
+
+	if err = loadDatabases(); err != nil {
+		CloseRuntime()
+		return err
+	}
+	return nil
+}
+
+// loadDatabases 从配置目录加载探针、指纹、工作流、主动指纹与Shiro key
+func loadDatabases() error {
+	if err := LoadServiceProbes(); err != nil {
+		return fmt.Errorf("自定义探针加载失败: %v", err)
+	}
+
+	fingerprints, err := ddfinger.ParseFingerYaml()
+	if err != nil {
+		return err
+	}
+	structs.FingerprintDB = fingerprints
+	if len(structs.FingerprintDB) == 0 {
+		return errors.New("请检查指纹数据库是否正常。")
+	}
+	gologger.Info().Msgf("YAML指纹数据: %d 条\n", len(structs.FingerprintDB))
+
+	if err := ReadWorkFlowDB(); err != nil {
+		return err
+	}
+	if len(structs.WorkFlowDB) == 0 {
+		return errors.New("请检查工作流数据库是否正常。")
+	}
+	gologger.Info().Msgf("漏洞检测支持指纹: %d 条", len(structs.WorkFlowDB))
+
+	ReadDirDB()
+	if len(structs.DirDB) == 0 {
+		return errors.New("请检查主动指纹探测数据库是否正常。")
+	}
+
+	d, errR := config.ReadFile("dict/shirokeys.txt")
+	if errR != nil {
+		return errors.New("请检查配置目录下的dict/shirokeys.txt是否存在。")
+	}
+	dStr := strings.ReplaceAll(string(d), "\r", "")
+	structs.ShiroKeys = strings.Split(dStr, "\n")
+	if len(structs.ShiroKeys) == 0 {
+		return errors.New("Shiro key为空")
+	}
+	return nil
+}
+
+// CloseRuntime 关闭SSH跳板并删除配置目录释放的临时文件
+func CloseRuntime() {
+	closeSSHJump()
+	config.Cleanup()
+}
+
+// NewScan 创建一次扫描的资产状态，InitRuntime之后调用，缓存数据库由LoadCheckpoint在断点目录中打开，扫描结束后调用CloseScan
+func NewScan(c structs.Config) (*structs.Scan, error) {
+	scan := structs.NewScan(c)
+
+	exclude, err := utils.NewExcludeScope(c.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	if exclude != nil {
+		scan.Exclude = exclude
+	}
+
+	profiles, err := LoadPortProfiles()
+	if err != nil {
+		return nil, err
+	}
+	ports, err := ResolvePorts(c.Ports, profiles)
+	if err != nil {
+		return nil, fmt.Errorf("端口参数(-p)错误: %v", err)
+	}
+	scan.Config.Ports = ports
+
+	if tcpProxy != nil {
+		proxyNotice(scan)
+	}
+	return scan, nil
+}
+
+// CloseScan 关闭缓存数据库与JSONL文件
+func CloseScan(scan *structs.Scan) {
+	closeCaches(scan)
+	report.Close(scan)
+}
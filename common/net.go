@@ -1,23 +1,72 @@
 package common
 
 import (
+	"context"
+	"dddd/common/ratelimit"
+	"github.com/lcvvvv/gonmap/simplenet"
+	"github.com/projectdiscovery/httpx/runner"
+	"github.com/projectdiscovery/nuclei/v3/pkg/protocols/common/protocolstate"
 	"net"
+	"strings"
 	"time"
 )
 
+func init() {
+	// gonmap协议识别的连接同样经过WrapperTCP
+	simplenet.Dial = WrapperTcpWithTimeout
+	// httpx与nuclei的请求共用限速
+	runner.RequestHook = ratelimit.Acquire
+	protocolstate.RequestHook = ratelimit.Acquire
+}
+
 func WrapperTcpWithTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
 	d := &net.Dialer{Timeout: timeout}
 	return WrapperTCP(network, address, d)
 }
 
 func WrapperTCP(network, address string, forward *net.Dialer) (net.Conn, error) {
-	//get conn
-	var conn net.Conn
-	var err error
-	conn, err = forward.Dial(network, address)
+	return wrapperTCPContext(context.Background(), network, address, forward)
+}
+
+func wrapperTCPContext(ctx context.Context, network, address string, forward *net.Dialer) (net.Conn, error) {
+	// 限速与单主机并发限制，连接关闭后归还名额
+	release := ratelimit.Acquire(address)
+
+	conn, err := dialContext(ctx, network, address, forward)
 	if err != nil {
+		release()
 		return nil, err
 	}
-	return conn, nil
+	return ratelimit.WrapConn(conn, release), nil
+
+}
+
+// dialContext 配置了代理时TCP连接经过代理，UDP无法代理，直接连接
+func dialContext(ctx context.Context, network, address string, forward *net.Dialer) (net.Conn, error) {
+	if tcpProxy != nil && strings.HasPrefix(network, "tcp") {
+		return tcpProxy.DialContext(ctx, network, address, forward)
+	}
+	return forward.DialContext(ctx, network, address)
+}
+
+// DialWithoutLimit 与WrapperTcpWithTimeout相同但不占用限速名额，供已自行获取名额的调用方建立额外连接
+func DialWithoutLimit(network, address string, timeout time.Duration) (net.Conn, error) {
+	return dialContext(context.Background(), network, address, &net.Dialer{Timeout: timeout})
+}
+
+// Dialer 供数据库等第三方驱动使用，连接统一经过WrapperTCP
+type Dialer struct {
+	Timeout time.Duration
+}
+
+func (d Dialer) Dial(network, address string) (net.Conn, error) {
+	return WrapperTcpWithTimeout(network, address, d.Timeout)
+}
+
+func (d Dialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	return WrapperTcpWithTimeout(network, address, timeout)
+}
 
+func (d Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return wrapperTCPContext(ctx, network, address, &net.Dialer{Timeout: d.Timeout})
 }
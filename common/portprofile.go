@@ -0,0 +1,172 @@
+package common
+
+import (
+	"dddd/config"
+	"errors"
+	"fmt"
+	"github.com/lcvvvv/gonmap"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// portProfileFile 配置目录下的端口组文件，其中的端口组覆盖同名的内置端口组
+const portProfileFile = "ports.yaml"
+
+// builtinPortProfiles 内置端口组，值与-p的格式相同，可引用其他端口组与top-N
+var builtinPortProfiles = map[string]string{
+	"default": PortTOP1000,
+	"all":     "1-65535",
+	"web": "80-90,443,591,593,800,801,808,880,888,1080,1443,2080,2443,3000,3001,3128,4443,4848,5000,5001,5601," +
+		"6443,7001,7002,7070,7080,7443,7777,8000-8100,8161,8180,8181,8200,8280,8443,8800,8848,8880,8888,8983," +
+		"9000-9010,9043,9060,9080,9090,9091,9200,9443,9999,10000,10443,18080,18081,28080,38080,50070",
+	"db": "1433,1521,1583,2100,2483,2484,3050,3306,3351,5432,5984,6379,7000,7474,7687,8086,8529,9042,9160," +
+		"9200,9300,11211,26257,27017,27018,27019,28017,33060,50000",
+	"remote-admin": "22,23,135,139,445,512-514,623,2222,2375,2376,3389,4899,5631,5632,5800,5900-5903,5938,5985," +
+		"5986,6000,6443,8291,10000,10250",
+	"iot": "23,80,81,443,554,1883,1900,2323,5000,5060,5683,7547,8000,8080,8081,8200,8443,8554,8883,8899," +
+		"9000,10554,34567,37215,37777,49152,52869",
+	"ics": "102,502,789,1089-1091,1911,1962,2222,2404,2455,4000,4840,4911,5006,5007,5094,9600,18245,18246," +
+		"20000,20547,44818,47808",
+}
+
+// LoadPortProfiles 返回内置端口组与配置目录下ports.yaml中的端口组，文件不存在时只返回内置端口组
+//
+//	ports.yaml 格式: 组名: 端口列表，如 mail: 25,110,143,465,587,993,995
+func LoadPortProfiles() (map[string]string, error) {
+	profiles := make(map[string]string, len(builtinPortProfiles))
+	for name, ports := range builtinPortProfiles {
+		profiles[name] = ports
+	}
+
+	data, err := config.ReadFile(portProfileFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return profiles, nil
+	} else if err != nil {
+		return nil, err
+	}
+	custom := make(map[string]interface{})
+	if err = yaml.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("%s解析失败: %v", portProfileFile, err)
+	}
+	for name, value := range custom {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch v := value.(type) {
+		case []interface{}:
+			var items []string
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			profiles[name] = strings.Join(items, ",")
+		case nil:
+			return nil, fmt.Errorf("%s中的端口组%s为空", portProfileFile, name)
+		default:
+			profiles[name] = fmt.Sprint(v)
+		}
+	}
+	return profiles, nil
+}
+
+// ResolvePorts 将-p的值展开为端口列表，支持端口、端口范围、端口组名与top-N(按nmap端口开放频率)组合，如 web,db,top-100,8000-8100
+// 返回排序去重后的端口字符串，连续的端口合并为范围
+func ResolvePorts(spec string, profiles map[string]string) (string, error) {
+	used := make(map[int]struct{})
+	err := expandPorts(spec, profiles, used, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(used) == 0 {
+		return "", errors.New("端口列表为空")
+	}
+	ports := make([]int, 0, len(used))
+	for port := range used {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return formatPorts(ports), nil
+}
+
+// expandPorts 展开spec中的端口，stack为正在展开的端口组，用于检查循环引用
+func expandPorts(spec string, profiles map[string]string, used map[int]struct{}, stack []string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		if ports, ok := profiles[item]; ok {
+			for _, name := range stack {
+				if name == item {
+					return fmt.Errorf("端口组循环引用: %s -> %s", strings.Join(stack, " -> "), item)
+				}
+			}
+			if err := expandPorts(ports, profiles, used, append(stack, item)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if strings.HasPrefix(item, "top-") {
+			n, err := strconv.Atoi(strings.TrimPrefix(item, "top-"))
+			if err != nil || n <= 0 {
+				return fmt.Errorf("无效的端口数量: %s", item)
+			}
+			for _, port := range gonmap.TopTCPPorts(n) {
+				used[port] = struct{}{}
+			}
+			continue
+		}
+
+		start, end, err := parsePortRange(item)
+		if err != nil {
+			if len(stack) > 0 {
+				return fmt.Errorf("端口组%s: %v", stack[len(stack)-1], err)
+			}
+			return err
+		}
+		for port := start; port <= end; port++ {
+			used[port] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// parsePortRange 解析单个端口或端口范围
+func parsePortRange(item string) (int, int, error) {
+	lower, upper := item, item
+	if index := strings.Index(item, "-"); index >= 0 {
+		lower, upper = item[:index], item[index+1:]
+	}
+	start, err1 := strconv.Atoi(strings.TrimSpace(lower))
+	end, err2 := strconv.Atoi(strings.TrimSpace(upper))
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("未知的端口或端口组: %s", item)
+	}
+	if start > end {
+		start, end = end, start
+	}
+	if start < 1 || end > 65535 {
+		return 0, 0, fmt.Errorf("端口超出范围(1-65535): %s", item)
+	}
+	return start, end, nil
+}
+
+// formatPorts 将有序端口列表格式化为逗号分隔的字符串，连续的端口合并为范围
+func formatPorts(ports []int) string {
+	var items []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j > i {
+			items = append(items, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		} else {
+			items = append(items, strconv.Itoa(ports[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolvePorts(t *testing.T) {
+	profiles := map[string]string{
+		"web":   "80,443,8080-8082",
+		"db":    "3306,6379",
+		"mixed": "web,db,22",
+	}
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"22", "22"},
+		{"9000-9002,22", "22,9000-9002"},
+		{"9002-9000", "9000-9002"},
+		{"web", "80,443,8080-8082"},
+		{" WEB , 81 ", "80-81,443,8080-8082"},
+		{"mixed,8083", "22,80,443,3306,6379,8080-8083"},
+		{"db,db,3306", "3306,6379"},
+	}
+	for _, tt := range tests {
+		got, err := ResolvePorts(tt.spec, profiles)
+		if err != nil {
+			t.Errorf("ResolvePorts(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ResolvePorts(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePortsTop(t *testing.T) {
+	used := make(map[int]struct{})
+	if err := expandPorts("top-10", nil, used, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(used) != 10 {
+		t.Errorf("top-10 展开为 %d 个端口", len(used))
+	}
+	if _, err := ResolvePorts("top-0", nil); err == nil {
+		t.Errorf("top-0 应返回错误")
+	}
+}
+
+func TestResolvePortsCycle(t *testing.T) {
+	profiles := map[string]string{
+		"a":    "80,b",
+		"b":    "443,c",
+		"c":    "a",
+		"self": "self",
+		"ok":   "b2,b2",
+		"b2":   "22",
+	}
+	for _, spec := range []string{"a", "c", "self"} {
+		_, err := ResolvePorts(spec, profiles)
+		if err == nil || !strings.Contains(err.Error(), "循环引用") {
+			t.Errorf("ResolvePorts(%q) error = %v, want 循环引用", spec, err)
+		}
+	}
+	// 同一端口组被多次引用不是循环
+	got, err := ResolvePorts("ok", profiles)
+	if err != nil || got != "22" {
+		t.Errorf("ResolvePorts(%q) = %q, %v", "ok", got, err)
+	}
+}
+
+func TestResolvePortsInvalid(t *testing.T) {
+	profiles := map[string]string{"bad": "80,unknown"}
+	for _, spec := range []string{"", ",", "0", "65536", "1-70000", "abc", "bad"} {
+		if _, err := ResolvePorts(spec, profiles); err == nil {
+			t.Errorf("ResolvePorts(%q) 应返回错误", spec)
+		}
+	}
+}
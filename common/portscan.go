@@ -2,16 +2,23 @@ package common
 
 import (
 	"bytes"
+	"dddd/common/progress"
+	"dddd/common/report"
+	"dddd/common/rtt"
+	"dddd/common/synscan"
 	"dddd/lib/masscan"
 	"dddd/structs"
 	"dddd/utils"
-	"fmt"
+	"errors"
 	"github.com/projectdiscovery/gologger"
+	"math/rand"
+	"net"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -52,48 +59,144 @@ func ParsePort(ports string) (scanPorts []int) {
 	return scanPorts
 }
 
-func PortScanTCP(IPs []string, Ports string, timeout int) []string {
-	var AliveAddress []string
+// PortScanTCP TCP全连接扫描，按主机时延自适应超时，超时的端口按重试次数重新探测
+// timeout为单次连接的最大超时时间(秒)
+func PortScanTCP(scan *structs.Scan, IPs []string, Ports string, timeout int) []string {
 	probePorts := ParsePort(Ports)
+	stage := scan.Progress.Start("端口扫描", len(IPs)*len(probePorts))
+	defer stage.Finish()
 
-	workers := structs.GlobalConfig.TCPPortScanThreads
-	if workers > len(IPs)*len(probePorts) {
-		workers = len(IPs) * len(probePorts)
-	}
-	Addrs := make(chan Addr, len(IPs)*len(probePorts))
-	results := make(chan string, len(IPs)*len(probePorts))
-	var wg sync.WaitGroup
-
-	//接收结果
+	// 打乱主机顺序，同时扫描的主机的端口顺序也各自打乱，避免连接集中在同一主机或同一端口
+	hosts := make(chan string)
 	go func() {
-		for found := range results {
-			AliveAddress = append(AliveAddress, found)
-			wg.Done()
+		defer close(hosts)
+		for _, i := range rand.Perm(len(IPs)) {
+			hosts <- IPs[i]
 		}
 	}()
 
-	//多线程扫描
+	var AliveAddress []string
+	out := make(chan string)
+	go portScanTCP(scan, hosts, probePorts, timeout, nil, out, stage, false)
+	for each := range out {
+		AliveAddress = append(AliveAddress, each)
+	}
+	return AliveAddress
+}
+
+// PortScanTCPStream 从hosts读取IP进行TCP全连接扫描，每个主机扫描结束后将开放端口写入out
+// skip不为空时每个主机扫描前调用，返回true的主机不扫描。hosts关闭且全部扫描结束后关闭out
+func PortScanTCPStream(scan *structs.Scan, hosts <-chan string, Ports string, timeout int, skip func(host string) bool, out chan<- string) {
+	stage := scan.Progress.Start("端口扫描", 0)
+	defer stage.Finish()
+	portScanTCP(scan, hosts, ParsePort(Ports), timeout, skip, out, stage, true)
+}
+
+// portJob 一次端口连接，结果通过done返回
+type portJob struct {
+	addr  Addr
+	round int
+	done  func(state PortState)
+}
+
+// portScanTCP 同时扫描的主机数量不超过扫描线程数，所有主机共用连接协程，grow为true时按主机增加进度总数
+func portScanTCP(scan *structs.Scan, hosts <-chan string, probePorts []int, timeout int, skip func(host string) bool,
+	out chan<- string, stage *progress.Stage, grow bool) {
+	defer close(out)
+	maxTimeout := time.Duration(timeout) * time.Second
+	workers := scan.Config.TCPPortScanThreads
+	if workers <= 0 {
+		workers = len(probePorts)
+	}
+
+	var wg sync.WaitGroup
+	jobs := make(chan portJob, workers)
 	for i := 0; i < workers; i++ {
+		wg.Add(1)
 		go func() {
-			for addr := range Addrs {
-				PortConnect(addr, results, timeout, &wg)
-				wg.Done()
+			defer wg.Done()
+			for job := range jobs {
+				state := PortConnect(scan, job.addr, maxTimeout, job.round)
+				stage.Add(1)
+				job.done(state)
 			}
 		}()
 	}
 
-	//添加扫描目标
+	var hostWG sync.WaitGroup
+	limiter := make(chan struct{}, workers)
+	for host := range hosts {
+		limiter <- struct{}{}
+		hostWG.Add(1)
+		go func(host string) {
+			defer func() {
+				<-limiter
+				hostWG.Done()
+			}()
+			if skip != nil && skip(host) {
+				return
+			}
+			if grow {
+				stage.AddTotal(int64(len(probePorts)))
+			}
+			for _, each := range scanHost(scan, host, probePorts, maxTimeout, jobs, stage) {
+				out <- each
+			}
+		}(host)
+	}
+	hostWG.Wait()
+	close(jobs)
+	wg.Wait()
+}
+
+// scanHost 扫描一个主机的全部端口，超时的端口按重试次数重新探测，开放端口数量超出阈值时丢弃
+func scanHost(scan *structs.Scan, host string, probePorts []int, maxTimeout time.Duration, jobs chan<- portJob, stage *progress.Stage) []string {
+	// 排除的host:port不发起连接
+	targets := make([]int, 0, len(probePorts))
 	for _, port := range probePorts {
-		for _, host := range IPs {
+		if scan.IsExcluded(net.JoinHostPort(host, strconv.Itoa(port))) {
+			stage.Add(1)
+			continue
+		}
+		targets = append(targets, port)
+	}
+	rand.Shuffle(len(targets), func(i, j int) {
+		targets[i], targets[j] = targets[j], targets[i]
+	})
+
+	var found []string
+	retries := scan.Config.TCPPortScanRetries
+	for round := 0; round <= retries && len(targets) > 0; round++ {
+		if round > 0 {
+			gologger.Debug().Msgf("%s 重试 %d 个超时端口(第%d次)", host, len(targets), round)
+			stage.AddTotal(int64(len(targets)))
+		}
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+		var filtered []int
+		for _, port := range targets {
+			port := port
 			wg.Add(1)
-			Addrs <- Addr{host, port}
+			jobs <- portJob{addr: Addr{host, port}, round: round, done: func(state PortState) {
+				lock.Lock()
+				switch state {
+				case PortOpen:
+					found = append(found, net.JoinHostPort(host, strconv.Itoa(port)))
+				case PortFiltered:
+					filtered = append(filtered, port)
+				}
+				lock.Unlock()
+				wg.Done()
+			}}
 		}
+		wg.Wait()
+		targets = filtered
 	}
-	wg.Wait()
-	close(Addrs)
-	close(results)
 
-	return AliveAddress
+	if exceedsPortsThreshold(scan, host, len(found)) {
+		return nil
+	}
+	return found
 }
 
 type Addr struct {
@@ -101,41 +204,132 @@ type Addr struct {
 	port int
 }
 
-var PortScan bool
+// PortState TCP连接探测的结果
+type PortState int
 
-func PortConnect(addr Addr, respondingHosts chan<- string, adjustedTimeout int, wg *sync.WaitGroup) {
+const (
+	PortOpen        PortState = iota
+	PortClosed                // 收到RST
+	PortFiltered              // 超时未响应
+	PortUnreachable           // 主机或网络不可达等其他错误
+)
+
+// PortConnect 连接addr，超时时间由主机时延估算，每次重试翻倍，不超过maxTimeout
+// 连接建立或被拒绝所用时间记为该主机的时延
+func PortConnect(scan *structs.Scan, addr Addr, maxTimeout time.Duration, round int) PortState {
 	host, port := addr.ip, addr.port
-	conn, err := WrapperTcpWithTimeout("tcp4", fmt.Sprintf("%s:%v", host, port), time.Duration(adjustedTimeout)*time.Second)
-	defer func() {
-		if conn != nil {
-			conn.Close()
+	if scan.IsExcluded(net.JoinHostPort(host, strconv.Itoa(port))) {
+		return PortUnreachable
+	}
+	timeout := rtt.Timeout(host, maxTimeout) << round
+	if timeout > maxTimeout || timeout <= 0 {
+		timeout = maxTimeout
+	}
+
+	state, elapsed := dialPort(addr, timeout)
+	if elapsed > 0 {
+		rtt.Record(host, elapsed)
+	}
+	if state == PortOpen {
+		gologger.Silent().Msgf("[PortScan] %v", net.JoinHostPort(host, strconv.Itoa(port)))
+		report.AddRecord(scan, report.RecordPort, structs.PortRecord{IP: host, Port: port})
+	}
+	return state
+}
+
+// dialPort 连接addr，返回端口状态与连接建立或被拒绝所用的时间
+func dialPort(addr Addr, timeout time.Duration) (PortState, time.Duration) {
+	address := net.JoinHostPort(addr.ip, strconv.Itoa(addr.port))
+
+	// 在发起连接时计时，不计入限速等待
+	var start time.Time
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			start = time.Now()
+			return nil
+		},
+	}
+	conn, err := WrapperTCP("tcp", address, dialer)
+	var elapsed time.Duration
+	if !start.IsZero() {
+		elapsed = time.Since(start)
+	}
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return PortClosed, elapsed
 		}
-	}()
-	if err == nil {
-		address := host + ":" + strconv.Itoa(port)
-		if PortScan {
-			gologger.Silent().Msgf("[PortScan] %v", address)
-		} else {
-			gologger.Silent().Msgf("[TCP-Alive] %v", address)
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return PortFiltered, 0
 		}
-		wg.Add(1)
-		respondingHosts <- address
+		return PortUnreachable, 0
+	}
+	conn.Close()
+	return PortOpen, elapsed
+}
+
+// synRate SYN扫描与ACK探测的每秒发包数，不超过全局限速
+func synRate(scan *structs.Scan) int {
+	rate := scan.Config.SYNPortScanThreads
+	if scan.Config.RateLimit > 0 && (rate <= 0 || scan.Config.RateLimit < rate) {
+		rate = scan.Config.RateLimit
+	}
+	return rate
+}
+
+// PortScanSYN 使用原生SYN扫描探测IP的端口，不支持或权限不足时返回错误，由调用方降级
+func PortScanSYN(scan *structs.Scan, IPs []string, Ports string) ([]string, error) {
+	ips := utils.RemoveDuplicateElement(IPs)
+	ports := ParsePort(Ports)
+
+	rate := synRate(scan)
+
+	stage := scan.Progress.Start("SYN端口扫描", len(ips)*len(ports))
+	defer stage.Finish()
+
+	gologger.Info().Msgf("开始SYN端口扫描")
+	found, err := synscan.Scan(synscan.Options{
+		IPs:     ips,
+		Ports:   ports,
+		Rate:    rate,
+		Retries: scan.Config.SYNRetries,
+		Wait:    time.Duration(scan.Config.TCPPortScanTimeout) * time.Second,
+		OnSent: func() {
+			stage.Add(1)
+		},
+		Skip: scan.IsExcluded,
+	})
+	if err != nil {
+		return nil, err
 	}
+	return recordSYNResults(scan, found), nil
 }
 
-func PortScanSYN(IPs []string) []string {
-	ips := strings.Join(utils.RemoveDuplicateElement(IPs), "\n")
-	err := os.WriteFile("masscan_tmp.txt", []byte(ips), 0666)
+// PortScanMasscan 调用masscan进行SYN扫描
+func PortScanMasscan(scan *structs.Scan, IPs []string, Ports string) []string {
+	file, err := os.CreateTemp("", "dddd_masscan_*.txt")
+	if err != nil {
+		gologger.Error().Msgf("创建masscan目标文件失败: %v", err)
+		return []string{}
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(strings.Join(utils.RemoveDuplicateElement(IPs), "\n"))
+	file.Close()
 	if err != nil {
 		return []string{}
 	}
-	defer os.Remove("masscan_tmp.txt")
 
-	ms := masscan.New(structs.GlobalConfig.MasscanPath)
-	ms.SetFileName("masscan_tmp.txt")
-	ms.SetPorts("1-65535")
-	ms.SetRate(strconv.Itoa(structs.GlobalConfig.SYNPortScanThreads))
-	gologger.Info().Msgf("开始SYN端口扫描")
+	ms := masscan.New(scan.Config.MasscanPath)
+	ms.SetFileName(file.Name())
+	ms.SetPorts(Ports)
+	ms.SetRate(strconv.Itoa(scan.Config.SYNPortScanThreads))
+	if scan.Exclude != nil {
+		if networks := scan.Exclude.Networks(); len(networks) > 0 {
+			ms.SetExclude(strings.Join(networks, ","))
+		}
+	}
+	gologger.Info().Msgf("开始masscan端口扫描")
 	err = ms.Run()
 	if err != nil {
 		return []string{}
@@ -149,18 +343,26 @@ func PortScanSYN(IPs []string) []string {
 	var results []string
 	for _, each := range hosts {
 		for _, port := range each.Ports {
-			results = append(results, each.Address.Addr+":"+port.Portid)
+			results = append(results, net.JoinHostPort(each.Address.Addr, port.Portid))
 		}
 	}
-	results = utils.RemoveDuplicateElement(results)
+	return recordSYNResults(scan, results)
+}
+
+// recordSYNResults 去重、排除后输出SYN扫描结果
+func recordSYNResults(scan *structs.Scan, found []string) []string {
+	results := scan.FilterExcluded(utils.RemoveDuplicateElement(found))
 	for _, each := range results {
 		gologger.Silent().Msg("[PortScan] " + each)
+		ip, p, _ := net.SplitHostPort(each)
+		port, _ := strconv.Atoi(p)
+		report.AddRecord(scan, report.RecordPort, structs.PortRecord{IP: ip, Port: port})
 	}
 	return results
 }
 
 // CheckMasScan 校验MasScan是否正确安装
-func CheckMasScan() bool {
+func CheckMasScan(scan *structs.Scan) bool {
 	var bsenv = ""
 	if OS != "windows" {
 		bsenv = "/bin/bash"
@@ -168,38 +370,39 @@ func CheckMasScan() bool {
 
 	var command *exec.Cmd
 	if OS == "windows" {
-		command = exec.Command("cmd", "/c", structs.GlobalConfig.MasscanPath)
+		command = exec.Command("cmd", "/c", scan.Config.MasscanPath)
 	} else if OS == "linux" {
-		command = exec.Command(bsenv, "-c", structs.GlobalConfig.MasscanPath)
+		command = exec.Command(bsenv, "-c", scan.Config.MasscanPath)
 	} else if OS == "darwin" {
-		command = exec.Command(bsenv, "-c", structs.GlobalConfig.MasscanPath)
+		command = exec.Command(bsenv, "-c", scan.Config.MasscanPath)
 	}
 	outinfo := bytes.Buffer{}
 	command.Stdout = &outinfo
 	err := command.Start()
 	if err != nil {
-		gologger.Error().Msgf("未检测到路径 %v 存在masscan", structs.GlobalConfig.MasscanPath)
+		gologger.Error().Msgf("未检测到路径 %v 存在masscan", scan.Config.MasscanPath)
 		return false
 	}
 	_ = command.Wait()
 
 	// 未检测到masscan的默认banner
 	if !strings.Contains(outinfo.String(), "masscan -p80,8000-8100 10.0.0.0/8 --rate=10000") {
-		gologger.Error().Msgf("未检测到路径 %v 存在masscan", structs.GlobalConfig.MasscanPath)
+		gologger.Error().Msgf("未检测到路径 %v 存在masscan", scan.Config.MasscanPath)
 		return false
 	}
 
 	return true
 }
 
-func RemoveFirewall(ipPorts []string) []string {
+func RemoveFirewall(scan *structs.Scan, ipPorts []string) []string {
 	var results []string
 
 	m := make(map[string][]string)
 	for _, ipPort := range ipPorts {
-		t := strings.Split(ipPort, ":")
-		ip := t[0]
-		port := t[1]
+		ip, port, err := net.SplitHostPort(ipPort)
+		if err != nil {
+			continue
+		}
 
 		_, ok := m[ip]
 		if !ok {
@@ -211,13 +414,21 @@ func RemoveFirewall(ipPorts []string) []string {
 
 	for ip, ports := range m {
 		ps := utils.RemoveDuplicateElement(ports)
-		if len(ps) >= structs.GlobalConfig.PortsThreshold {
-			gologger.Error().Msgf("%s 端口数量超出阈值,已丢弃", ip)
+		if exceedsPortsThreshold(scan, ip, len(ps)) {
 			continue
 		}
 		for _, p := range ports {
-			results = append(results, ip+":"+p)
+			results = append(results, net.JoinHostPort(ip, p))
 		}
 	}
 	return utils.RemoveDuplicateElement(results)
 }
+
+// exceedsPortsThreshold 单个IP开放端口数量超出阈值时多为防火墙应答，丢弃该IP
+func exceedsPortsThreshold(scan *structs.Scan, ip string, count int) bool {
+	if count == 0 || count < scan.Config.PortsThreshold {
+		return false
+	}
+	gologger.Error().Msgf("%s 端口数量超出阈值,已丢弃", ip)
+	return true
+}
@@ -0,0 +1,235 @@
+// Package progress 记录各阶段的完成数量，定时在stderr输出进度、速率与预计剩余时间，并可通过HTTP接口以JSON查询
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stage 一个阶段的进度，总数未知时可边执行边增加
+type Stage struct {
+	tracker  *Tracker
+	name     string
+	started  time.Time
+	total    atomic.Int64
+	done     atomic.Int64
+	finished atomic.Int64 // 结束时间的UnixNano，0为未结束
+	counter  func() (done int64, total int64)
+}
+
+// Status 阶段进度的快照
+type Status struct {
+	Name           string  `json:"name"`
+	Done           int64   `json:"done"`
+	Total          int64   `json:"total"`
+	Percent        float64 `json:"percent"`
+	Rate           float64 `json:"rate"` // 每秒完成数
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	ETASeconds     float64 `json:"eta_seconds"` // 无法估计时为-1
+	Finished       bool    `json:"finished"`
+}
+
+// Tracker 一次扫描各阶段的进度
+type Tracker struct {
+	stages    []*Stage
+	startedAt time.Time
+	lock      sync.Mutex
+	verbose   atomic.Bool
+}
+
+// New 创建进度记录，每次扫描开始时调用
+func New() *Tracker {
+	return &Tracker{startedAt: time.Now()}
+}
+
+// Start 开始一个阶段，total为预计完成总数，未知时传0
+func (t *Tracker) Start(name string, total int) *Stage {
+	s := &Stage{tracker: t, name: name, started: time.Now()}
+	s.total.Store(int64(total))
+	t.lock.Lock()
+	t.stages = append(t.stages, s)
+	t.lock.Unlock()
+	return s
+}
+
+// Track 开始一个由外部计数器提供进度的阶段
+func (t *Tracker) Track(name string, counter func() (done int64, total int64)) *Stage {
+	s := t.Start(name, 0)
+	s.counter = counter
+	return s
+}
+
+// Add 完成数量增加n，超过总数时总数随之增加
+func (s *Stage) Add(n int64) {
+	done := s.done.Add(n)
+	for {
+		total := s.total.Load()
+		if done <= total || s.total.CompareAndSwap(total, done) {
+			return
+		}
+	}
+}
+
+// AddTotal 总数增加n
+func (s *Stage) AddTotal(n int64) {
+	s.total.Add(n)
+}
+
+// Finish 结束阶段，开启进度输出时打印阶段用时
+func (s *Stage) Finish() {
+	if !s.finished.CompareAndSwap(0, time.Now().UnixNano()) {
+		return
+	}
+	if s.tracker.verbose.Load() {
+		status := s.Status()
+		fmt.Fprintf(os.Stderr, "[进度] %s 完成 %d/%d 用时 %s\n",
+			status.Name, status.Done, status.Total, formatSeconds(status.ElapsedSeconds))
+	}
+}
+
+// Status 返回阶段当前的进度
+func (s *Stage) Status() Status {
+	done, total := s.done.Load(), s.total.Load()
+	if s.counter != nil {
+		done, total = s.counter()
+	}
+	end := time.Now()
+	finished := s.finished.Load()
+	if finished != 0 {
+		end = time.Unix(0, finished)
+	}
+	elapsed := end.Sub(s.started).Seconds()
+
+	status := Status{
+		Name:           s.name,
+		Done:           done,
+		Total:          total,
+		ElapsedSeconds: elapsed,
+		ETASeconds:     -1,
+		Finished:       finished != 0,
+	}
+	if total > 0 {
+		status.Percent = float64(done) * 100 / float64(total)
+	}
+	if elapsed > 0 {
+		status.Rate = float64(done) / elapsed
+	}
+	if status.Finished {
+		status.ETASeconds = 0
+	} else if status.Rate > 0 && total >= done {
+		status.ETASeconds = float64(total-done) / status.Rate
+	}
+	return status
+}
+
+// All 返回所有阶段的进度
+func (t *Tracker) All() []Status {
+	t.lock.Lock()
+	list := append([]*Stage{}, t.stages...)
+	t.lock.Unlock()
+
+	var result []Status
+	for _, s := range list {
+		result = append(result, s.Status())
+	}
+	return result
+}
+
+func formatSeconds(seconds float64) string {
+	if seconds < 0 {
+		return "未知"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// String 单行进度描述
+func (status Status) String() string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "[进度] %s %d/%d", status.Name, status.Done, status.Total)
+	if status.Total > 0 {
+		fmt.Fprintf(builder, " (%.2f%%)", status.Percent)
+	}
+	fmt.Fprintf(builder, " %.1f/s 已用 %s 剩余 %s", status.Rate,
+		formatSeconds(status.ElapsedSeconds), formatSeconds(status.ETASeconds))
+	return builder.String()
+}
+
+// StartReporter 每隔interval在stderr输出未结束阶段的进度，返回的函数用于停止输出
+func (t *Tracker) StartReporter(interval time.Duration) (stop func()) {
+	t.verbose.Store(true)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, status := range t.All() {
+					if !status.Finished {
+						fmt.Fprintln(os.Stderr, status.String())
+					}
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.verbose.Store(false)
+			close(done)
+		})
+	}
+}
+
+// statusResponse 状态接口的响应
+type statusResponse struct {
+	StartedAt      time.Time `json:"started_at"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	Current        string    `json:"current"` // 最近开始且未结束的阶段
+	Stages         []Status  `json:"stages"`
+}
+
+func (t *Tracker) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	resp := statusResponse{
+		StartedAt:      t.startedAt,
+		ElapsedSeconds: time.Since(t.startedAt).Seconds(),
+		Stages:         t.All(),
+	}
+	if resp.Stages == nil {
+		resp.Stages = []Status{}
+	}
+	for _, status := range resp.Stages {
+		if !status.Finished {
+			resp.Current = status.Name
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Serve 在addr上提供JSON格式的进度查询接口，返回的函数用于关闭
+func (t *Tracker) Serve(addr string) (stop func(), err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", t.handleStatus)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	return func() {
+		_ = server.Close()
+	}, nil
+}
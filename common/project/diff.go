@@ -0,0 +1,226 @@
+package project
+
+import (
+	"dddd/common"
+	"dddd/common/report"
+	"dddd/structs"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 资产变化类型
+const (
+	ChangeNew     = "new"
+	ChangeClosed  = "closed"
+	ChangeChanged = "changed"
+	ChangeFixed   = "fixed"
+)
+
+// ranStage 快照中是否完成了任一阶段
+func (s *Snapshot) ranStage(stages ...string) bool {
+	for _, finished := range s.Stages {
+		for _, stage := range stages {
+			if finished == stage {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameTargets 两次扫描的目标是否相同，目标不同时资产消失可能只是因为没有扫描
+func (s *Snapshot) sameTargets(other *Snapshot) bool {
+	a := toSet(s.Targets)
+	b := toSet(other.Targets)
+	if len(a) != len(b) {
+		return false
+	}
+	for target := range a {
+		if _, ok := b[target]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare 对比两次快照。只有两次扫描都执行过的阶段才参与对比，避免未执行的阶段被当作资产消失；
+// 两次扫描的目标不同时只输出新增与变化
+func Compare(prev *Snapshot, cur *Snapshot) []structs.DiffRecord {
+	var records []structs.DiffRecord
+	add := func(kind, change, target, detail, old string) {
+		records = append(records, structs.DiffRecord{
+			Kind:   kind,
+			Change: change,
+			Target: target,
+			Detail: detail,
+			Old:    old,
+		})
+	}
+	both := func(stages ...string) bool {
+		return prev.ranStage(stages...) && cur.ranStage(stages...)
+	}
+	sameTargets := prev.sameTargets(cur)
+
+	if both(common.StageDiscovery, common.StagePortScan) {
+		prevHosts := toSet(prev.Hosts)
+		curHosts := toSet(cur.Hosts)
+		for _, host := range sortedKeys(curHosts) {
+			if _, ok := prevHosts[host]; !ok {
+				add("host", ChangeNew, host, "", "")
+			}
+		}
+		for _, host := range sortedKeys(prevHosts) {
+			if _, ok := curHosts[host]; !ok && sameTargets {
+				add("host", ChangeClosed, host, "", "")
+			}
+		}
+	}
+
+	if both(common.StagePortScan) {
+		for _, hostPort := range sortedKeys(cur.Ports) {
+			if _, ok := prev.Ports[hostPort]; !ok {
+				add("port", ChangeNew, hostPort, cur.Ports[hostPort], "")
+			}
+		}
+		for _, hostPort := range sortedKeys(prev.Ports) {
+			if _, ok := cur.Ports[hostPort]; !ok && sameTargets {
+				add("port", ChangeClosed, hostPort, prev.Ports[hostPort], "")
+			}
+		}
+	}
+
+	if both(common.StageProtocol) {
+		for _, hostPort := range sortedKeys(cur.Ports) {
+			old, ok := prev.Ports[hostPort]
+			if ok && old != "" && cur.Ports[hostPort] != "" && old != cur.Ports[hostPort] {
+				add("service", ChangeChanged, hostPort, cur.Ports[hostPort], old)
+			}
+		}
+		for _, hostPort := range sortedKeys(cur.Versions) {
+			old, ok := prev.Versions[hostPort]
+			if ok && old != cur.Versions[hostPort] {
+				add("version", ChangeChanged, hostPort, cur.Versions[hostPort], old)
+			}
+		}
+	}
+
+	if both(common.StageWeb) {
+		for _, u := range sortedKeys(cur.Webs) {
+			web := cur.Webs[u]
+			old, ok := prev.Webs[u]
+			if !ok {
+				add("web", ChangeNew, u, webDetail(web), "")
+			} else if old.Title != web.Title {
+				add("web", ChangeChanged, u, webDetail(web), webDetail(old))
+			}
+		}
+	}
+
+	if both(common.StageFinger) {
+		for _, target := range sortedKeys(cur.Fingers) {
+			oldProducts := toSet(prev.Fingers[target])
+			for _, product := range cur.Fingers[target] {
+				if _, ok := oldProducts[product]; !ok {
+					add("finger", ChangeNew, target, product, "")
+				}
+			}
+		}
+	}
+
+	// Nuclei与GoPoc分别对比，只执行了其中一个时另一个的漏洞不会被当作已修复
+	for _, engine := range []string{common.StageNuclei, common.StageGoPoc} {
+		if !both(engine) {
+			continue
+		}
+		for _, key := range sortedKeys(cur.Vulns) {
+			vuln := cur.Vulns[key]
+			if _, ok := prev.Vulns[key]; !ok && vuln.Engine == engine {
+				add("vuln", ChangeNew, vuln.Target, vuln.Name+" "+vuln.Severity, "")
+			}
+		}
+		if !sameTargets {
+			continue
+		}
+		for _, key := range sortedKeys(prev.Vulns) {
+			vuln := prev.Vulns[key]
+			if _, ok := cur.Vulns[key]; !ok && vuln.Engine == engine {
+				add("vuln", ChangeFixed, vuln.Target, vuln.Name+" "+vuln.Severity, "")
+			}
+		}
+	}
+	return records
+}
+
+var kindNames = map[string]string{
+	"host":    "主机",
+	"port":    "端口",
+	"service": "服务",
+	"version": "版本",
+	"web":     "Web",
+	"finger":  "指纹",
+	"vuln":    "漏洞",
+}
+
+var changeNames = map[string]string{
+	ChangeNew:     "新增",
+	ChangeClosed:  "消失",
+	ChangeChanged: "变化",
+	ChangeFixed:   "修复",
+}
+
+// ReportDiff 输出与上一次扫描相比的变化，并写入JSONL结果
+func ReportDiff(scan *structs.Scan, prev *Snapshot, cur *Snapshot) {
+	records := Compare(prev, cur)
+	gologger.Info().Msgf("与 %s 的扫描结果相比共有 %d 处变化",
+		prev.Time.Format("2006-01-02 15:04:05"), len(records))
+	for _, record := range records {
+		line := fmt.Sprintf("[Diff] [%s%s] %s", changeNames[record.Change], kindNames[record.Kind], record.Target)
+		if record.Detail != "" {
+			line += " " + record.Detail
+		}
+		if record.Old != "" {
+			line += " (原: " + record.Old + ")"
+		}
+		gologger.Silent().Msg(line)
+		report.AddRecord(scan, report.RecordDiff, record)
+	}
+}
+
+func webDetail(web WebInfo) string {
+	return "[" + strconv.Itoa(web.StatusCode) + "] " + web.Title
+}
+
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+	return set
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return lessTarget(keys[i], keys[j])
+	})
+	return keys
+}
+
+// lessTarget 同一主机的端口按数字排序
+func lessTarget(a string, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA == nil && errB == nil && hostA == hostB {
+		pa, _ := strconv.Atoi(portA)
+		pb, _ := strconv.Atoi(portB)
+		return pa < pb
+	}
+	return strings.Compare(a, b) < 0
+}
@@ -0,0 +1,191 @@
+package project
+
+import (
+	"dddd/common"
+	"dddd/common/report"
+	"dddd/structs"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var allStages = []string{common.StageDiscovery, common.StagePortScan, common.StageProtocol,
+	common.StageWeb, common.StageFinger, common.StageNuclei, common.StageGoPoc}
+
+// diffSnapshot 两次对比共用的基础快照
+func diffSnapshot() *Snapshot {
+	return &Snapshot{
+		Time:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Targets: []string{"10.0.0.0/24"},
+		Stages:  allStages,
+		Hosts:   []string{"10.0.0.1"},
+		Ports:   map[string]string{"10.0.0.1:22": "ssh", "10.0.0.1:80": "http"},
+		Webs: map[string]WebInfo{
+			"http://10.0.0.1": {StatusCode: 200, Title: "Welcome"},
+		},
+		Fingers:  map[string][]string{"http://10.0.0.1": {"nginx"}},
+		Vulns:    map[string]VulnInfo{},
+		Versions: map[string]string{"10.0.0.1:22": "OpenSSH 8.0"},
+	}
+}
+
+func diffRecord(kind, change, target, detail, old string) structs.DiffRecord {
+	return structs.DiffRecord{Kind: kind, Change: change, Target: target, Detail: detail, Old: old}
+}
+
+func TestCompare(t *testing.T) {
+	nucleiVuln := VulnInfo{Name: "CVE-2021-41773", Target: "http://10.0.0.1", Severity: "critical", Engine: common.StageNuclei}
+	gopocVuln := VulnInfo{Name: "SSH-Weak", Target: "10.0.0.1:22", Severity: "high", Engine: common.StageGoPoc}
+
+	tests := []struct {
+		name   string
+		modify func(prev *Snapshot, cur *Snapshot)
+		want   []structs.DiffRecord
+	}{
+		{
+			name:   "没有变化",
+			modify: func(prev *Snapshot, cur *Snapshot) {},
+		},
+		{
+			name: "主机与端口",
+			modify: func(prev *Snapshot, cur *Snapshot) {
+				cur.Hosts = []string{"10.0.0.2"}
+				cur.Ports = map[string]string{"10.0.0.1:22": "ssh", "10.0.0.1:8080": "", "10.0.0.2:443": "https"}
+			},
+			want: []structs.DiffRecord{
+				diffRecord("host", ChangeNew, "10.0.0.2", "", ""),
+				diffRecord("host", ChangeClosed, "10.0.0.1", "", ""),
+				diffRecord("port", ChangeNew, "10.0.0.1:8080", "", ""),
+				diffRecord("port", ChangeNew, "10.0.0.2:443", "https", ""),
+				diffRecord("port", ChangeClosed, "10.0.0.1:80", "http", ""),
+			},
+		},
+		{
+			name: "服务与版本",
+			modify: func(prev *Snapshot, cur *Snapshot) {
+				prev.Ports["10.0.0.1:8080"] = ""
+				cur.Ports = map[string]string{"10.0.0.1:22": "ssh", "10.0.0.1:80": "https", "10.0.0.1:8080": "http"}
+				cur.Versions = map[string]string{"10.0.0.1:22": "OpenSSH 9.6"}
+			},
+			// 上一次未识别出协议的端口不算服务变化
+			want: []structs.DiffRecord{
+				diffRecord("service", ChangeChanged, "10.0.0.1:80", "https", "http"),
+				diffRecord("version", ChangeChanged, "10.0.0.1:22", "OpenSSH 9.6", "OpenSSH 8.0"),
+			},
+		},
+		{
+			name: "Web与指纹",
+			modify: func(prev *Snapshot, cur *Snapshot) {
+				cur.Webs = map[string]WebInfo{
+					"http://10.0.0.1":      {StatusCode: 302, Title: "Login"},
+					"http://10.0.0.1:8080": {StatusCode: 404, Title: ""},
+				}
+				cur.Fingers = map[string][]string{"http://10.0.0.1": {"nginx", "Tomcat"}}
+			},
+			want: []structs.DiffRecord{
+				diffRecord("web", ChangeChanged, "http://10.0.0.1", "[302] Login", "[200] Welcome"),
+				diffRecord("web", ChangeNew, "http://10.0.0.1:8080", "[404] ", ""),
+				diffRecord("finger", ChangeNew, "http://10.0.0.1", "Tomcat", ""),
+			},
+		},
+		{
+			name: "漏洞新增与修复",
+			modify: func(prev *Snapshot, cur *Snapshot) {
+				prev.Vulns["nuclei-old"] = nucleiVuln
+				cur.Vulns["gopoc-new"] = gopocVuln
+			},
+			want: []structs.DiffRecord{
+				diffRecord("vuln", ChangeFixed, "http://10.0.0.1", "CVE-2021-41773 critical", ""),
+				diffRecord("vuln", ChangeNew, "10.0.0.1:22", "SSH-Weak high", ""),
+			},
+		},
+		{
+			name: "只执行了Nuclei",
+			modify: func(prev *Snapshot, cur *Snapshot) {
+				prev.Vulns["gopoc-old"] = gopocVuln
+				cur.Stages = []string{common.StageNuclei}
+			},
+		},
+		{
+			name: "未执行的阶段不参与对比",
+			modify: func(prev *Snapshot, cur *Snapshot) {
+				cur.Stages = []string{common.StageFinger}
+				cur.Hosts = nil
+				cur.Ports = map[string]string{}
+				cur.Webs = map[string]WebInfo{}
+			},
+		},
+		{
+			name: "目标不同时只输出新增与变化",
+			modify: func(prev *Snapshot, cur *Snapshot) {
+				prev.Vulns["nuclei-old"] = nucleiVuln
+				cur.Targets = []string{"10.0.0.1"}
+				cur.Hosts = []string{"10.0.0.2"}
+				cur.Ports = map[string]string{"10.0.0.1:22": "ssh", "10.0.0.2:443": "https"}
+				cur.Vulns["gopoc-new"] = gopocVuln
+			},
+			want: []structs.DiffRecord{
+				diffRecord("host", ChangeNew, "10.0.0.2", "", ""),
+				diffRecord("port", ChangeNew, "10.0.0.2:443", "https", ""),
+				diffRecord("vuln", ChangeNew, "10.0.0.1:22", "SSH-Weak high", ""),
+			},
+		},
+		{
+			name: "目标顺序不同",
+			modify: func(prev *Snapshot, cur *Snapshot) {
+				prev.Targets = []string{"10.0.0.0/24", "example.com"}
+				cur.Targets = []string{"example.com", "10.0.0.0/24"}
+				cur.Hosts = nil
+			},
+			want: []structs.DiffRecord{
+				diffRecord("host", ChangeClosed, "10.0.0.1", "", ""),
+			},
+		},
+	}
+	for _, tt := range tests {
+		prev, cur := diffSnapshot(), diffSnapshot()
+		tt.modify(prev, cur)
+		got := Compare(prev, cur)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Compare() =\n%+v\nwant\n%+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReportDiff(t *testing.T) {
+	// 变化记录会写入当前目录的log.txt
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	scan := structs.NewScan(structs.Config{})
+	var got []structs.DiffRecord
+	scan.Report.Hook = func(recordType string, data interface{}) {
+		if recordType != report.RecordDiff {
+			t.Errorf("记录类型 = %s, want %s", recordType, report.RecordDiff)
+		}
+		got = append(got, data.(structs.DiffRecord))
+	}
+
+	prev, cur := diffSnapshot(), diffSnapshot()
+	cur.Ports["10.0.0.1:443"] = "https"
+	delete(cur.Ports, "10.0.0.1:22")
+	ReportDiff(scan, prev, cur)
+
+	want := []structs.DiffRecord{
+		diffRecord("port", ChangeNew, "10.0.0.1:443", "https", ""),
+		diffRecord("port", ChangeClosed, "10.0.0.1:22", "ssh", ""),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReportDiff 输出 = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+// Package project 将每次扫描的资产按项目名保存到本地数据库，并与同一项目的上一次扫描对比
+package project
+
+import (
+	"dddd/common"
+	"dddd/structs"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WebInfo Web路径的状态码与标题
+type WebInfo struct {
+	StatusCode int    `json:"status_code"`
+	Title      string `json:"title"`
+}
+
+// VulnInfo 漏洞结果
+type VulnInfo struct {
+	Name     string `json:"name"`
+	Target   string `json:"target"`
+	Severity string `json:"severity"`
+	Engine   string `json:"engine"` // 发现漏洞的阶段 nuclei 或 gopoc
+}
+
+// Snapshot 一次扫描结束时的资产
+type Snapshot struct {
+	Time     time.Time           `json:"time"`
+	Targets  []string            `json:"targets"`
+	Stages   []string            `json:"stages"` // 已完成的阶段，未执行的阶段不参与对比
+	Hosts    []string            `json:"hosts"`
+	Ports    map[string]string   `json:"ports"` // host:port -> 协议，未识别时为空
+	Webs     map[string]WebInfo  `json:"webs"`  // URL -> 状态码与标题
+	Fingers  map[string][]string `json:"fingers"`
+	Vulns    map[string]VulnInfo `json:"vulns"`
+	Versions map[string]string   `json:"versions,omitempty"` // host:port -> 产品与版本，未识别出版本的端口不记录
+}
+
+// NewSnapshot 从全局资产状态生成快照
+func NewSnapshot(scan *structs.Scan, st *structs.CheckpointState) *Snapshot {
+	snap := &Snapshot{
+		Time:     time.Now(),
+		Targets:  scan.Config.Targets,
+		Stages:   st.FinishedStages,
+		Ports:    make(map[string]string),
+		Webs:     make(map[string]WebInfo),
+		Fingers:  make(map[string][]string),
+		Vulns:    make(map[string]VulnInfo),
+		Versions: make(map[string]string),
+	}
+
+	hosts := make(map[string]struct{})
+	for _, host := range st.AliveHosts {
+		hosts[host] = struct{}{}
+	}
+	addPort := func(key string, protocol string) {
+		_, hostPort := structs.SplitServiceKey(key)
+		host, _, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			return
+		}
+		hosts[host] = struct{}{}
+		if protocol != "" || snap.Ports[key] == "" {
+			snap.Ports[key] = protocol
+		}
+	}
+	for _, hostPort := range st.IPPort {
+		addPort(hostPort, "")
+	}
+	for _, hostPort := range st.DomainPort {
+		addPort(hostPort, "")
+	}
+	scan.IPPortMapLock.Lock()
+	for hostPort, protocol := range scan.IPPortMap {
+		addPort(hostPort, protocol)
+	}
+	for hostPort, detail := range scan.ServiceMap {
+		if detail.Version != "" {
+			snap.Versions[hostPort] = detail.String()
+		}
+	}
+	scan.IPPortMapLock.Unlock()
+	for host := range hosts {
+		snap.Hosts = append(snap.Hosts, host)
+	}
+	sort.Strings(snap.Hosts)
+
+	scan.URLMapLock.Lock()
+	for rootURL, entity := range scan.URLMap {
+		for path, pathEntity := range entity.WebPaths {
+			snap.Webs[joinURL(rootURL, path)] = WebInfo{
+				StatusCode: pathEntity.StatusCode,
+				Title:      pathEntity.Title,
+			}
+		}
+	}
+	scan.URLMapLock.Unlock()
+
+	scan.ResultMapLock.Lock()
+	for target, products := range scan.ResultMap {
+		snap.Fingers[target] = append([]string{}, products...)
+	}
+	scan.ResultMapLock.Unlock()
+
+	for _, result := range st.NucleiResults {
+		target := result.Matched
+		if target == "" {
+			target = result.Host
+		}
+		snap.Vulns[result.TemplateID+"|"+target] = VulnInfo{
+			Name:     result.TemplateID,
+			Target:   target,
+			Severity: result.Info.SeverityHolder.Severity.String(),
+			Engine:   common.StageNuclei,
+		}
+	}
+	for _, result := range scan.GoPocsResults {
+		snap.Vulns[result.PocName+"|"+result.Target] = VulnInfo{
+			Name:     result.PocName,
+			Target:   result.Target,
+			Severity: result.Security,
+			Engine:   common.StageGoPoc,
+		}
+	}
+	return snap
+}
+
+func joinURL(rootURL string, path string) string {
+	if strings.HasSuffix(rootURL, "/") && strings.HasPrefix(path, "/") {
+		return rootURL + path[1:]
+	}
+	return rootURL + path
+}
+
+var projectsBucket = []byte("projects")
+
+// Save 将快照追加到项目的扫描记录中，返回该项目的上一次快照，首次扫描时为nil
+func Save(dbPath string, name string, snap *Snapshot) (*Snapshot, error) {
+	db, err := bbolt.Open(dbPath, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开项目数据库%s失败: %v", dbPath, err)
+	}
+	defer db.Close()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev *Snapshot
+	err = db.Update(func(tx *bbolt.Tx) error {
+		projects, err := tx.CreateBucketIfNotExists(projectsBucket)
+		if err != nil {
+			return err
+		}
+		runs, err := projects.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+
+		if _, v := runs.Cursor().Last(); v != nil {
+			prev = &Snapshot{}
+			if err := json.Unmarshal(v, prev); err != nil {
+				return fmt.Errorf("项目上一次扫描记录解析失败: %v", err)
+			}
+		}
+
+		id, err := runs.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, id)
+		return runs.Put(key, data)
+	})
+	return prev, err
+}
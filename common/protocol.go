@@ -1,18 +1,26 @@
 package common
 
 import (
+	"dddd/common/progress"
+	"dddd/common/report"
 	"dddd/structs"
 	"dddd/utils"
-	"fmt"
 	"github.com/lcvvvv/gonmap"
+	"github.com/logrusorgru/aurora"
 	"github.com/projectdiscovery/gologger"
+	"net"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
 
-func GetProtocol(hostPorts []string, threads int) {
+// Service 协议识别得到的服务
+type Service struct {
+	HostPort string
+	Protocol string
+}
+
+func GetProtocol(scan *structs.Scan, hostPorts []string, threads int) {
 	if len(hostPorts) == 0 {
 		return
 	}
@@ -21,75 +29,146 @@ func GetProtocol(hostPorts []string, threads int) {
 	if len(hostPorts) < threads {
 		threads = len(hostPorts)
 	}
+	stage := scan.Progress.Start("协议识别", len(hostPorts))
+	defer stage.Finish()
 
-	workers := threads
 	Addrs := make(chan string, len(hostPorts))
-	defer close(Addrs)
-	results := make(chan structs.ProtocolResult, len(hostPorts))
-	defer close(results)
-	var wg sync.WaitGroup
+	for _, hostPort := range hostPorts {
+		Addrs <- hostPort
+	}
+	close(Addrs)
+	scanProtocol(scan, Addrs, threads, nil, stage, false)
+}
 
-	//接收结果
-	go func() {
-		for found := range results {
-			if found.Status == int(gonmap.Closed) {
-				wg.Done()
-				continue
-			}
-			if found.Status == gonmap.Open || found.Response == nil {
-				wg.Done()
-				continue
-			}
+// ScanProtocol 从hostPorts读取ip:port进行协议识别，结果写入IPPortMap，识别出的服务同时写入out
+// hostPorts关闭且识别结束后关闭out
+func ScanProtocol(scan *structs.Scan, hostPorts <-chan string, threads int, out chan<- Service) {
+	stage := scan.Progress.Start("协议识别", 0)
+	defer stage.Finish()
+	scanProtocol(scan, hostPorts, threads, out, stage, true)
+}
 
-			if found.Port == 23 && found.Response.FingerPrint.Service == "" {
-				found.Response.FingerPrint.Service = "telnet"
-			}
-			hostPort := fmt.Sprintf("%s:%v", found.IP, found.Port)
-			structs.GlobalIPPortMapLock.Lock()
-			_, ok := structs.GlobalIPPortMap[hostPort]
-			structs.GlobalIPPortMapLock.Unlock()
-			if !ok {
-				structs.GlobalBannerHMap.Set(hostPort, []byte(found.Response.Raw))
-				structs.GlobalIPPortMapLock.Lock()
-				structs.GlobalIPPortMap[hostPort] = found.Response.FingerPrint.Service
-				structs.GlobalIPPortMapLock.Unlock()
-			}
-			if found.Response.FingerPrint.Service != "" {
-				gologger.Silent().Msgf("[Nmap] %v://%v:%v", found.Response.FingerPrint.Service, found.IP, found.Port)
-			}
-			wg.Done()
-		}
-	}()
+func scanProtocol(scan *structs.Scan, hostPorts <-chan string, threads int, out chan<- Service, stage *progress.Stage, grow bool) {
+	if out != nil {
+		defer close(out)
+	}
+	if threads <= 0 {
+		threads = 1
+	}
 
 	//多线程扫描
-	for i := 0; i < workers; i++ {
+	var wg sync.WaitGroup
+	Addrs := make(chan string, threads)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			scanner := gonmap.New()
 			for addr := range Addrs {
-				t := strings.Split(addr, ":")
-				if len(t) < 2 {
-					continue
+				service := identifyProtocol(scan, scanner, addr)
+				stage.Add(1)
+				if service != "" && out != nil {
+					out <- Service{HostPort: addr, Protocol: service}
 				}
-				ip := t[0]
-				port, err := strconv.Atoi(t[1])
-				if err != nil || port > 65535 {
-					continue
-				}
-				status, response := scanner.ScanTimeout(ip, port, time.Second*10)
-				var data structs.ProtocolResult
-				data.IP = ip
-				data.Port = port
-				data.Status = int(status)
-				data.Response = response
-				results <- data
 			}
 		}()
 	}
 
 	//添加扫描目标
-	for _, hostPort := range hostPorts {
-		wg.Add(1)
+	seen := make(map[string]struct{})
+	for hostPort := range hostPorts {
+		if _, ok := seen[hostPort]; ok {
+			continue
+		}
+		seen[hostPort] = struct{}{}
+		if grow {
+			stage.AddTotal(1)
+		}
 		Addrs <- hostPort
 	}
+	close(Addrs)
 	wg.Wait()
 }
+
+// identifyProtocol 识别单个端口的协议并记录，返回识别出的服务名
+func identifyProtocol(scan *structs.Scan, scanner *gonmap.Nmap, addr string) string {
+	ip, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil || port > 65535 {
+		return ""
+	}
+	status, response := scanner.ScanTimeout(ip, port, time.Second*10)
+	if status == gonmap.Closed || status == gonmap.Open || response == nil {
+		return ""
+	}
+
+	if port == 23 && response.FingerPrint.Service == "" {
+		response.FingerPrint.Service = "telnet"
+	}
+	hostPort := net.JoinHostPort(ip, strconv.Itoa(port))
+	scan.IPPortMapLock.Lock()
+	_, ok := scan.IPPortMap[hostPort]
+	if !ok {
+		scan.IPPortMap[hostPort] = response.FingerPrint.Service
+	}
+	scan.IPPortMapLock.Unlock()
+	if !ok {
+		scan.BannerHMap.Set(hostPort, []byte(response.Raw))
+	}
+	if response.FingerPrint.Service == "" {
+		return ""
+	}
+
+	detail := serviceFingerprint(response.FingerPrint)
+	if !ok {
+		scan.SetServiceFingerprint(hostPort, detail)
+	}
+	logService(response.FingerPrint.Service+"://"+hostPort, detail)
+	record := serviceRecord(ip, port, response.FingerPrint.Service, detail)
+	record.TLS = response.TLS
+	report.AddRecord(scan, report.RecordService, record)
+	if !ok {
+		collectCertificate(scan, ip, port, response.FingerPrint.Service, response.TLS)
+	}
+	return response.FingerPrint.Service
+}
+
+// serviceFingerprint 取出gonmap指纹中的服务详情
+func serviceFingerprint(fp *gonmap.FingerPrint) structs.ServiceFingerprint {
+	return structs.ServiceFingerprint{
+		ProductName:     fp.ProductName,
+		Version:         fp.Version,
+		Info:            fp.Info,
+		Hostname:        fp.Hostname,
+		OperatingSystem: fp.OperatingSystem,
+		DeviceType:      fp.DeviceType,
+		CPE:             fp.CPE,
+	}
+}
+
+// logService 输出识别出的服务，有产品或版本时附在末尾，如 [Nmap] ssh://1.1.1.1:22 [OpenSSH 8.2p1 (protocol 2.0)]
+func logService(target string, detail structs.ServiceFingerprint) {
+	if s := detail.String(); s != "" {
+		gologger.Silent().Msgf("[Nmap] %v [%v]", target, aurora.Cyan(s))
+		return
+	}
+	gologger.Silent().Msgf("[Nmap] %v", target)
+}
+
+func serviceRecord(ip string, port int, service string, detail structs.ServiceFingerprint) structs.ServiceRecord {
+	return structs.ServiceRecord{
+		IP:              ip,
+		Port:            port,
+		Service:         service,
+		ProductName:     detail.ProductName,
+		Version:         detail.Version,
+		Info:            detail.Info,
+		Hostname:        detail.Hostname,
+		OperatingSystem: detail.OperatingSystem,
+		DeviceType:      detail.DeviceType,
+		CPE:             detail.CPE,
+	}
+}
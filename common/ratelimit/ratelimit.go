@@ -0,0 +1,160 @@
+// Package ratelimit 全局与单主机的令牌桶限速及单主机并发限制，所有阶段的连接与请求共用
+package ratelimit
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 空闲超过idleTimeout的主机限速器在下一次清理时删除，避免大网段扫描时hosts无限增长
+const idleTimeout = time.Minute
+
+type hostLimiter struct {
+	limiter  *rate.Limiter
+	sem      chan struct{}
+	active   int       // 正在等待或持有名额的请求数，由lock保护
+	lastUsed time.Time // 最近一次获取或归还名额的时间，由lock保护
+}
+
+var (
+	global          *rate.Limiter
+	hostRate        int
+	hostConcurrency int
+	hosts           = make(map[string]*hostLimiter)
+	lastSweep       time.Time
+	lock            sync.Mutex
+)
+
+// Init 设置全局每秒请求数、单主机每秒请求数与单主机并发数，0表示不限制
+func Init(globalRate int, perHostRate int, perHostConcurrency int) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	global = nil
+	if globalRate > 0 {
+		global = rate.NewLimiter(rate.Limit(globalRate), 1)
+	}
+	hostRate = perHostRate
+	hostConcurrency = perHostConcurrency
+	hosts = make(map[string]*hostLimiter)
+	lastSweep = time.Now()
+}
+
+// Enabled 是否设置了任意限制
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return global != nil || hostRate > 0 || hostConcurrency > 0
+}
+
+// hostOf 从IP、域名、host:port或URL中提取主机
+func hostOf(target string) string {
+	if strings.Contains(target, "://") {
+		if u, err := url.Parse(target); err == nil {
+			return strings.ToLower(u.Hostname())
+		}
+	}
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(target)
+}
+
+// sweep 删除空闲的主机限速器，调用方须持有lock
+func sweep(now time.Time) {
+	if now.Sub(lastSweep) < idleTimeout {
+		return
+	}
+	lastSweep = now
+	for host, h := range hosts {
+		if h.active == 0 && now.Sub(h.lastUsed) >= idleTimeout {
+			delete(hosts, host)
+		}
+	}
+}
+
+// getHost 返回主机的限速器并将其标记为使用中，使用结束后须调用done
+func getHost(host string) *hostLimiter {
+	lock.Lock()
+	defer lock.Unlock()
+	if hostRate <= 0 && hostConcurrency <= 0 {
+		return nil
+	}
+	now := time.Now()
+	sweep(now)
+	h, ok := hosts[host]
+	if !ok {
+		h = &hostLimiter{}
+		if hostRate > 0 {
+			h.limiter = rate.NewLimiter(rate.Limit(hostRate), 1)
+		}
+		if hostConcurrency > 0 {
+			h.sem = make(chan struct{}, hostConcurrency)
+		}
+		hosts[host] = h
+	}
+	h.active++
+	h.lastUsed = now
+	return h
+}
+
+// done 归还getHost的使用标记
+func (h *hostLimiter) done() {
+	lock.Lock()
+	defer lock.Unlock()
+	h.active--
+	h.lastUsed = time.Now()
+}
+
+func noop() {}
+
+// Acquire 等待目标主机的并发名额与令牌，返回的release须在连接关闭或请求结束后调用
+func Acquire(target string) (release func()) {
+	lock.Lock()
+	g := global
+	lock.Unlock()
+
+	release = noop
+	h := getHost(hostOf(target))
+	if h != nil && h.sem != nil {
+		h.sem <- struct{}{}
+		var once sync.Once
+		release = func() {
+			once.Do(func() {
+				<-h.sem
+				h.done()
+			})
+		}
+	}
+	if h != nil && h.limiter != nil {
+		_ = h.limiter.Wait(context.Background())
+	}
+	if h != nil && h.sem == nil {
+		h.done()
+	}
+	if g != nil {
+		_ = g.Wait(context.Background())
+	}
+	return release
+}
+
+// conn 关闭时归还并发名额
+type conn struct {
+	net.Conn
+	release func()
+}
+
+func (c *conn) Close() error {
+	defer c.release()
+	return c.Conn.Close()
+}
+
+// WrapConn 使连接关闭时调用release
+func WrapConn(c net.Conn, release func()) net.Conn {
+	return &conn{Conn: c, release: release}
+}
@@ -0,0 +1,74 @@
+package report
+
+import (
+	"dddd/structs"
+	"encoding/json"
+	"github.com/projectdiscovery/gologger"
+	"os"
+	"time"
+)
+
+// JSONL 记录类型
+const (
+	RecordHost    = "host"
+	RecordPort    = "port"
+	RecordService = "service"
+	RecordCert    = "cert"
+	RecordWeb     = "web"
+	RecordFinger  = "finger"
+	RecordNuclei  = "nuclei"
+	RecordGoPoc   = "gopoc"
+	RecordDiff    = "diff"
+	RecordFlag    = "flag"
+)
+
+type Record struct {
+	Type string      `json:"type"`
+	Time string      `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// Close 关闭JSONL文件，扫描结束后调用
+func Close(scan *structs.Scan) {
+	scan.Report.Lock.Lock()
+	defer scan.Report.Lock.Unlock()
+	if scan.Report.JSONL != nil {
+		_ = scan.Report.JSONL.Close()
+		scan.Report.JSONL = nil
+	}
+}
+
+// AddRecord 以JSON Lines格式实时写入一条结果记录，并通知结果回调
+func AddRecord(scan *structs.Scan, recordType string, data interface{}) {
+	if scan.Report.Hook != nil {
+		scan.Report.Hook(recordType, data)
+	}
+
+	if scan.Config.JSONLOutput == "" {
+		return
+	}
+	line, err := json.Marshal(Record{
+		Type: recordType,
+		Time: time.Now().Format(time.RFC3339),
+		Data: data,
+	})
+	if err != nil {
+		gologger.Error().Msgf("JSONL序列化失败: %v", err)
+		return
+	}
+
+	scan.Report.Lock.Lock()
+	defer scan.Report.Lock.Unlock()
+	if scan.Report.JSONLFailed {
+		return
+	}
+	if scan.Report.JSONL == nil {
+		scan.Report.JSONL, err = os.OpenFile(scan.Config.JSONLOutput, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			gologger.Error().Msgf("Open %s error, %v", scan.Config.JSONLOutput, err)
+			scan.Report.JSONLFailed = true
+			return
+		}
+	}
+	_, _ = scan.Report.JSONL.Write(append(line, '\n'))
+}
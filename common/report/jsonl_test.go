@@ -0,0 +1,56 @@
+package report
+
+import (
+	"dddd/structs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAddRecord(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "results.jsonl")
+	scan := structs.NewScan(structs.Config{JSONLOutput: output})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			AddRecord(scan, RecordHost, "10.0.0.1")
+		}()
+	}
+	wg.Wait()
+	Close(scan)
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 20 {
+		t.Errorf("JSONL 共 %d 行, want 20", lines)
+	}
+}
+
+func TestAddRecordOpenFailed(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "missing", "results.jsonl")
+	scan := structs.NewScan(structs.Config{JSONLOutput: output})
+	count := 0
+	scan.Report.Hook = func(recordType string, data interface{}) {
+		count++
+	}
+
+	// 打开失败后不再尝试写入，结果回调不受影响
+	for i := 0; i < 3; i++ {
+		AddRecord(scan, RecordHost, "10.0.0.1")
+	}
+	if !scan.Report.JSONLFailed || scan.Report.JSONL != nil {
+		t.Errorf("JSONL打开失败后 JSONLFailed=%v JSONL=%v", scan.Report.JSONLFailed, scan.Report.JSONL)
+	}
+	if scan.Config.JSONLOutput != output {
+		t.Errorf("JSONLOutput 被修改为 %q", scan.Config.JSONLOutput)
+	}
+	if count != 3 {
+		t.Errorf("结果回调 %d 次, want 3", count)
+	}
+}
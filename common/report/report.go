@@ -42,20 +42,25 @@ func writeFile(result string, filename string) {
 	}
 }
 
-var ReportIndex = 1
-
-func GenerateHTMLReportHeader() {
-	if structs.GlobalConfig.ReportName == "" {
-		structs.GlobalConfig.ReportName = strconv.Itoa(int(time.Now().Unix())) + ".html"
+func GenerateHTMLReportHeader(scan *structs.Scan) {
+	if scan.Config.ReportName == "" {
+		scan.Config.ReportName = strconv.Itoa(int(time.Now().Unix())) + ".html"
+	}
+	// 续扫或仅执行部分阶段时报告可能已存在
+	if _, err := os.Stat(scan.Config.ReportName); err == nil {
+		return
 	}
 	showData := defaultHeader()
-	writeFile(showData, structs.GlobalConfig.ReportName)
+	writeFile(showData, scan.Config.ReportName)
 }
 
-func AddResultByResultEvent(result output.ResultEvent) {
-	if structs.GlobalConfig.ReportName == "" {
+func AddResultByResultEvent(scan *structs.Scan, result output.ResultEvent) {
+	AddRecord(scan, RecordNuclei, result)
+	if scan.Config.ReportName == "" {
 		return
 	}
+	scan.Report.Lock.Lock()
+	defer scan.Report.Lock.Unlock()
 	severityString := getSeverity(result.Info.SeverityHolder.Severity)
 
 	title := fmt.Sprintf(`<table>
@@ -63,7 +68,7 @@ func AddResultByResultEvent(result output.ResultEvent) {
 		<td class="vuln">%v&nbsp;&nbsp;%s</td>
 		<td class="security %s">%s</td>
 		<td class="url">%s</td>
-	</thead>`, ReportIndex, result.TemplateID, strings.ToLower(severityString), strings.ToUpper(severityString), result.Host)
+	</thead>`, scan.Report.Index, result.TemplateID, strings.ToLower(severityString), strings.ToUpper(severityString), result.Host)
 
 	info := fmt.Sprintf("<b>name:</b> %s&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;<b>author:</b> %s&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;<b>security:</b> %s",
 		result.Info.Name, result.Info.Authors.String(), severityString,
@@ -111,12 +116,14 @@ func AddResultByResultEvent(result output.ResultEvent) {
 
 	footer := "</tbody></table>"
 	d := title + header + bodyinfo + body + footer
-	writeFile(d, structs.GlobalConfig.ReportName)
+	writeFile(d, scan.Config.ReportName)
 
-	ReportIndex += 1
+	scan.Report.Index += 1
 }
 
-func AddResultByGoPocResult(result structs.GoPocsResultType) {
+func AddResultByGoPocResult(scan *structs.Scan, result structs.GoPocsResultType) {
+	scan.Report.Lock.Lock()
+	defer scan.Report.Lock.Unlock()
 	severityString := result.Security
 
 	title := fmt.Sprintf(`<table>
@@ -124,7 +131,7 @@ func AddResultByGoPocResult(result structs.GoPocsResultType) {
 		<td class="vuln">%v&nbsp;&nbsp;%s</td>
 		<td class="security %s">%s</td>
 		<td class="url">%s</td>
-	</thead>`, ReportIndex, result.PocName, strings.ToLower(severityString), strings.ToUpper(severityString), result.Target)
+	</thead>`, scan.Report.Index, result.PocName, strings.ToLower(severityString), strings.ToUpper(severityString), result.Target)
 
 	info := ""
 	if result.Description != "" {
@@ -157,7 +164,7 @@ func AddResultByGoPocResult(result structs.GoPocsResultType) {
 
 	footer := "</tbody></table>"
 	d := title + header + bodyinfo + body + footer
-	writeFile(d, structs.GlobalConfig.ReportName)
+	writeFile(d, scan.Config.ReportName)
 
-	ReportIndex += 1
+	scan.Report.Index += 1
 }
@@ -0,0 +1,97 @@
+// Package rtt 记录存活探测与端口扫描中测得的往返时延，按主机估算端口扫描的超时时间
+package rtt
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// MinTimeout 超时时间下限，避免局域网内时延过小导致连接在高并发下被误判为超时
+	MinTimeout = 300 * time.Millisecond
+	// minVariance 时延波动的下限，与RFC 6298中的时钟粒度作用相同
+	minVariance = 50 * time.Millisecond
+)
+
+// estimator 按RFC 6298平滑估算时延
+type estimator struct {
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int
+}
+
+func (e *estimator) add(sample time.Duration) {
+	if e.samples == 0 {
+		e.srtt = sample
+		e.rttvar = sample / 2
+	} else {
+		diff := e.srtt - sample
+		if diff < 0 {
+			diff = -diff
+		}
+		e.rttvar = (3*e.rttvar + diff) / 4
+		e.srtt = (7*e.srtt + sample) / 8
+	}
+	e.samples++
+}
+
+func (e *estimator) timeout() time.Duration {
+	variance := 4 * e.rttvar
+	if variance < minVariance {
+		variance = minVariance
+	}
+	return e.srtt + variance
+}
+
+var (
+	hosts  = make(map[string]*estimator)
+	global estimator
+	lock   sync.Mutex
+)
+
+// Reset 清空所有时延记录，每次扫描开始前调用
+func Reset() {
+	lock.Lock()
+	defer lock.Unlock()
+	hosts = make(map[string]*estimator)
+	global = estimator{}
+}
+
+// Record 记录一次到host的往返时延，来自ICMP应答、TCP连接建立或被拒绝
+func Record(host string, sample time.Duration) {
+	if sample <= 0 {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	e, ok := hosts[host]
+	if !ok {
+		e = &estimator{}
+		hosts[host] = e
+	}
+	e.add(sample)
+	global.add(sample)
+}
+
+// Timeout 返回连接host的超时时间，范围为MinTimeout到max
+// 没有该主机的时延记录时使用所有主机的估算值的两倍，尚无任何记录时返回max
+func Timeout(host string, max time.Duration) time.Duration {
+	lock.Lock()
+	var timeout time.Duration
+	if e, ok := hosts[host]; ok {
+		timeout = e.timeout()
+	} else if global.samples > 0 {
+		timeout = 2 * global.timeout()
+	} else {
+		timeout = max
+	}
+	lock.Unlock()
+
+	if timeout < MinTimeout {
+		timeout = MinTimeout
+	}
+	if timeout > max {
+		timeout = max
+	}
+	return timeout
+}
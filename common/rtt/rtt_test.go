@@ -0,0 +1,75 @@
+package rtt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimator(t *testing.T) {
+	var e estimator
+	e.add(100 * time.Millisecond)
+	if e.srtt != 100*time.Millisecond || e.rttvar != 50*time.Millisecond {
+		t.Fatalf("首个样本 srtt=%v rttvar=%v", e.srtt, e.rttvar)
+	}
+	if got := e.timeout(); got != 300*time.Millisecond {
+		t.Errorf("timeout() = %v, want 300ms", got)
+	}
+
+	// srtt = (7*100+180)/8 = 110, rttvar = (3*50+80)/4 = 57.5
+	e.add(180 * time.Millisecond)
+	if e.srtt != 110*time.Millisecond || e.rttvar != 57500*time.Microsecond {
+		t.Errorf("第二个样本 srtt=%v rttvar=%v", e.srtt, e.rttvar)
+	}
+	if got := e.timeout(); got != 340*time.Millisecond {
+		t.Errorf("timeout() = %v, want 340ms", got)
+	}
+
+	// 时延稳定时波动收敛到下限
+	var stable estimator
+	for i := 0; i < 50; i++ {
+		stable.add(10 * time.Millisecond)
+	}
+	if got := stable.timeout(); got != 10*time.Millisecond+minVariance {
+		t.Errorf("稳定时延 timeout() = %v, want %v", got, 10*time.Millisecond+minVariance)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	Reset()
+	defer Reset()
+	max := 3 * time.Second
+
+	if got := Timeout("10.0.0.1", max); got != max {
+		t.Errorf("没有记录时 Timeout = %v, want %v", got, max)
+	}
+
+	Record("10.0.0.1", 0)
+	Record("10.0.0.1", -time.Second)
+	if got := Timeout("10.0.0.1", max); got != max {
+		t.Errorf("无效样本不应被记录, Timeout = %v", got)
+	}
+
+	Record("10.0.0.1", 200*time.Millisecond)
+	if got := Timeout("10.0.0.1", max); got != 600*time.Millisecond {
+		t.Errorf("Timeout = %v, want 600ms", got)
+	}
+	// 其他主机使用全局估算值的两倍
+	if got := Timeout("10.0.0.2", max); got != 1200*time.Millisecond {
+		t.Errorf("未记录的主机 Timeout = %v, want 1.2s", got)
+	}
+	// 不超过max
+	if got := Timeout("10.0.0.2", time.Second); got != time.Second {
+		t.Errorf("Timeout = %v, want 1s", got)
+	}
+
+	// 不低于MinTimeout
+	Record("10.0.0.3", time.Millisecond)
+	if got := Timeout("10.0.0.3", max); got != MinTimeout {
+		t.Errorf("Timeout = %v, want %v", got, MinTimeout)
+	}
+
+	Reset()
+	if got := Timeout("10.0.0.1", max); got != max {
+		t.Errorf("Reset后 Timeout = %v, want %v", got, max)
+	}
+}
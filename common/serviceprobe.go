@@ -0,0 +1,48 @@
+package common
+
+import (
+	"dddd/config"
+	"errors"
+	"github.com/lcvvvv/gonmap"
+	"github.com/projectdiscovery/gologger"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// serviceProbeDir 配置目录下存放自定义探针的目录，文件格式与nmap-service-probes相同
+const serviceProbeDir = "probes"
+
+// LoadServiceProbes 加载配置目录下probes中的全部探针文件，按文件名顺序合并到内置探针，目录不存在时跳过
+// 每次加载前恢复为内置探针，重复调用不会重复合并
+func LoadServiceProbes() error {
+	gonmap.ResetProbes()
+	entries, err := fs.ReadDir(config.FS(), serviceProbeDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	probes, matches := gonmap.ProbesCount, gonmap.MatchCount
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name()[0] == '.' {
+			continue
+		}
+		name := path.Join(serviceProbeDir, entry.Name())
+		data, err := config.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err = gonmap.LoadProbes(name, string(data)); err != nil {
+			return err
+		}
+	}
+	if gonmap.MatchCount > matches {
+		gologger.Info().Msgf("自定义探针: 新增 %d 个探针、%d 条指纹", gonmap.ProbesCount-probes, gonmap.MatchCount-matches)
+	}
+	return nil
+}
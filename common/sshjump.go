@@ -0,0 +1,522 @@
+package common
+
+import (
+	"context"
+	"dddd/structs"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// sshJumpTunnel 当前使用的SSH跳板，重新初始化时关闭
+var sshJumpTunnel *SSHJumpDialer
+
+// SSHJumpDialer 经SSH跳板的direct-tcpip通道建立TCP连接，通道平均分配到多个SSH连接上，
+// 避免高并发时单个连接的通道过多
+type SSHJumpDialer struct {
+	address string
+	config  *ssh.ClientConfig
+	forward tunnelDialer // 非nil时经代理连接跳板
+	slots   []*sshJumpSlot
+	next    uint32
+	bridge  net.Listener // 本地SOCKS5服务，供Web探测与Nuclei HTTP请求使用
+	timeout time.Duration
+	closed  atomic.Bool
+}
+
+// sshJumpSlot 一个SSH连接，断开后在下次使用时重新连接
+type sshJumpSlot struct {
+	lock   sync.Mutex
+	client *ssh.Client
+}
+
+// InitSSHJump 根据-ssh-jump等参数连接跳板，已配置-tcp-proxy时经代理连接跳板
+func InitSSHJump(config structs.Config) error {
+	username, address, err := parseSSHJump(config.SSHJump)
+	if err != nil {
+		return err
+	}
+	auth, err := sshJumpAuth(config.SSHJumpPassword, config.SSHJumpKey)
+	if err != nil {
+		return err
+	}
+	hostKeyCallback, err := sshJumpHostKey(config.SSHJumpFingerprint)
+	if err != nil {
+		return err
+	}
+	conns := config.SSHJumpConns
+	if conns <= 0 {
+		conns = 1
+	}
+	timeout := time.Duration(config.WebTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	j := &SSHJumpDialer{
+		address: address,
+		forward: tcpProxy,
+		slots:   make([]*sshJumpSlot, conns),
+		timeout: timeout,
+	}
+	var once sync.Once
+	j.config = &ssh.ClientConfig{
+		User: username,
+		Auth: auth,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if err := hostKeyCallback(hostname, remote, key); err != nil {
+				return err
+			}
+			once.Do(func() {
+				gologger.Info().Msgf("SSH跳板 %s 主机密钥: %s %s", address, key.Type(), ssh.FingerprintSHA256(key))
+			})
+			return nil
+		},
+		Timeout: timeout,
+	}
+	for i := range j.slots {
+		j.slots[i] = &sshJumpSlot{}
+	}
+	// 先建立一个连接，认证失败时直接退出
+	if _, err = j.slots[0].get(j); err != nil {
+		return err
+	}
+
+	j.bridge, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		j.Close()
+		return err
+	}
+	go j.serveBridge()
+
+	sshJumpTunnel = j
+	setTunnel(j, "socks5://"+j.bridge.Addr().String())
+	return nil
+}
+
+// closeSSHJump 关闭上一次扫描使用的SSH跳板
+func closeSSHJump() {
+	if sshJumpTunnel != nil {
+		sshJumpTunnel.Close()
+		sshJumpTunnel = nil
+	}
+}
+
+// parseSSHJump 解析 user@host[:port]，未指定用户时使用当前用户，端口默认22
+func parseSSHJump(s string) (string, string, error) {
+	username, hostPort := "", s
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		username, hostPort = s[:i], s[i+1:]
+	}
+	if username == "" {
+		if u, err := user.Current(); err == nil {
+			username = u.Username
+		}
+	}
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host, port = strings.Trim(hostPort, "[]"), "22"
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("跳板地址为空: %s", s)
+	}
+	if _, err = strconv.ParseUint(port, 10, 16); err != nil {
+		return "", "", fmt.Errorf("跳板端口不正确: %s", s)
+	}
+	return username, net.JoinHostPort(host, port), nil
+}
+
+// sshJumpAuth 指定私钥时使用私钥认证，password为私钥密码；仅指定密码时使用密码认证；
+// 均未指定时使用SSH Agent与~/.ssh下未加密的默认私钥
+func sshJumpAuth(password, keyFile string) ([]ssh.AuthMethod, error) {
+	if keyFile != "" {
+		signer, err := loadSSHKey(keyFile, password)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+	if password != "" {
+		return []ssh.AuthMethod{
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+		}, nil
+	}
+
+	var methods []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	var signers []ssh.Signer
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			if signer, err := loadSSHKey(filepath.Join(home, ".ssh", name), ""); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("未指定密码(-ssh-jump-pass)或私钥(-ssh-jump-key)，且没有可用的SSH Agent与默认私钥")
+	}
+	return methods, nil
+}
+
+// sshJumpHostKey 指定fingerprint时只接受SHA256指纹一致的主机密钥，否则按~/.ssh/known_hosts与
+// /etc/ssh/ssh_known_hosts校验，跳板不在其中或密钥不一致时拒绝连接
+func sshJumpHostKey(fingerprint string) (ssh.HostKeyCallback, error) {
+	if fingerprint != "" {
+		if !strings.HasPrefix(fingerprint, "SHA256:") {
+			fingerprint = "SHA256:" + fingerprint
+		}
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if actual := ssh.FingerprintSHA256(key); actual != fingerprint {
+				return fmt.Errorf("跳板%s的主机密钥指纹为%s，与-ssh-jump-fingerprint指定的%s不一致", hostname, actual, fingerprint)
+			}
+			return nil
+		}, nil
+	}
+
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".ssh", "known_hosts"))
+	}
+	files = append(files, "/etc/ssh/ssh_known_hosts")
+	var existing []string
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	var check ssh.HostKeyCallback
+	if len(existing) > 0 {
+		var err error
+		check, err = knownhosts.New(existing...)
+		if err != nil {
+			return nil, fmt.Errorf("known_hosts解析失败: %v", err)
+		}
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if check != nil {
+			err := check(hostname, remote, key)
+			var keyErr *knownhosts.KeyError
+			if err == nil || !errors.As(err, &keyErr) {
+				return err
+			}
+			if len(keyErr.Want) > 0 {
+				return fmt.Errorf("跳板%s的主机密钥%s与known_hosts中的记录不一致，可能存在中间人攻击", hostname, ssh.FingerprintSHA256(key))
+			}
+		}
+		return fmt.Errorf("跳板%s不在known_hosts中，主机密钥为%s %s，核对后添加到~/.ssh/known_hosts或使用-ssh-jump-fingerprint指定",
+			hostname, key.Type(), ssh.FingerprintSHA256(key))
+	}, nil
+}
+
+func loadSSHKey(keyFile, passphrase string) (ssh.Signer, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("私钥%s已加密，请使用-ssh-jump-pass指定私钥密码", keyFile)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("私钥%s解析失败: %v", keyFile, err)
+	}
+	return signer, nil
+}
+
+func (j *SSHJumpDialer) String() string {
+	return "ssh://" + j.config.User + "@" + j.address
+}
+
+// Close 关闭本地SOCKS5服务与全部SSH连接
+func (j *SSHJumpDialer) Close() {
+	j.closed.Store(true)
+	if j.bridge != nil {
+		j.bridge.Close()
+	}
+	for _, slot := range j.slots {
+		slot.lock.Lock()
+		if slot.client != nil {
+			slot.client.Close()
+			slot.client = nil
+		}
+		slot.lock.Unlock()
+	}
+}
+
+// get 返回该位置的SSH连接，尚未连接或已断开时重新连接
+func (s *sshJumpSlot) get(j *SSHJumpDialer) (*ssh.Client, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	if j.closed.Load() {
+		return nil, errors.New("SSH跳板已关闭")
+	}
+	client, err := j.connect()
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	go func() {
+		_ = client.Wait()
+		s.reset(client)
+	}()
+	return client, nil
+}
+
+// reset 关闭断开的连接，client已被替换时不处理
+func (s *sshJumpSlot) reset(client *ssh.Client) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.client == client {
+		s.client = nil
+		client.Close()
+	}
+}
+
+func (j *SSHJumpDialer) connect() (*ssh.Client, error) {
+	var conn net.Conn
+	var err error
+	if j.forward != nil {
+		conn, err = j.forward.DialContext(context.Background(), "tcp", j.address, &net.Dialer{Timeout: j.timeout})
+	} else {
+		conn, err = net.DialTimeout("tcp", j.address, j.timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 握手超时
+	_ = conn.SetDeadline(time.Now().Add(j.timeout))
+	c, channels, requests, err := ssh.NewClientConn(conn, j.address, j.config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, channels, requests), nil
+}
+
+// Dial 经跳板连接address，连接本地SOCKS5服务本身时直接连接
+func (j *SSHJumpDialer) Dial(network, address string) (net.Conn, error) {
+	if j.bridge != nil && address == j.bridge.Addr().String() {
+		return net.Dial(network, address)
+	}
+	return j.DialContext(context.Background(), network, address, &net.Dialer{})
+}
+
+// DialContext 经跳板连接address，ssh.Client不支持ctx，超时后放弃等待并关闭稍后建立的通道
+func (j *SSHJumpDialer) DialContext(ctx context.Context, network, address string, forward *net.Dialer) (net.Conn, error) {
+	if forward.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, forward.Timeout)
+		defer cancel()
+	}
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := j.dial(address)
+		done <- result{conn, err}
+	}()
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, fmt.Errorf("ssh跳板连接%s: %w", address, ctx.Err())
+	}
+}
+
+// dial 轮流使用各SSH连接打开通道，连接已断开时重新连接一次
+func (j *SSHJumpDialer) dial(address string) (net.Conn, error) {
+	slot := j.slots[atomic.AddUint32(&j.next, 1)%uint32(len(j.slots))]
+	var lastErr error
+	for i := 0; i < 2; i++ {
+		client, err := slot.get(j)
+		if err != nil {
+			return nil, fmt.Errorf("连接SSH跳板%s失败: %w", j.address, err)
+		}
+		conn, err := client.Dial("tcp", address)
+		if err == nil {
+			return newJumpConn(conn, address), nil
+		}
+		var openErr *ssh.OpenChannelError
+		if errors.As(err, &openErr) {
+			return nil, channelError(openErr)
+		}
+		slot.reset(client)
+		lastErr = err
+	}
+	return nil, fmt.Errorf("SSH跳板%s: %w", j.address, lastErr)
+}
+
+// channelError 跳板上连接被拒绝与超时分别对应端口关闭与超时
+func channelError(e *ssh.OpenChannelError) error {
+	message := strings.ToLower(e.Message)
+	switch {
+	case e.Reason == ssh.Prohibited:
+		return fmt.Errorf("ssh跳板不允许端口转发(AllowTcpForwarding): %s", e.Message)
+	case strings.Contains(message, "refused"):
+		return fmt.Errorf("ssh跳板: %w", syscall.ECONNREFUSED)
+	case strings.Contains(message, "timed out"):
+		return fmt.Errorf("ssh跳板: %w", os.ErrDeadlineExceeded)
+	}
+	return fmt.Errorf("ssh跳板: %v", e)
+}
+
+// jumpConn SSH通道不支持超时，经net.Pipe转发以支持gonmap等依赖的SetDeadline
+type jumpConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func newJumpConn(channel net.Conn, address string) net.Conn {
+	local, pipe := net.Pipe()
+	go func() {
+		_, _ = io.Copy(pipe, channel)
+		pipe.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(channel, pipe)
+		channel.Close()
+	}()
+	// 域名由跳板解析，只有目标为IP时才能给出远端地址
+	remote := channel.RemoteAddr()
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			p, _ := strconv.Atoi(port)
+			remote = &net.TCPAddr{IP: ip, Port: p}
+		}
+	}
+	return &jumpConn{Conn: local, remote: remote}
+}
+
+func (c *jumpConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// serveBridge 本地不需要认证的SOCKS5服务，httpx与Nuclei HTTP请求只支持代理URL，经此使用跳板
+func (j *SSHJumpDialer) serveBridge() {
+	for {
+		conn, err := j.bridge.Accept()
+		if err != nil {
+			return
+		}
+		go j.handleBridge(conn)
+	}
+}
+
+func (j *SSHJumpDialer) handleBridge(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(j.timeout))
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil || header[0] != 5 {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return
+	}
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+	if request[1] != 1 {
+		_, _ = conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make(net.IP, net.IPv4len)
+		if request[3] == 4 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = ip.String()
+	case 3:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return
+		}
+		name := make([]byte, size[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		_, _ = conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	remote, err := j.DialContext(context.Background(), "tcp", address, &net.Dialer{Timeout: j.timeout})
+	if err != nil {
+		reply := byte(4)
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			reply = 5
+		} else if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+			reply = 6
+		}
+		_, _ = conn.Write([]byte{5, reply, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer remote.Close()
+	if _, err = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	go func() {
+		_, _ = io.Copy(remote, conn)
+		remote.Close()
+	}()
+	_, _ = io.Copy(conn, remote)
+}
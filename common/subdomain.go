@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"dddd/config"
 	"dddd/structs"
 	"dddd/utils"
 	"github.com/projectdiscovery/dnsx/calldnsx"
@@ -25,7 +26,11 @@ func SubFinder(domain string) []string {
 		RemoveWildcard:     true,
 		DisableUpdateCheck: true,
 	})
-	err = runner.UnmarshalFrom("config/subfinder-config.yaml")
+	configFile, err := config.Path("subfinder-config.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = runner.UnmarshalFrom(configFile)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -44,20 +49,24 @@ func SubFinder(domain string) []string {
 	return strings.Split(string(data), "\n")
 }
 
-func GetSubDomain(domains []string) []string {
+func GetSubDomain(scan *structs.Scan, domains []string) []string {
 	var results []string
+	wordlist, err := config.Path("subdomains.txt")
+	if err != nil {
+		gologger.Error().Msgf("读取子域名字典失败: %v", err)
+	}
 	for _, domain := range domains {
 
 		// 爆破子域名
-		if !structs.GlobalConfig.NoSubdomainBruteForce {
-			br := calldnsx.CallDNSx(domain, structs.GlobalConfig.SubdomainBruteForceThreads)
+		if !scan.Config.NoSubdomainBruteForce && wordlist != "" {
+			br := calldnsx.CallDNSx(domain, wordlist, scan.Config.SubdomainBruteForceThreads)
 			for _, v := range br {
 				results = append(results, v)
 			}
 		}
 
 		// subfinder被动收集
-		if !structs.GlobalConfig.NoSubFinder {
+		if !scan.Config.NoSubFinder {
 			r := SubFinder(domain)
 			for _, v := range r {
 				results = append(results, v)
@@ -66,5 +75,5 @@ func GetSubDomain(domains []string) []string {
 
 	}
 
-	return utils.RemoveDuplicateElement(results)
+	return scan.FilterExcluded(utils.RemoveDuplicateElement(results))
 }
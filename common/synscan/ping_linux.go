@@ -0,0 +1,106 @@
+//go:build linux
+
+package synscan
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// ACKPing 向每个IP的端口发送ACK，收到RST即判定主机存活，可穿透只拦截SYN的无状态防火墙
+// 目标回复的RST序列号等于发出的确认号，以此校验响应
+func ACKPing(opts PingOptions) error {
+	conns, err := listen(opts.IPs)
+	if err != nil || len(conns) == 0 {
+		return err
+	}
+
+	p := &pinger{
+		opts:    opts,
+		srcPort: uint16(40000 + rand.Intn(20000)),
+		secret:  rand.Uint32(),
+		alive:   make(map[string]struct{}),
+	}
+
+	var wg sync.WaitGroup
+	for _, g := range conns {
+		wg.Add(1)
+		go func(conn net.PacketConn) {
+			defer wg.Done()
+			p.receive(conn)
+		}(g.conn)
+	}
+
+	limiter := newLimiter(opts.Rate)
+	for _, port := range opts.Ports {
+		for _, g := range conns {
+			for _, t := range g.targets {
+				_ = limiter.Wait(context.Background())
+				packet := buildTCP(t.src, t.ip, p.srcPort, uint16(port), 0, cookie(p.secret, t.ip, uint16(port)), flagACK)
+				p.sent.Store(t.ip.String(), time.Now())
+				writePacket(g.conn, packet, t.ip)
+			}
+		}
+	}
+	time.Sleep(opts.Wait)
+
+	for _, g := range conns {
+		_ = g.conn.Close()
+	}
+	wg.Wait()
+	return nil
+}
+
+type pinger struct {
+	opts    PingOptions
+	srcPort uint16
+	secret  uint32
+	sent    sync.Map
+
+	lock  sync.Mutex
+	alive map[string]struct{}
+}
+
+// receive 读取发往探测源端口的RST，直到套接字关闭
+func (p *pinger) receive(conn net.PacketConn) {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		r, ok := parseTCP(buf[:n])
+		if !ok || r.dstPort != p.srcPort || r.flags&flagRST == 0 {
+			continue
+		}
+		ipAddr, ok := addr.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+		ip := normalizeIP(ipAddr.IP)
+		if r.seq != cookie(p.secret, ip, r.srcPort) {
+			continue
+		}
+
+		host := ip.String()
+		p.lock.Lock()
+		_, found := p.alive[host]
+		p.alive[host] = struct{}{}
+		p.lock.Unlock()
+		if found || p.opts.OnAlive == nil {
+			continue
+		}
+		var elapsed time.Duration
+		if value, ok := p.sent.Load(host); ok {
+			elapsed = time.Since(value.(time.Time))
+		}
+		p.opts.OnAlive(host, elapsed)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package synscan
+
+// ACKPing 非Linux系统不支持原始套接字ACK探测
+func ACKPing(opts PingOptions) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,138 @@
+// Package synscan 基于原始套接字的SYN端口扫描与TCP ACK主机发现，仅支持Linux，需要root或CAP_NET_RAW权限
+package synscan
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"net"
+	"time"
+)
+
+// ErrNotSupported 当前系统不支持原始套接字探测
+var ErrNotSupported = errors.New("原始套接字探测仅支持Linux")
+
+// Options SYN扫描参数
+type Options struct {
+	IPs     []string
+	Ports   []int
+	Rate    int           // 每秒发包数
+	Retries int           // 未收到响应的端口重发次数
+	Wait    time.Duration // 发包结束后等待响应的时间
+	OnSent  func()        // 每个端口首次发包后调用，用于统计进度
+	OnOpen  func(hostPort string)
+	Skip    func(hostPort string) bool // 返回true的目标不发包，用于排除列表
+}
+
+// PingOptions TCP ACK主机发现参数
+type PingOptions struct {
+	IPs     []string
+	Ports   []int
+	Rate    int           // 每秒发包数
+	Wait    time.Duration // 发包结束后等待响应的时间
+	OnAlive func(ip string, rtt time.Duration)
+}
+
+// target 一个待扫描的IP及其出口地址
+type target struct {
+	ip  net.IP
+	src net.IP
+}
+
+// cookie 由目标地址与端口计算SYN序列号，用于校验SYN/ACK是否为本次扫描的响应
+func cookie(secret uint32, ip net.IP, port uint16) uint32 {
+	h := fnv.New32a()
+	var b [6]byte
+	binary.BigEndian.PutUint32(b[:4], secret)
+	binary.BigEndian.PutUint16(b[4:], port)
+	_, _ = h.Write(b[:])
+	_, _ = h.Write(ip)
+	return h.Sum32()
+}
+
+// sourceIP 通过路由表获取发往dst使用的本机地址，UDP的Dial不会发出数据包
+func sourceIP(dst net.IP) (net.IP, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(dst.String(), "53"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+const (
+	flagSYN = 0x02
+	flagRST = 0x04
+	flagACK = 0x10
+)
+
+// buildTCP 构造带MSS选项的TCP报文(不含IP头)，SYN扫描只设置seq，ACK探测只设置ack
+func buildTCP(src, dst net.IP, srcPort, dstPort uint16, seq, ack uint32, flags byte) []byte {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint16(b[0:], srcPort)
+	binary.BigEndian.PutUint16(b[2:], dstPort)
+	binary.BigEndian.PutUint32(b[4:], seq)
+	binary.BigEndian.PutUint32(b[8:], ack)
+	b[12] = 6 << 4 // 首部长度24字节
+	b[13] = flags
+	binary.BigEndian.PutUint16(b[14:], 64240)
+	b[20], b[21] = 2, 4 // MSS
+	binary.BigEndian.PutUint16(b[22:], 1460)
+	binary.BigEndian.PutUint16(b[16:], checksum(src, dst, b))
+	return b
+}
+
+// checksum 计算包含IPv4/IPv6伪首部的TCP校验和
+func checksum(src, dst net.IP, segment []byte) uint16 {
+	var pseudo []byte
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		pseudo = make([]byte, 12)
+		copy(pseudo[0:], src4)
+		copy(pseudo[4:], dst4)
+		pseudo[9] = 6
+		binary.BigEndian.PutUint16(pseudo[10:], uint16(len(segment)))
+	} else {
+		pseudo = make([]byte, 40)
+		copy(pseudo[0:], src.To16())
+		copy(pseudo[16:], dst.To16())
+		binary.BigEndian.PutUint32(pseudo[32:], uint32(len(segment)))
+		pseudo[39] = 6
+	}
+
+	var sum uint32
+	for _, data := range [][]byte{pseudo, segment} {
+		for i := 0; i+1 < len(data); i += 2 {
+			sum += uint32(data[i])<<8 | uint32(data[i+1])
+		}
+		if len(data)%2 == 1 {
+			sum += uint32(data[len(data)-1]) << 8
+		}
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// reply 解析后的TCP响应
+type reply struct {
+	srcPort uint16
+	dstPort uint16
+	seq     uint32
+	ack     uint32
+	flags   byte
+}
+
+// parseTCP 解析不含IP头的TCP报文
+func parseTCP(b []byte) (reply, bool) {
+	if len(b) < 20 {
+		return reply{}, false
+	}
+	return reply{
+		srcPort: binary.BigEndian.Uint16(b[0:]),
+		dstPort: binary.BigEndian.Uint16(b[2:]),
+		seq:     binary.BigEndian.Uint32(b[4:]),
+		ack:     binary.BigEndian.Uint32(b[8:]),
+		flags:   b[13],
+	}, true
+}
@@ -0,0 +1,236 @@
+//go:build linux
+
+package synscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"golang.org/x/time/rate"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Scan 对所有IP与端口发送SYN，返回收到SYN/ACK的ip:port。被扫描端回复的SYN/ACK由内核以RST结束，不建立连接
+func Scan(opts Options) ([]string, error) {
+	conns, err := listen(opts.IPs)
+	if err != nil || len(conns) == 0 {
+		return nil, err
+	}
+
+	s := &scanner{
+		opts:    opts,
+		srcPort: uint16(40000 + rand.Intn(20000)),
+		secret:  rand.Uint32(),
+		open:    make(map[string]struct{}),
+	}
+
+	var wg sync.WaitGroup
+	for _, g := range conns {
+		wg.Add(1)
+		go func(conn net.PacketConn) {
+			defer wg.Done()
+			s.receive(conn)
+		}(g.conn)
+	}
+
+	s.send(conns)
+
+	for _, g := range conns {
+		_ = g.conn.Close()
+	}
+	wg.Wait()
+	return s.results, nil
+}
+
+// listen 按地址族分组目标并打开原始套接字，没有可路由的目标时返回空
+func listen(ips []string) ([]*group, error) {
+	var targets4, targets6 []target
+	for _, each := range ips {
+		ip := net.ParseIP(each)
+		if ip == nil {
+			continue
+		}
+		src, err := sourceIP(ip)
+		if err != nil {
+			gologger.Debug().Msgf("%s 无可用路由: %v", each, err)
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			targets4 = append(targets4, target{ip: ip4, src: src.To4()})
+		} else {
+			targets6 = append(targets6, target{ip: ip.To16(), src: src.To16()})
+		}
+	}
+
+	var conns []*group
+	if len(targets4) > 0 {
+		conn, err := net.ListenPacket("ip4:tcp", "0.0.0.0")
+		if err != nil {
+			return nil, permissionError(err)
+		}
+		conns = append(conns, &group{conn: conn, targets: targets4})
+	}
+	if len(targets6) > 0 {
+		conn, err := net.ListenPacket("ip6:tcp", "::")
+		if err != nil {
+			for _, g := range conns {
+				_ = g.conn.Close()
+			}
+			return nil, permissionError(err)
+		}
+		conns = append(conns, &group{conn: conn, targets: targets6})
+	}
+	for _, g := range conns {
+		if ipConn, ok := g.conn.(*net.IPConn); ok {
+			_ = ipConn.SetReadBuffer(8 << 20)
+		}
+	}
+	return conns, nil
+}
+
+// newLimiter 按每秒发包数限速，rate<=0时不限速
+func newLimiter(r int) *rate.Limiter {
+	burst := r / 100
+	if burst < 1 {
+		burst = 1
+	}
+	limit := rate.Inf
+	if r > 0 {
+		limit = rate.Limit(r)
+	}
+	return rate.NewLimiter(limit, burst)
+}
+
+// group 同一地址族的目标共用一个原始套接字
+type group struct {
+	conn    net.PacketConn
+	targets []target
+}
+
+type scanner struct {
+	opts    Options
+	srcPort uint16
+	secret  uint32
+
+	lock    sync.Mutex
+	open    map[string]struct{}
+	results []string
+}
+
+func permissionError(err error) error {
+	if errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EPERM) {
+		return fmt.Errorf("原始套接字需要root或CAP_NET_RAW权限: %v", err)
+	}
+	return err
+}
+
+func (s *scanner) isOpen(hostPort string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.open[hostPort]
+	return ok
+}
+
+// send 按端口外层、IP内层的顺序发包，分散对单个主机的压力，未响应的端口按重试次数重发
+func (s *scanner) send(groups []*group) {
+	limiter := newLimiter(s.opts.Rate)
+
+	for round := 0; round <= s.opts.Retries; round++ {
+		for _, port := range s.opts.Ports {
+			for _, g := range groups {
+				for _, t := range g.targets {
+					hostPort := net.JoinHostPort(t.ip.String(), strconv.Itoa(port))
+					if s.opts.Skip != nil && s.opts.Skip(hostPort) {
+						if round == 0 && s.opts.OnSent != nil {
+							s.opts.OnSent()
+						}
+						continue
+					}
+					if round > 0 && s.isOpen(hostPort) {
+						continue
+					}
+					_ = limiter.Wait(context.Background())
+					packet := buildTCP(t.src, t.ip, s.srcPort, uint16(port), cookie(s.secret, t.ip, uint16(port)), 0, flagSYN)
+					writePacket(g.conn, packet, t.ip)
+					if round == 0 && s.opts.OnSent != nil {
+						s.opts.OnSent()
+					}
+				}
+			}
+		}
+		// 重发前只需短暂等待，最后一轮等待完整的响应时间
+		wait := s.opts.Wait
+		if round < s.opts.Retries && wait > time.Second {
+			wait = time.Second
+		}
+		time.Sleep(wait)
+	}
+}
+
+// normalizeIP IPv4地址统一为4字节，与计算cookie时一致
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// writePacket 发送缓冲区满时稍等后重试
+func writePacket(conn net.PacketConn, packet []byte, ip net.IP) {
+	for i := 0; i < 3; i++ {
+		_, err := conn.WriteTo(packet, &net.IPAddr{IP: ip})
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, syscall.ENOBUFS) {
+			gologger.Debug().Msgf("SYN发包失败 %s: %v", ip, err)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// receive 读取发往扫描源端口的SYN/ACK，直到套接字关闭
+func (s *scanner) receive(conn net.PacketConn) {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		r, ok := parseTCP(buf[:n])
+		if !ok || r.dstPort != s.srcPort || r.flags&(flagSYN|flagACK) != flagSYN|flagACK {
+			continue
+		}
+		ipAddr, ok := addr.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+		ip := normalizeIP(ipAddr.IP)
+		if r.ack-1 != cookie(s.secret, ip, r.srcPort) {
+			continue
+		}
+
+		hostPort := net.JoinHostPort(ip.String(), strconv.Itoa(int(r.srcPort)))
+		s.lock.Lock()
+		_, found := s.open[hostPort]
+		if !found {
+			s.open[hostPort] = struct{}{}
+			s.results = append(s.results, hostPort)
+		}
+		s.lock.Unlock()
+		if !found && s.opts.OnOpen != nil {
+			s.opts.OnOpen(hostPort)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package synscan
+
+// Scan 非Linux系统不支持原始套接字SYN扫描
+func Scan(opts Options) ([]string, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"dddd/common/report"
+	"dddd/structs"
+	"github.com/lcvvvv/gonmap"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// PortUDPDefault 默认UDP扫描端口，均有对应的UDP探针
+var PortUDPDefault = "53,69,111,123,137,161,389,623,1434,5060,5353,11211"
+
+// PortScanUDP 使用gonmap的UDP探针扫描，收到响应的端口同时完成服务识别，结果写入IPPortMap
+// 返回开放端口在IPPortMap中的键 udp/IP:Port
+func PortScanUDP(scan *structs.Scan, IPs []string, Ports string, threads int) []string {
+	probePorts := ParsePort(Ports)
+	var hostPorts []string
+	for _, port := range probePorts {
+		for _, ip := range IPs {
+			hostPort := net.JoinHostPort(ip, strconv.Itoa(port))
+			if scan.IsExcluded(hostPort) {
+				continue
+			}
+			hostPorts = append(hostPorts, hostPort)
+		}
+	}
+	if len(hostPorts) == 0 {
+		return nil
+	}
+	if threads <= 0 || threads > len(hostPorts) {
+		threads = len(hostPorts)
+	}
+
+	gologger.Info().Msg("UDP端口扫描")
+	stage := scan.Progress.Start("UDP端口扫描", len(hostPorts))
+	defer stage.Finish()
+
+	var results []string
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	addrs := make(chan string, threads)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scanner := gonmap.New()
+			for hostPort := range addrs {
+				key, ok := scanUDP(scan, scanner, hostPort)
+				stage.Add(1)
+				if !ok {
+					continue
+				}
+				lock.Lock()
+				results = append(results, key)
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, hostPort := range hostPorts {
+		addrs <- hostPort
+	}
+	close(addrs)
+	wg.Wait()
+	return results
+}
+
+// scanUDP 探测单个UDP端口，有响应时记录服务
+func scanUDP(scan *structs.Scan, scanner *gonmap.Nmap, hostPort string) (string, bool) {
+	ip, p, _ := net.SplitHostPort(hostPort)
+	port, _ := strconv.Atoi(p)
+	status, response := scanner.ScanUDP(ip, port)
+	if (status != gonmap.Matched && status != gonmap.NotMatched) || response == nil {
+		return "", false
+	}
+
+	key := structs.UDPServiceKey(hostPort)
+	service := response.FingerPrint.Service
+	scan.BannerHMap.Set(key, []byte(response.Raw))
+	scan.IPPortMapLock.Lock()
+	scan.IPPortMap[key] = service
+	scan.IPPortMapLock.Unlock()
+
+	report.AddRecord(scan, report.RecordPort, structs.PortRecord{IP: ip, Port: port, Transport: "udp"})
+	if service == "" {
+		gologger.Silent().Msgf("[PortScan] %v%v", structs.UDPServicePrefix, hostPort)
+		return key, true
+	}
+	detail := serviceFingerprint(response.FingerPrint)
+	scan.SetServiceFingerprint(key, detail)
+	logService(structs.UDPServicePrefix+service+"://"+hostPort, detail)
+	record := serviceRecord(ip, port, service, detail)
+	record.Transport = "udp"
+	report.AddRecord(scan, report.RecordService, record)
+	return key, true
+}
@@ -1,10 +1,12 @@
 package uncover
 
 import (
+	"dddd/config"
 	"dddd/structs"
 	"dddd/utils"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/retryablehttp-go"
@@ -13,7 +15,6 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 )
@@ -27,12 +28,11 @@ type FOFAResponseJson struct {
 	Size    int        `json:"size"`
 }
 
-func getFOFAKeys() []string {
+func getFOFAKeys() ([]string, error) {
 	var apiKeys []string
-	f, err := os.Open("config/subfinder-config.yaml")
+	f, err := config.FS().Open("subfinder-config.yaml")
 	if err != nil {
-		gologger.Fatal().Msg("打开API Key配置文件config/subfinder-config.yaml失败")
-		return []string{}
+		return nil, fmt.Errorf("打开API Key配置文件subfinder-config.yaml失败: %v", err)
 	}
 	defer f.Close()
 
@@ -46,23 +46,25 @@ func getFOFAKeys() []string {
 		}
 	}
 	if len(apiKeys) == 0 {
-		gologger.Fatal().Msg("未获取到FOFA API Key")
-		return []string{}
+		return nil, errors.New("未获取到FOFA API Key")
 	}
 
-	return apiKeys
+	return apiKeys, nil
 }
 
 // 从Fofa中搜索目标
-func SearchFOFACore(keyword string, pageSize int) []string {
+func SearchFOFACore(scan *structs.Scan, keyword string, pageSize int) ([]string, error) {
 	opts := retryablehttp.DefaultOptionsSpraying
 	client := retryablehttp.NewClient(opts)
 
 	url := "https://fofa.info/api/v1/search/all"
-	keys := getFOFAKeys()
+	keys, err := getFOFAKeys()
+	if err != nil {
+		return nil, err
+	}
 	randKey := keys[rand.Intn(len(keys))]
 	if !strings.Contains(randKey, ":") {
-		gologger.Fatal().Msg("请核对FOFA API KEY格式。正确格式为: email:key")
+		return nil, errors.New("请核对FOFA API KEY格式。正确格式为: email:key")
 	}
 	tmp := strings.Split(randKey, ":")
 	email := tmp[0]
@@ -70,7 +72,7 @@ func SearchFOFACore(keyword string, pageSize int) []string {
 
 	req, err := retryablehttp.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		gologger.Fatal().Msgf("FOFA API请求构建失败。")
+		return nil, fmt.Errorf("FOFA API请求构建失败: %v", err)
 	}
 	unc := keyword
 	search := base64.URLEncoding.EncodeToString([]byte(unc))
@@ -92,30 +94,30 @@ func SearchFOFACore(keyword string, pageSize int) []string {
 	resp, errDo := client.Do(req)
 	if errDo != nil {
 		gologger.Error().Msgf("[Fofa] [%s] 资产查询失败！请检查网络状态。Error:%s", keyword, errDo.Error())
-		return results
+		return results, nil
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		gologger.Error().Msgf("[Fofa] 获取Hunter 响应Body失败: %v", err.Error())
-		return results
+		return results, nil
 	}
 
 	var responseJson FOFAResponseJson
 	if err = json.Unmarshal(data, &responseJson); err != nil {
 		gologger.Error().Msgf("[Fofa] 返回数据Json解析失败! Error:%s", err.Error())
-		return results
+		return results, nil
 	}
 
 	if responseJson.Error {
 		gologger.Error().Msgf("[Fofa] [%s] 搜索失败！返回响应体Error为True。", keyword)
-		return results
+		return results, nil
 	}
 
 	if responseJson.Size == 0 {
 		gologger.Error().Msgf("[Fofa] [%s] 无结果。", keyword)
-		return results
+		return results, nil
 	}
 
 	for _, result := range responseJson.Results {
@@ -123,6 +125,9 @@ func SearchFOFACore(keyword string, pageSize int) []string {
 		protocol := result[1]
 		icp := result[2]
 		title := result[3]
+		if scan.IsExcluded(host) {
+			continue
+		}
 
 		show := "[Fofa]"
 		if protocol == "http" {
@@ -147,16 +152,19 @@ func SearchFOFACore(keyword string, pageSize int) []string {
 
 	gologger.Info().Msgf("[Fofa] [%s] 已查询: %d/%d", keyword, len(responseJson.Results), responseJson.Size)
 
-	return results
+	return results, nil
 }
 
-func FOFASearch(keywords []string) []string {
+func FOFASearch(scan *structs.Scan, keywords []string) ([]string, error) {
 	gologger.Info().Msgf("准备从 Fofa 获取数据")
 	var results []string
 	for _, keyword := range keywords {
-		result := SearchFOFACore(keyword,
-			structs.GlobalConfig.FofaMaxCount)
+		result, err := SearchFOFACore(scan, keyword,
+			scan.Config.FofaMaxCount)
+		if err != nil {
+			return nil, err
+		}
 		results = append(results, result...)
 	}
-	return utils.RemoveDuplicateElement(results)
+	return utils.RemoveDuplicateElement(results), nil
 }
@@ -1,10 +1,12 @@
 package uncover
 
 import (
+	"dddd/config"
 	"dddd/structs"
 	"dddd/utils"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/httpx/common/hashes"
@@ -13,8 +15,8 @@ import (
 	"gopkg.in/yaml.v3"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -47,12 +49,11 @@ type hunterData struct {
 	RestQuota string    `json:"rest_quota"`
 }
 
-func getHunterKeys() []string {
+func getHunterKeys() ([]string, error) {
 	var apiKeys []string
-	f, err := os.Open("config/subfinder-config.yaml")
+	f, err := config.FS().Open("subfinder-config.yaml")
 	if err != nil {
-		gologger.Fatal().Msg("打开API Key配置文件config/subfinder-config.yaml失败")
-		return []string{}
+		return nil, fmt.Errorf("打开API Key配置文件subfinder-config.yaml失败: %v", err)
 	}
 	defer f.Close()
 
@@ -66,20 +67,22 @@ func getHunterKeys() []string {
 		}
 	}
 	if len(apiKeys) == 0 {
-		gologger.Fatal().Msg("未获取到Hunter API Key")
-		return []string{}
+		return nil, errors.New("未获取到Hunter API Key")
 	}
 
-	return apiKeys
+	return apiKeys, nil
 }
 
 // SearchHunter 从Hunter中搜索目标
-func SearchHunterCore(keyword string, pageSize int, maxQueryPage int) ([]string, []string) {
+func SearchHunterCore(scan *structs.Scan, keyword string, pageSize int, maxQueryPage int) ([]string, []string, error) {
 	opts := retryablehttp.DefaultOptionsSpraying
 	client := retryablehttp.NewClient(opts)
 
 	url := "https://hunter.qianxin.com/openApi/search"
-	keys := getHunterKeys()
+	keys, err := getHunterKeys()
+	if err != nil {
+		return nil, nil, err
+	}
 	randKey := keys[rand.Intn(len(keys))]
 
 	page := 1
@@ -90,7 +93,7 @@ func SearchHunterCore(keyword string, pageSize int, maxQueryPage int) ([]string,
 	for page <= maxQueryPage {
 		req, err := retryablehttp.NewRequest(http.MethodGet, url, nil)
 		if err != nil {
-			gologger.Fatal().Msgf("Hunter API请求构建失败。")
+			return results, ipResult, fmt.Errorf("Hunter API请求构建失败: %v", err)
 		}
 		unc := keyword
 		search := base64.URLEncoding.EncodeToString([]byte(unc))
@@ -137,31 +140,34 @@ func SearchHunterCore(keyword string, pageSize int, maxQueryPage int) ([]string,
 				time.Sleep(time.Second * 3)
 				continue
 			}
-			return results, ipResult
+			return results, ipResult, nil
 		}
 
 		if responseJson.Data.Total == 0 {
 			gologger.Error().Msgf("[Hunter] %s 无结果。", keyword)
-			return results, ipResult
+			return results, ipResult, nil
 		}
 
 		for _, v := range responseJson.Data.InfoArr {
+			if scan.IsExcluded(net.JoinHostPort(v.IP, strconv.Itoa(v.Port))) || (v.URL != "" && scan.IsExcluded(v.URL)) {
+				continue
+			}
 			if v.IsWeb == "是" {
 				gologger.Silent().Msgf("[Hunter] [%d] %s [%s] [%s] [%s]", v.Code, v.URL, v.Title, v.City, v.Company)
 
-				if structs.GlobalConfig.LowPerceptionMode {
+				if scan.Config.LowPerceptionMode {
 					rootURL := fmt.Sprintf("%s://%s:%d", v.Protocol, v.IP, v.Port)
 
-					structs.GlobalURLMapLock.Lock()
-					_, rootURLOK := structs.GlobalURLMap[rootURL]
-					structs.GlobalURLMapLock.Unlock()
+					scan.URLMapLock.Lock()
+					_, rootURLOK := scan.URLMap[rootURL]
+					scan.URLMapLock.Unlock()
 					if !rootURLOK {
 						responseCode, header, body, server, contentType, contentLen := utils.ExtractResponse(v.Banner)
 
 						md5 := hashes.Md5([]byte(body))
 						headerMd5 := hashes.Md5([]byte(header))
-						_ = structs.GlobalHttpBodyHMap.Set(md5, []byte(body))
-						_ = structs.GlobalHttpHeaderHMap.Set(headerMd5, []byte(header))
+						_ = scan.HttpBodyHMap.Set(md5, []byte(body))
+						_ = scan.HttpHeaderHMap.Set(headerMd5, []byte(header))
 
 						l, e := strconv.Atoi(contentLen)
 						if e != nil {
@@ -194,28 +200,28 @@ func SearchHunterCore(keyword string, pageSize int, maxQueryPage int) ([]string,
 						urlE.WebPaths = make(map[string]structs.UrlPathEntity)
 						urlE.WebPaths["/"] = webPath
 
-						structs.GlobalURLMapLock.Lock()
-						structs.GlobalURLMap[rootURL] = urlE
-						structs.GlobalURLMapLock.Unlock()
+						scan.URLMapLock.Lock()
+						scan.URLMap[rootURL] = urlE
+						scan.URLMapLock.Unlock()
 					}
 				} else {
 					results = append(results, v.URL)
 				}
 			} else {
 				gologger.Silent().Msgf("[Hunter] %s://%s:%d", v.Protocol, v.IP, v.Port)
-				if structs.GlobalConfig.LowPerceptionMode {
-					hostPort := fmt.Sprintf("%s:%d", v.IP, v.Port)
-					structs.GlobalIPPortMapLock.Lock()
-					_, ok := structs.GlobalIPPortMap[hostPort]
-					structs.GlobalIPPortMapLock.Unlock()
+				if scan.Config.LowPerceptionMode {
+					hostPort := net.JoinHostPort(v.IP, strconv.Itoa(v.Port))
+					scan.IPPortMapLock.Lock()
+					_, ok := scan.IPPortMap[hostPort]
+					scan.IPPortMapLock.Unlock()
 					if !ok {
-						structs.GlobalBannerHMap.Set(hostPort, []byte(v.Banner))
-						structs.GlobalIPPortMapLock.Lock()
-						structs.GlobalIPPortMap[hostPort] = v.Protocol
-						structs.GlobalIPPortMapLock.Unlock()
+						scan.BannerHMap.Set(hostPort, []byte(v.Banner))
+						scan.IPPortMapLock.Lock()
+						scan.IPPortMap[hostPort] = v.Protocol
+						scan.IPPortMapLock.Unlock()
 					}
 				} else {
-					results = append(results, fmt.Sprintf("%s:%v", v.IP, v.Port))
+					results = append(results, net.JoinHostPort(v.IP, strconv.Itoa(v.Port)))
 				}
 			}
 			ipResult = append(ipResult, v.IP)
@@ -226,7 +232,7 @@ func SearchHunterCore(keyword string, pageSize int, maxQueryPage int) ([]string,
 			responseJson.Data.Total, responseJson.Data.RestQuota)
 
 		if currentQueryCount >= responseJson.Data.Total {
-			return results, ipResult
+			return results, ipResult, nil
 		}
 
 		page += 1
@@ -235,19 +241,22 @@ func SearchHunterCore(keyword string, pageSize int, maxQueryPage int) ([]string,
 		time.Sleep(time.Second * 3)
 
 	}
-	return results, ipResult
+	return results, ipResult, nil
 }
 
-func HunterSearch(keywords []string) ([]string, []string) {
+func HunterSearch(scan *structs.Scan, keywords []string) ([]string, []string, error) {
 	gologger.Info().Msgf("准备从 Hunter 获取数据")
 	var results []string
 	var ipResults []string
 	for _, keyword := range keywords {
-		result, ipResult := SearchHunterCore(keyword,
-			structs.GlobalConfig.HunterPageSize,
-			structs.GlobalConfig.HunterMaxPageCount)
+		result, ipResult, err := SearchHunterCore(scan, keyword,
+			scan.Config.HunterPageSize,
+			scan.Config.HunterMaxPageCount)
+		if err != nil {
+			return nil, nil, err
+		}
 		results = append(results, result...)
 		ipResults = append(ipResults, ipResult...)
 	}
-	return utils.RemoveDuplicateElement(results), utils.RemoveDuplicateElement(ipResults)
+	return utils.RemoveDuplicateElement(results), utils.RemoveDuplicateElement(ipResults), nil
 }
@@ -1,9 +1,11 @@
 package uncover
 
 import (
+	"dddd/config"
 	"dddd/structs"
 	"dddd/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/retryablehttp-go"
@@ -11,15 +13,13 @@ import (
 	"gopkg.in/yaml.v3"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
-var IsVIP bool
-
 type QuakeServiceInfo struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -90,12 +90,11 @@ type QuakeServiceInfo struct {
 	} `json:"meta"`
 }
 
-func getQuakeKeys() []string {
+func getQuakeKeys() ([]string, error) {
 	var apiKeys []string
-	f, err := os.Open("config/subfinder-config.yaml")
+	f, err := config.FS().Open("subfinder-config.yaml")
 	if err != nil {
-		gologger.Fatal().Msg("打开API Key配置文件config/subfinder-config.yaml失败")
-		return []string{}
+		return nil, fmt.Errorf("打开API Key配置文件subfinder-config.yaml失败: %v", err)
 	}
 	defer f.Close()
 
@@ -109,34 +108,36 @@ func getQuakeKeys() []string {
 		}
 	}
 	if len(apiKeys) == 0 {
-		gologger.Fatal().Msg("未获取到Quake API Key")
-		return []string{}
+		return nil, errors.New("未获取到Quake API Key")
 	}
 
-	return apiKeys
+	return apiKeys, nil
 }
 
 // 从Fofa中搜索目标
-func SearchQuakeCore(keyword string, pageSize int) []string {
+func SearchQuakeCore(scan *structs.Scan, keyword string, pageSize int, isVIP bool) ([]string, error) {
 	opts := retryablehttp.DefaultOptionsSpraying
 	client := retryablehttp.NewClient(opts)
 
 	url := "https://quake.360.net/api/v3/search/quake_service"
-	keys := getQuakeKeys()
+	keys, err := getQuakeKeys()
+	if err != nil {
+		return nil, err
+	}
 	randKey := keys[rand.Intn(len(keys))]
 
 	data := make(map[string]interface{})
 	data["query"] = keyword
 	data["start"] = "0"
 	data["size"] = strconv.Itoa(pageSize)
-	if !IsVIP {
+	if !isVIP {
 		data["include"] = []string{"ip", "port"}
 	}
 	jsonData, _ := json.Marshal(data)
 
 	req, err := retryablehttp.NewRequest(http.MethodPost, url, jsonData)
 	if err != nil {
-		gologger.Fatal().Msgf("Quake API请求构建失败。")
+		return nil, fmt.Errorf("Quake API请求构建失败: %v", err)
 	}
 	req.Header.Set("X-QuakeToken", randKey)
 	req.Header.Set("Content-Type", "application/json")
@@ -148,18 +149,18 @@ func SearchQuakeCore(keyword string, pageSize int) []string {
 	resp, errDo := client.Do(req)
 	if errDo != nil {
 		gologger.Error().Msgf("[Quake] [%s] 资产查询失败！请检查网络状态。Error:%s", keyword, errDo.Error())
-		return results
+		return results, nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 401 {
-		gologger.Fatal().Msgf("[Quake] API-KEY错误。请检查。")
+		return nil, errors.New("[Quake] API-KEY错误。请检查。")
 	}
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		gologger.Error().Msgf("[Quake] 获取Hunter 响应Body失败: %v", err.Error())
-		return results
+		return results, nil
 	}
 
 	var serviceInfo QuakeServiceInfo
@@ -167,18 +168,24 @@ func SearchQuakeCore(keyword string, pageSize int) []string {
 	if err != nil {
 		gologger.Error().Msg("[Quake] 响应解析失败，疑似Token失效、。Quake接口具体返回信息如下：")
 		fmt.Println(string(respBody))
-		return results
+		return results, nil
 	}
 
 	for _, d := range serviceInfo.Data {
+		if scan.IsExcluded(net.JoinHostPort(d.IP, strconv.Itoa(d.Port))) {
+			continue
+		}
 		if d.Service.HTTP.URL == nil {
-			t := fmt.Sprintf("%s:%d", d.IP, d.Port)
+			t := net.JoinHostPort(d.IP, strconv.Itoa(d.Port))
 			if utils.GetItemInArray(results, t) == -1 {
 				gologger.Silent().Msgf("[Quake] %s", t)
 				results = append(results, t)
 			}
 		} else {
 			for _, u := range d.Service.HTTP.URL {
+				if scan.IsExcluded(u) {
+					continue
+				}
 				if utils.GetItemInArray(results, u) == -1 {
 					gologger.Silent().Msgf("[Quake] %s", u)
 					results = append(results, u)
@@ -187,11 +194,14 @@ func SearchQuakeCore(keyword string, pageSize int) []string {
 		}
 
 	}
-	return results
+	return results, nil
 }
 
-func IsQuakeVIP() bool {
-	keys := getQuakeKeys()
+func IsQuakeVIP() (bool, error) {
+	keys, err := getQuakeKeys()
+	if err != nil {
+		return false, err
+	}
 	randKey := keys[rand.Intn(len(keys))]
 	opts := retryablehttp.DefaultOptionsSpraying
 	client := retryablehttp.NewClient(opts)
@@ -200,42 +210,44 @@ func IsQuakeVIP() bool {
 
 	req, err := retryablehttp.NewRequest(http.MethodGet, url, "")
 	if err != nil {
-		gologger.Fatal().Msgf("Quake API请求构建失败。")
+		return false, fmt.Errorf("Quake API请求构建失败: %v", err)
 	}
 	req.Header.Set("X-QuakeToken", randKey)
 	req.Header.Set("Content-Type", "application/json")
 	resp, errDo := client.Do(req)
 	if errDo != nil {
 		gologger.Error().Msgf("[Quake] 用户信息查询失败！请检查网络状态。Error:%s", errDo.Error())
-		return false
+		return false, nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 401 {
-		gologger.Fatal().Msgf("[Quake] API-KEY错误。请检查。")
+		return false, errors.New("[Quake] API-KEY错误。请检查。")
 	}
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		gologger.Error().Msgf("[Quake] 获取Hunter 响应Body失败: %v", err.Error())
-		return false
+		return false, nil
 	}
 
 	if strings.Contains(string(respBody), "终身会员") {
-		return true
+		return true, nil
 	}
 	if strings.Contains(string(respBody), "高级会员") {
-		return true
+		return true, nil
 	}
 
-	return false
+	return false, nil
 }
 
-func QuakeSearch(keywords []string) []string {
-	IsVIP = false
+func QuakeSearch(scan *structs.Scan, keywords []string) ([]string, error) {
 	gologger.Info().Msg("正在查询Quake账户权限。")
-	IsVIP = IsQuakeVIP()
-	if IsVIP {
+	isVIP, err := IsQuakeVIP()
+	if err != nil {
+		return nil, err
+	}
+	if isVIP {
 		gologger.Info().Msgf("VIP")
 	} else {
 		gologger.Info().Msgf("非VIP")
@@ -243,9 +255,12 @@ func QuakeSearch(keywords []string) []string {
 	gologger.Info().Msgf("准备从 Quake 获取数据")
 	var results []string
 	for _, keyword := range keywords {
-		result := SearchQuakeCore(keyword,
-			structs.GlobalConfig.QuakeSize)
+		result, err := SearchQuakeCore(scan, keyword,
+			scan.Config.QuakeSize, isVIP)
+		if err != nil {
+			return nil, err
+		}
 		results = append(results, result...)
 	}
-	return utils.RemoveDuplicateElement(results)
+	return utils.RemoveDuplicateElement(results), nil
 }
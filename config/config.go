@@ -0,0 +1,148 @@
+// Package config 定位指纹、工作流、字典与POC等配置文件。
+//
+// 配置来源的优先级为: -config参数 > DDDD_HOME环境变量 > 内置配置(使用 -tags embed 构建时) >
+// 当前目录下的config > 程序所在目录下的config
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// EnvHome 指定dddd所在目录的环境变量，配置目录为其下的config
+const EnvHome = "DDDD_HOME"
+
+// embedded 内置配置，仅在 -tags embed 构建时不为nil
+var embedded fs.FS
+
+var (
+	dir       string
+	fsys      fs.FS
+	extracted = make(map[string]string)
+	lock      sync.Mutex
+)
+
+// Init 确定配置来源，configDir为-config参数的值
+func Init(configDir string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	dir, fsys = "", nil
+	switch {
+	case configDir != "":
+		dir = configDir
+	case os.Getenv(EnvHome) != "":
+		dir = filepath.Join(os.Getenv(EnvHome), "config")
+	case embedded != nil:
+		fsys = embedded
+		return nil
+	default:
+		dir = "config"
+		if !isDir(dir) {
+			if exe, err := os.Executable(); err == nil {
+				if exe, err = filepath.EvalSymlinks(exe); err == nil {
+					dir = filepath.Join(filepath.Dir(exe), "config")
+				}
+			}
+		}
+	}
+
+	if !isDir(dir) {
+		return fmt.Errorf("配置目录%s不存在，请使用-config或环境变量%s指定", dir, EnvHome)
+	}
+	abs, err := filepath.Abs(dir)
+	if err == nil {
+		dir = abs
+	}
+	fsys = os.DirFS(dir)
+	return nil
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+// Embedded 当前是否使用内置配置
+func Embedded() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return fsys != nil && dir == ""
+}
+
+// Dir 配置目录的绝对路径，使用内置配置时为空
+func Dir() string {
+	lock.Lock()
+	defer lock.Unlock()
+	return dir
+}
+
+// Source 用于日志显示的配置来源
+func Source() string {
+	if Embedded() {
+		return "内置配置"
+	}
+	return Dir()
+}
+
+// FS 配置文件系统，路径为相对于配置目录的/分隔路径，如 dict/ssh.txt
+func FS() fs.FS {
+	lock.Lock()
+	defer lock.Unlock()
+	if fsys == nil {
+		// 未调用Init时保持旧行为，从当前目录下的config读取
+		return os.DirFS("config")
+	}
+	return fsys
+}
+
+// ReadFile 读取配置文件，name如 finger.yaml、dict/ssh.txt
+func ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(FS(), name)
+}
+
+// Path 返回配置文件在磁盘上的路径，供只接受文件路径的第三方库使用。
+// 使用内置配置时将文件释放到临时目录
+func Path(name string) (string, error) {
+	if !Embedded() {
+		d := Dir()
+		if d == "" {
+			d = "config"
+		}
+		return filepath.Join(d, filepath.FromSlash(name)), nil
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if p, ok := extracted[name]; ok {
+		return p, nil
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp("", "dddd-*-"+filepath.Base(name))
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	if _, err = tmp.Write(data); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	extracted[name] = tmp.Name()
+	return tmp.Name(), nil
+}
+
+// Cleanup 删除Path释放的临时文件
+func Cleanup() {
+	lock.Lock()
+	defer lock.Unlock()
+	for name, p := range extracted {
+		_ = os.Remove(p)
+		delete(extracted, name)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build embed
+
+package config
+
+import "embed"
+
+// 使用 go build -tags embed 构建时将默认配置(指纹、工作流、字典、POC)打包进可执行文件
+//
+//go:embed dict pocs probes dir.yaml finger.yaml ports.yaml subdomains.txt subfinder-config.yaml workflow.yaml
+var embeddedFS embed.FS
+
+func init() {
+	embedded = embeddedFS
+}
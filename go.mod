@@ -10,9 +10,11 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/projectdiscovery/dnsx v1.1.5
 	github.com/projectdiscovery/gologger v1.1.11
+	github.com/projectdiscovery/tlsx v1.1.6-0.20231016194953-a3ff9518c766
 	github.com/satori/go.uuid v1.2.0
 	github.com/sijms/go-ora/v2 v2.7.9
 	github.com/tomatome/grdp v0.1.0
+	golang.org/x/time v0.3.0
 )
 
 require (
@@ -166,7 +168,6 @@ require (
 	github.com/projectdiscovery/rawhttp v0.1.23 // indirect
 	github.com/projectdiscovery/rdap v0.9.1-0.20221108103045-9865884d1917 // indirect
 	github.com/projectdiscovery/sarif v0.0.1 // indirect
-	github.com/projectdiscovery/tlsx v1.1.6-0.20231016194953-a3ff9518c766 // indirect
 	github.com/projectdiscovery/uncover v1.0.7 // indirect
 	github.com/projectdiscovery/wappalyzergo v0.0.109 // indirect
 	github.com/projectdiscovery/yamldoc-go v1.0.4 // indirect
@@ -211,7 +212,6 @@ require (
 	goftp.io/server/v2 v2.0.1 // indirect
 	golang.org/x/arch v0.3.0 // indirect
 	golang.org/x/term v0.13.0 // indirect
-	golang.org/x/time v0.3.0 // indirect
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6 // indirect
 	gopkg.in/corvus-ch/zbase32.v1 v1.0.0 // indirect
 	gopkg.in/src-d/go-git.v4 v4.13.1 // indirect
@@ -294,7 +294,7 @@ require (
 	github.com/yl2chen/cidranger v1.0.2 // indirect
 	github.com/yuin/goldmark v1.5.4 // indirect
 	github.com/yuin/goldmark-emoji v1.0.1 // indirect
-	go.etcd.io/bbolt v1.3.7 // indirect
+	go.etcd.io/bbolt v1.3.7
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/crypto v0.14.0
 	golang.org/x/exp v0.0.0-20231006140011-7918f672742d // indirect
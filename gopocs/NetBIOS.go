@@ -20,14 +20,14 @@ func NetBIOS(info *structs.HostInfo) error {
 	netbios, _ := NetBIOS1(info)
 	output := netbios.String()
 	if len(output) > 0 {
-		realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+		realhost := net.JoinHostPort(info.Host, info.Ports)
 		result := fmt.Sprintf("NetBios: %s %s ", info.Host, output)
 
 		gologger.Silent().Msg("[GoPoc] " + result)
 
 		showData := fmt.Sprintf("Host: %v\nInfo: %v", realhost, output)
 
-		GoPocWriteResult(structs.GoPocsResultType{
+		GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 			PocName:     "NetBIOS-Leak",
 			Security:    "INFO",
 			Target:      realhost,
@@ -52,7 +52,7 @@ func NetBIOS1(info *structs.HostInfo) (netbios NetBiosInfo, err error) {
 		payload0 = append(payload0, name...)
 		payload0 = append(payload0, []byte("\x00 EOENEBFACACACACACACACACACACACACA\x00")...)
 	}
-	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	realhost := net.JoinHostPort(info.Host, info.Ports)
 	var conn net.Conn
 	conn, err = common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
 	defer func() {
@@ -105,8 +105,8 @@ func NetBIOS1(info *structs.HostInfo) (netbios NetBiosInfo, err error) {
 func GetNbnsname(info *structs.HostInfo) (netbios NetBiosInfo, err error) {
 	senddata1 := []byte{102, 102, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 32, 67, 75, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 0, 0, 33, 0, 1}
 	//senddata1 := []byte("ff\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00 CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\x00\x00!\x00\x01")
-	realhost := fmt.Sprintf("%s:137", info.Host)
-	conn, err := net.DialTimeout("udp", realhost, time.Duration(6)*time.Second)
+	realhost := net.JoinHostPort(info.Host, "137")
+	conn, err := common.WrapperTcpWithTimeout("udp", realhost, time.Duration(6)*time.Second)
 	defer func() {
 		if conn != nil {
 			conn.Close()
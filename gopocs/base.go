@@ -5,13 +5,12 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"dddd/common/report"
+	"dddd/config"
 	"dddd/structs"
 	"dddd/utils"
 	"encoding/base64"
 	"net"
-	"os"
 	"strings"
-	"sync"
 )
 
 var PluginList = map[string]interface{}{
@@ -34,8 +33,6 @@ var PluginList = map[string]interface{}{
 	"Shiro-Key-Crack":     ShiroKeyCheck,
 }
 
-var WriteResultLock sync.Mutex
-
 func ReadBytes(conn net.Conn) (result []byte, err error) {
 	size := 4096
 	buf := make([]byte, size)
@@ -208,23 +205,25 @@ func CheckErrs(err error) bool {
 	return false
 }
 
-func GoPocWriteResult(result structs.GoPocsResultType) {
-	WriteResultLock.Lock()
-	report.AddResultByGoPocResult(result)
-	WriteResultLock.Unlock()
+func GoPocWriteResult(scan *structs.Scan, result structs.GoPocsResultType) {
+	scan.GoPocsLock.Lock()
+	scan.GoPocsResults = append(scan.GoPocsResults, result)
+	scan.GoPocsLock.Unlock()
+	report.AddRecord(scan, report.RecordGoPoc, result)
+	report.AddResultByGoPocResult(scan, result)
 }
 
 func readDict(name string) string {
-	bt, err := os.ReadFile(name)
+	bt, err := config.ReadFile(name)
 	if err != nil {
 		return ""
 	}
 	return string(bt)
 }
 
-// initDic 初始化用于爆破的字典
-func initDic() {
-	basePath := "config/dict/"
+// InitDic 从配置目录读取用于爆破的字典，配置目录初始化后、扫描开始前调用
+func InitDic() {
+	basePath := "dict/"
 	ftpUserPasswdDict = readDict(basePath + "ftp.txt")
 	mssqlUserPasswdDict = readDict(basePath + "mssql.txt")
 	mysqlUserPasswdDict = readDict(basePath + "mysql.txt")
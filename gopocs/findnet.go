@@ -25,7 +25,7 @@ func Findnet(info *structs.HostInfo) error {
 }
 
 func FindnetScan(info *structs.HostInfo) error {
-	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	realhost := net.JoinHostPort(info.Host, info.Ports)
 	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
 	defer func() {
 		if conn != nil {
@@ -67,7 +67,7 @@ func FindnetScan(info *structs.HostInfo) error {
 	if flag {
 		return err
 	}
-	err = read(text, info.Host)
+	err = read(info.Scan, text, info.Host)
 	return err
 }
 
@@ -96,7 +96,7 @@ func HexUnicodeStringToString(src string) string {
 	return context
 }
 
-func read(text []byte, host string) error {
+func read(scan *structs.Scan, text []byte, host string) error {
 	encodedStr := hex.EncodeToString(text)
 
 	hn := ""
@@ -136,7 +136,7 @@ func read(text []byte, host string) error {
 	}
 	gologger.Silent().Msg("[GoPoc] RPC:" + result)
 
-	GoPocWriteResult(structs.GoPocsResultType{
+	GoPocWriteResult(scan, structs.GoPocsResultType{
 		PocName:     "WMI-Leak",
 		Security:    "INFO",
 		Target:      host,
@@ -1,11 +1,14 @@
 package gopocs
 
 import (
+	"dddd/common"
+	"dddd/common/ratelimit"
 	"dddd/structs"
 	_ "embed"
 	"fmt"
 	"github.com/jlaffaye/ftp"
 	"github.com/projectdiscovery/gologger"
+	"net"
 	"time"
 )
 
@@ -49,7 +52,14 @@ func FtpScan(info *structs.HostInfo) (tmperr error) {
 func FtpConn(info *structs.HostInfo, user string, pass string) (flag bool, err error) {
 	flag = false
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
-	conn, err := ftp.DialTimeout(fmt.Sprintf("%v:%v", Host, Port), time.Duration(6)*time.Second)
+	// 控制连接与数据连接属于同一次登录，只占用一个并发名额
+	release := ratelimit.Acquire(net.JoinHostPort(Host, Port))
+	defer release()
+	timeout := time.Duration(6) * time.Second
+	conn, err := ftp.Dial(net.JoinHostPort(Host, Port), ftp.DialWithTimeout(timeout),
+		ftp.DialWithDialFunc(func(network, address string) (net.Conn, error) {
+			return common.DialWithoutLimit(network, address, timeout)
+		}))
 	if err == nil {
 		err = conn.Login(Username, Password)
 		if err == nil {
@@ -75,10 +85,10 @@ func FtpConn(info *structs.HostInfo, user string, pass string) (flag bool, err e
 
 			gologger.Silent().Msgf("[GoPoc] " + result)
 
-			GoPocWriteResult(structs.GoPocsResultType{
+			GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 				PocName:     "FTP-Login",
 				Security:    "HIGH",
-				Target:      fmt.Sprintf("%v:%v", Host, Port),
+				Target:      net.JoinHostPort(Host, Port),
 				InfoLeft:    result,
 				Description: "FTP未授权访问或弱口令",
 			})
@@ -5,12 +5,13 @@ import (
 	"dddd/structs"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
+	"net"
 	"strings"
 	"time"
 )
 
 func JDWPScan(info *structs.HostInfo) (err error) {
-	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	realhost := net.JoinHostPort(info.Host, info.Ports)
 	client, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
 	defer func() {
 		if client != nil {
@@ -77,7 +78,7 @@ func JDWPScan(info *structs.HostInfo) (err error) {
 	result := fmt.Sprintf("[GoPoc] JDWP://%s Unauthorized", realhost)
 	gologger.Silent().Msg(result)
 
-	GoPocWriteResult(structs.GoPocsResultType{
+	GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 		PocName:     "JDWP-Unauthorized",
 		Security:    "CRITICAL",
 		Target:      realhost,
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	_ "github.com/denisenkom/go-mssqldb"
 	"github.com/projectdiscovery/gologger"
+	"net"
 	"strings"
 	"time"
 )
@@ -41,7 +42,7 @@ func MongodbUnauth(info *structs.HostInfo) (flag bool, err error) {
 		0x21, 0x00, 0x00, 0x00, 0x2, 0x67, 0x65, 0x74, 0x4c, 0x6f, 0x67, 0x00, 0x10, 0x00, 0x00, 0x00, 0x73, 0x74, 0x61, 0x72, 0x74, 0x75, 0x70, 0x57, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73, 0x00, 0x00,
 	}
 
-	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	realhost := net.JoinHostPort(info.Host, info.Ports)
 
 	checkUnAuth := func(address string, packet []byte) (string, error) {
 		conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
@@ -83,7 +84,7 @@ func MongodbUnauth(info *structs.HostInfo) (flag bool, err error) {
 		result := fmt.Sprintf("[GoPoc] Mongodb://%s Unauthorized", realhost)
 		gologger.Silent().Msg(result)
 
-		GoPocWriteResult(structs.GoPocsResultType{
+		GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 			PocName:     "Mongodb-Unauthorized",
 			Security:    "HIGH",
 			Target:      realhost,
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
+	"net"
 	"strings"
 	"time"
 )
@@ -33,7 +34,7 @@ func MS17010(info *structs.HostInfo) error {
 func MS17010Scan(info *structs.HostInfo) error {
 	ip := info.Host
 	// connecting to a host in LAN if reachable should be very quick
-	conn, err := common.WrapperTcpWithTimeout("tcp", ip+":445", time.Duration(7)*time.Second)
+	conn, err := common.WrapperTcpWithTimeout("tcp", net.JoinHostPort(ip, "445"), time.Duration(7)*time.Second)
 	defer func() {
 		if conn != nil {
 			conn.Close()
@@ -129,7 +130,7 @@ func MS17010Scan(info *structs.HostInfo) error {
 		result := fmt.Sprintf("[GoPoc] MS17-010 %s (%s)", ip, os)
 		gologger.Silent().Msg(result)
 
-		GoPocWriteResult(structs.GoPocsResultType{
+		GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 			PocName:     "MS17-010",
 			Security:    "CRITICAL",
 			Target:      ip,
@@ -2,11 +2,13 @@ package gopocs
 
 import (
 	"database/sql"
+	"dddd/common"
 	"dddd/structs"
 	_ "embed"
 	"fmt"
-	_ "github.com/denisenkom/go-mssqldb"
+	mssql "github.com/denisenkom/go-mssqldb"
 	"github.com/projectdiscovery/gologger"
+	"net"
 	"time"
 )
 
@@ -101,7 +103,7 @@ func MssqlConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
 	dataSourceName := fmt.Sprintf("server=%s;user id=%s;password=%s;port=%v;encrypt=disable;timeout=%v",
 		Host, Username, Password, Port, time.Duration(6)*time.Second)
-	db, err := sql.Open("mssql", dataSourceName)
+	db, err := openMssql(dataSourceName)
 	if err == nil {
 		db.SetConnMaxLifetime(time.Duration(6) * time.Second)
 		db.SetConnMaxIdleTime(time.Duration(6) * time.Second)
@@ -114,10 +116,10 @@ func MssqlConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 
 			showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", Host, Port, Username, Password)
 
-			GoPocWriteResult(structs.GoPocsResultType{
+			GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 				PocName:     "Mssql-Login",
 				Security:    "CRITICAL",
-				Target:      Host + ":" + Port,
+				Target:      net.JoinHostPort(Host, Port),
 				InfoLeft:    showData,
 				InfoRight:   verifyMssql(db),
 				Description: "Mssql弱口令",
@@ -128,3 +130,13 @@ func MssqlConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 	}
 	return flag, err
 }
+
+// openMssql 与sql.Open相同，连接经过WrapperTCP
+func openMssql(dataSourceName string) (*sql.DB, error) {
+	connector, err := mssql.NewConnector(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	connector.Dialer = common.Dialer{Timeout: time.Duration(6) * time.Second}
+	return sql.OpenDB(connector), nil
+}
@@ -1,15 +1,25 @@
 package gopocs
 
 import (
+	"context"
 	"database/sql"
+	"dddd/common"
 	"dddd/structs"
 	_ "embed"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/projectdiscovery/gologger"
+	"net"
 	"time"
 )
 
+func init() {
+	// mysql驱动的连接经过WrapperTCP
+	mysql.RegisterDialContext("tcp", func(ctx context.Context, addr string) (net.Conn, error) {
+		return common.Dialer{Timeout: time.Duration(6) * time.Second}.DialContext(ctx, "tcp", addr)
+	})
+}
+
 var mysqlUserPasswdDict string
 
 func MysqlScan(info *structs.HostInfo) (tmperr error) {
@@ -38,7 +48,7 @@ func MysqlScan(info *structs.HostInfo) (tmperr error) {
 func MysqlConn(info *structs.HostInfo, user string, pass string) (flag bool, err error) {
 	flag = false
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
-	dataSourceName := fmt.Sprintf("%v:%v@tcp(%v:%v)/mysql?charset=utf8&timeout=%v", Username, Password, Host, Port, time.Duration(6)*time.Second)
+	dataSourceName := fmt.Sprintf("%v:%v@tcp(%v)/mysql?charset=utf8&timeout=%v", Username, Password, net.JoinHostPort(Host, Port), time.Duration(6)*time.Second)
 	db, err := sql.Open("mysql", dataSourceName)
 	if err == nil {
 		db.SetConnMaxLifetime(time.Duration(6) * time.Second)
@@ -88,10 +98,10 @@ func MysqlConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 				}
 			}
 
-			GoPocWriteResult(structs.GoPocsResultType{
+			GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 				PocName:     "Mysql-Login",
 				Security:    "High",
-				Target:      Host + ":" + Port,
+				Target:      net.JoinHostPort(Host, Port),
 				InfoLeft:    showData,
 				InfoRight:   msg,
 				Description: "Mysql弱口令",
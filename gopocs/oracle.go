@@ -2,11 +2,13 @@ package gopocs
 
 import (
 	"database/sql"
+	"dddd/common"
 	"dddd/structs"
 	_ "embed"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
-	_ "github.com/sijms/go-ora/v2"
+	go_ora "github.com/sijms/go-ora/v2"
+	"net"
 	"time"
 )
 
@@ -38,8 +40,8 @@ func OracleScan(info *structs.HostInfo) (tmperr error) {
 func OracleConn(info *structs.HostInfo, user string, pass string) (flag bool, err error) {
 	flag = false
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
-	dataSourceName := fmt.Sprintf("oracle://%s:%s@%s:%s/orcl", Username, Password, Host, Port)
-	db, err := sql.Open("oracle", dataSourceName)
+	dataSourceName := fmt.Sprintf("oracle://%s:%s@%s/orcl", Username, Password, net.JoinHostPort(Host, Port))
+	db, err := openOracle(dataSourceName)
 	if err == nil {
 		db.SetConnMaxLifetime(time.Duration(6) * time.Second)
 		db.SetConnMaxIdleTime(time.Duration(6) * time.Second)
@@ -52,10 +54,10 @@ func OracleConn(info *structs.HostInfo, user string, pass string) (flag bool, er
 
 			showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", Host, Port, Username, Password)
 
-			GoPocWriteResult(structs.GoPocsResultType{
+			GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 				PocName:     "Oracle-Login",
 				Security:    "High",
-				Target:      Host + ":" + Port,
+				Target:      net.JoinHostPort(Host, Port),
 				InfoLeft:    showData,
 				Description: "Oracle弱口令",
 			})
@@ -65,3 +67,13 @@ func OracleConn(info *structs.HostInfo, user string, pass string) (flag bool, er
 	}
 	return flag, err
 }
+
+// openOracle 与sql.Open相同，连接经过WrapperTCP
+func openOracle(dataSourceName string) (*sql.DB, error) {
+	connector, err := (&go_ora.OracleDriver{}).OpenConnector(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	connector.(*go_ora.OracleConnector).Dialer(common.Dialer{Timeout: time.Duration(6) * time.Second})
+	return sql.OpenDB(connector), nil
+}
@@ -2,11 +2,13 @@ package gopocs
 
 import (
 	"database/sql"
+	"dddd/common"
 	"dddd/structs"
 	_ "embed"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/projectdiscovery/gologger"
+	"net"
 	"strings"
 	"time"
 )
@@ -45,8 +47,8 @@ func PostgresScan(info *structs.HostInfo) (tmperr error) {
 func PostgresConn(info *structs.HostInfo, user string, pass string) (flag bool, err error) {
 	flag = false
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
-	dataSourceName := fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=%v", Username, Password, Host, Port, "postgres", "disable")
-	db, err := sql.Open("postgres", dataSourceName)
+	dataSourceName := fmt.Sprintf("postgres://%v:%v@%v/%v?sslmode=%v", Username, Password, net.JoinHostPort(Host, Port), "postgres", "disable")
+	db, err := openPostgres(dataSourceName)
 	if err == nil {
 		db.SetConnMaxLifetime(time.Duration(5) * time.Second)
 		defer db.Close()
@@ -57,10 +59,10 @@ func PostgresConn(info *structs.HostInfo, user string, pass string) (flag bool,
 
 			showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", Host, Port, Username, Password)
 
-			GoPocWriteResult(structs.GoPocsResultType{
+			GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 				PocName:     "PostgreSQL-Login",
 				Security:    "CRITICAL",
-				Target:      Host + ":" + Port,
+				Target:      net.JoinHostPort(Host, Port),
 				InfoLeft:    showData,
 				Description: "PostgreSQL弱口令",
 			})
@@ -69,3 +71,13 @@ func PostgresConn(info *structs.HostInfo, user string, pass string) (flag bool,
 	}
 	return flag, err
 }
+
+// openPostgres 与sql.Open相同，连接经过WrapperTCP
+func openPostgres(dataSourceName string) (*sql.DB, error) {
+	connector, err := pq.NewConnector(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	connector.Dialer(common.Dialer{Timeout: time.Duration(6) * time.Second})
+	return sql.OpenDB(connector), nil
+}
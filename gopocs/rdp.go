@@ -17,6 +17,7 @@ import (
 	"github.com/tomatome/grdp/protocol/tpkt"
 	"github.com/tomatome/grdp/protocol/x224"
 	"log"
+	"net"
 	"os"
 	"strconv"
 	"sync"
@@ -47,7 +48,7 @@ func RdpScan(info *structs.HostInfo) (tmperr error) {
 
 	for i := 0; i < 1; i++ {
 		wg.Add(1)
-		go worker(info.Host, "", port, &wg, brlist, &signal, &num, all, &mutex, 6)
+		go worker(info.Scan, info.Host, "", port, &wg, brlist, &signal, &num, all, &mutex, 6)
 	}
 
 	close(brlist)
@@ -61,7 +62,7 @@ func RdpScan(info *structs.HostInfo) (tmperr error) {
 	return tmperr
 }
 
-func worker(host, domain string, port int, wg *sync.WaitGroup, brlist chan Brutelist, signal *bool, num *int, all int, mutex *sync.Mutex, timeout int64) {
+func worker(scan *structs.Scan, host, domain string, port int, wg *sync.WaitGroup, brlist chan Brutelist, signal *bool, num *int, all int, mutex *sync.Mutex, timeout int64) {
 	defer wg.Done()
 	for one := range brlist {
 		if *signal == true {
@@ -81,10 +82,10 @@ func worker(host, domain string, port int, wg *sync.WaitGroup, brlist chan Brute
 			gologger.Silent().Msg("[GoPoc] " + result)
 			showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", host, port, user, pass)
 
-			GoPocWriteResult(structs.GoPocsResultType{
+			GoPocWriteResult(scan, structs.GoPocsResultType{
 				PocName:     "RDP-Login",
 				Security:    "CRITICAL",
-				Target:      fmt.Sprintf("%v:%v", host, port),
+				Target:      net.JoinHostPort(host, strconv.Itoa(port)),
 				InfoLeft:    showData,
 				Description: "RDP弱口令",
 			})
@@ -102,7 +103,7 @@ func incrNum(num *int, mutex *sync.Mutex) {
 }
 
 func RdpConn(ip, domain, user, password string, port int, timeout int64) (bool, error) {
-	target := fmt.Sprintf("%s:%d", ip, port)
+	target := net.JoinHostPort(ip, strconv.Itoa(port))
 	g := NewClient(target, glog.NONE)
 	err := g.Login(domain, user, password, timeout)
 
@@ -80,7 +80,7 @@ func RedisScan(info *structs.HostInfo) (tmperr error) {
 
 func RedisConn(info *structs.HostInfo, pass string) (flag bool, err error) {
 	flag = false
-	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	realhost := net.JoinHostPort(info.Host, info.Ports)
 	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
 	defer func() {
 		if conn != nil {
@@ -110,7 +110,7 @@ func RedisConn(info *structs.HostInfo, pass string) (flag bool, err error) {
 
 		showData := fmt.Sprintf("Host: %v\nPassword: %v\n", realhost, pass)
 
-		GoPocWriteResult(structs.GoPocsResultType{
+		GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 			PocName:     "Redis-Login",
 			Security:    "HIGH",
 			Target:      realhost,
@@ -125,7 +125,7 @@ func RedisConn(info *structs.HostInfo, pass string) (flag bool, err error) {
 
 func RedisUnauth(info *structs.HostInfo) (flag bool, err error) {
 	flag = false
-	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	realhost := net.JoinHostPort(info.Host, info.Ports)
 	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
 	defer func() {
 		if conn != nil {
@@ -155,7 +155,7 @@ func RedisUnauth(info *structs.HostInfo) (flag bool, err error) {
 
 		showData := fmt.Sprintf("Host: %v\nUnauthorized\n", realhost)
 
-		GoPocWriteResult(structs.GoPocsResultType{
+		GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 			PocName:     "Redis-Login",
 			Security:    "HIGH",
 			Target:      realhost,
@@ -5,6 +5,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"dddd/common/ratelimit"
 	"dddd/structs"
 	"encoding/base64"
 	"fmt"
@@ -42,6 +43,8 @@ func sendShiroRequest(url string, data string) bool {
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/71.0.3578.98 Safari/537.36")
 	req.Header.Set("Cookie", "JSESSIONID="+Randcase(8)+";rememberMe="+data)
 
+	release := ratelimit.Acquire(url)
+	defer release()
 	resp, err := client.Do(req)
 	if err != nil {
 		return false
@@ -140,7 +143,7 @@ func ShiroKeyCheck(info *structs.HostInfo) {
 
 			showData := fmt.Sprintf("Host: %v\nkey: %v\nmode: %v\n", url, key, tp)
 
-			GoPocWriteResult(structs.GoPocsResultType{
+			GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 				PocName:     "Shiro Weak Key",
 				Security:    "CRITICAL",
 				Target:      url,
@@ -2,6 +2,7 @@ package gopocs
 
 import (
 	"context"
+	"dddd/common"
 	"dddd/structs"
 	_ "embed"
 	"fmt"
@@ -39,7 +40,7 @@ func SmbScan(info *structs.HostInfo) (tmperr error) {
 func SmblConn(info *structs.HostInfo, user string, pass string) (flag bool, err error) {
 	flag = false
 
-	conn, err := net.Dial("tcp", info.Host+":"+info.Ports)
+	conn, err := common.WrapperTcpWithTimeout("tcp", net.JoinHostPort(info.Host, info.Ports), time.Duration(6)*time.Second)
 	if err != nil {
 		return false, err
 	}
@@ -72,10 +73,10 @@ func SmblConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 	gologger.Silent().Msg(result)
 	showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", info.Host, info.Ports, user, pass)
 
-	GoPocWriteResult(structs.GoPocsResultType{
+	GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 		PocName:     "SMB-Login",
 		Security:    "CRITICAL",
-		Target:      info.Host + ":" + info.Ports,
+		Target:      net.JoinHostPort(info.Host, info.Ports),
 		InfoLeft:    showData,
 		InfoRight:   showShare,
 		Description: "SMB弱口令",
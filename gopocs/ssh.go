@@ -1,6 +1,7 @@
 package gopocs
 
 import (
+	"dddd/common"
 	"dddd/structs"
 	_ "embed"
 	"fmt"
@@ -54,7 +55,7 @@ func SshConn(info *structs.HostInfo, user string, pass string) (flag bool, err e
 		},
 	}
 
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%v:%v", Host, Port), config)
+	client, err := sshDial(net.JoinHostPort(Host, Port), config)
 	if err == nil {
 		defer client.Close()
 		session, err := client.NewSession()
@@ -73,10 +74,10 @@ func SshConn(info *structs.HostInfo, user string, pass string) (flag bool, err e
 
 			showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", Host, Port, Username, Password)
 
-			GoPocWriteResult(structs.GoPocsResultType{
+			GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 				PocName:     "SSH-Login",
 				Security:    "CRITICAL",
-				Target:      Host + ":" + Port,
+				Target:      net.JoinHostPort(Host, Port),
 				InfoLeft:    showData,
 				InfoRight:   shellInfo,
 				Description: "SSH弱口令",
@@ -87,3 +88,17 @@ func SshConn(info *structs.HostInfo, user string, pass string) (flag bool, err e
 	return flag, err
 
 }
+
+// sshDial 与ssh.Dial相同，连接经过WrapperTCP
+func sshDial(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", addr, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
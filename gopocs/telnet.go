@@ -7,6 +7,7 @@ import (
 	_ "embed"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -38,10 +39,10 @@ func TelnetScan(info *structs.HostInfo) (tmperr error) {
 
 		showData := fmt.Sprintf("Host: %v:%v\nUnauthorized\n", info.Host, info.Ports)
 
-		GoPocWriteResult(structs.GoPocsResultType{
+		GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 			PocName:     "Telnet-Login",
 			Security:    "CRITICAL",
-			Target:      info.Host + ":" + info.Ports,
+			Target:      net.JoinHostPort(info.Host, info.Ports),
 			InfoLeft:    showData,
 			Description: "Telnet未授权/弱口令",
 		})
@@ -78,10 +79,10 @@ func TelnetScan(info *structs.HostInfo) (tmperr error) {
 
 					showData := fmt.Sprintf("Host: %v:%v\nPass: %v\n", info.Host, info.Ports, pass)
 
-					GoPocWriteResult(structs.GoPocsResultType{
+					GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 						PocName:     "Telnet-Login",
 						Security:    "CRITICAL",
-						Target:      info.Host + ":" + info.Ports,
+						Target:      net.JoinHostPort(info.Host, info.Ports),
 						InfoLeft:    showData,
 						Description: "Telnet未授权/弱口令",
 					})
@@ -93,10 +94,10 @@ func TelnetScan(info *structs.HostInfo) (tmperr error) {
 
 					showData := fmt.Sprintf("Host: %v:%v\nUser: %v\nPass: %v\n", info.Host, info.Ports, user, pass)
 
-					GoPocWriteResult(structs.GoPocsResultType{
+					GoPocWriteResult(info.Scan, structs.GoPocsResultType{
 						PocName:     "Telnet-Login",
 						Security:    "CRITICAL",
-						Target:      info.Host + ":" + info.Ports,
+						Target:      net.JoinHostPort(info.Host, info.Ports),
 						InfoLeft:    showData,
 						Description: "Telnet未授权/弱口令",
 					})
@@ -2,10 +2,11 @@ package telnetlib
 
 import (
 	"bytes"
+	"dddd/common"
 	"errors"
-	"fmt"
 	"net"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -130,7 +131,7 @@ func New(addr string, port int) *Client {
 }
 
 func (c *Client) Connect() error {
-	conn, err := net.DialTimeout("tcp", c.Netloc(), 5*time.Second)
+	conn, err := common.WrapperTcpWithTimeout("tcp", c.Netloc(), 5*time.Second)
 	if err != nil {
 		return err
 	}
@@ -180,7 +181,7 @@ func (c *Client) ReadContext() string {
 }
 
 func (c *Client) Netloc() string {
-	return fmt.Sprintf("%s:%d", c.IPAddr, c.Port)
+	return net.JoinHostPort(c.IPAddr, strconv.Itoa(c.Port))
 }
 
 func (c *Client) Close() {
@@ -3,6 +3,8 @@ package ddfinger
 import (
 	"bytes"
 	"container/list"
+	"dddd/common/report"
+	"dddd/config"
 	"dddd/structs"
 	"dddd/utils"
 	"encoding/base64"
@@ -10,8 +12,8 @@ import (
 	"github.com/logrusorgru/aurora"
 	"github.com/projectdiscovery/gologger"
 	"gopkg.in/yaml.v3"
+	"net"
 	"net/url"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -48,6 +50,17 @@ import (
 // content_type!="text/html" content_type不包含text/html
 // banner="123"
 // banner!="123"
+// product="OpenSSH" 服务产品名包含OpenSSH
+// product!="OpenSSH" 服务产品名不包含OpenSSH
+// version="8" 服务版本为8或8.x
+// version>="7.4" 服务版本大于等于7.4，8.2p1按8、2、p、1逐段比较
+// version<="7.4" 服务版本小于等于7.4
+// version<"8" 服务版本小于8
+// os="Linux" 服务所在操作系统包含Linux
+// info="protocol 2.0" 服务附加信息包含protocol 2.0
+// hostname="db01" 服务返回的主机名包含db01
+// device="router" 设备类型包含router
+// cpe="cpe:/a:openbsd:openssh" 服务CPE包含cpe:/a:openbsd:openssh
 // 永真
 // type="service"
 
@@ -86,7 +99,8 @@ func advance(ch int) int {
 func boolEval(expression string) bool {
 	// 左右括号相等
 	if strings.Count(expression, "(") != strings.Count(expression, ")") {
-		gologger.Fatal().Msg(fmt.Sprintf("[-] 纯布尔表达式 [%s] 左右括号不匹配", expression))
+		gologger.Error().Msgf("[-] 纯布尔表达式 [%s] 左右括号不匹配", expression)
+		return false
 	}
 	// 去除空格
 	for strings.Contains(expression, " ") {
@@ -200,23 +214,35 @@ func boolEval(expression string) bool {
 
 }
 
-func readFingerYaml() map[string]interface{} {
-	data, err := os.ReadFile("config/finger.yaml")
-	fps := make(map[string]interface{})
-	err = yaml.Unmarshal(data, &fps)
+func readFingerYaml() (map[string]interface{}, error) {
+	data, err := config.ReadFile("finger.yaml")
 	if err != nil {
-		gologger.Fatal().Msg(err.Error())
+		return nil, err
+	}
+	fps := make(map[string]interface{})
+	if err = yaml.Unmarshal(data, &fps); err != nil {
+		return nil, fmt.Errorf("解析finger.yaml失败: %v", err)
 	}
-	return fps
+	return fps, nil
 }
 
 func getRuleData(rule string) structs.RuleData {
-	if !strings.Contains(rule, "=\"") {
+	// 运算符后紧跟引号，严格大于、小于为 >" <"
+	pos := -1
+	for _, sep := range []string{"=\"", ">\"", "<\""} {
+		if i := strings.Index(rule, sep); i > 0 && (pos == -1 || i < pos) {
+			pos = i
+		}
+	}
+	if pos == -1 {
 		return structs.RuleData{}
 	}
-	pos := strings.Index(rule, "=\"")
 	op := 0
-	if rule[pos-1] == 33 {
+	if rule[pos] == 62 {
+		op = 6
+	} else if rule[pos] == 60 {
+		op = 7
+	} else if rule[pos-1] == 33 {
 		op = 1
 	} else if rule[pos-1] == 61 {
 		op = 2
@@ -230,7 +256,7 @@ func getRuleData(rule string) structs.RuleData {
 
 	start := 0
 	ti := 0
-	if op > 0 {
+	if op > 0 && op < 6 {
 		ti = 1
 	}
 	for i := pos - 1 - ti; i >= 0; i-- {
@@ -270,16 +296,19 @@ func parseRule(rule string) []structs.RuleData {
 	return result
 }
 
-func ParseFingerYaml() []structs.FingerPEntity {
+func ParseFingerYaml() ([]structs.FingerPEntity, error) {
 	var result []structs.FingerPEntity
-	fingerprintYaml := readFingerYaml()
+	fingerprintYaml, err := readFingerYaml()
+	if err != nil {
+		return nil, err
+	}
 	for productName, rulesInterface := range fingerprintYaml {
 		for _, ruleInterface := range rulesInterface.([]interface{}) {
 			ruleL := ruleInterface.(string)
 			result = append(result, structs.FingerPEntity{ProductName: productName, Rule: parseRule(ruleL), AllString: ruleL})
 		}
 	}
-	return result
+	return result, nil
 }
 
 func regexMatch(pattern string, s string) (bool, error) {
@@ -334,16 +363,148 @@ func dataCheckInt(op int16, dataSource int, dataRule int) bool {
 		if dataSource <= dataRule {
 			return true
 		}
+	} else if op == 6 {
+		if dataSource > dataRule {
+			return true
+		}
+	} else if op == 7 {
+		if dataSource < dataRule {
+			return true
+		}
 	}
 	return false
 }
 
-func checkPath(Path string,
+// dataCheckVersion 比较版本号，version="8"匹配8与8.x，没有版本时只有!=成立
+func dataCheckVersion(op int16, dataSource string, dataRule string) bool {
+	if dataSource == "" {
+		return op == 1
+	}
+	switch op {
+	case 0:
+		return versionHasPrefix(dataSource, dataRule)
+	case 1:
+		return !versionHasPrefix(dataSource, dataRule)
+	case 2:
+		return strings.EqualFold(dataSource, dataRule)
+	case 5:
+		return dataCheckString(op, dataSource, dataRule)
+	}
+	return dataCheckInt(op, compareVersion(dataSource, dataRule), 0)
+}
+
+// splitVersion 按数字与字母分段，如 8.2p1 为 8 2 p 1
+func splitVersion(version string) []string {
+	var parts []string
+	current := ""
+	digit := false
+	for _, ch := range strings.ToLower(version) {
+		isDigit := ch >= '0' && ch <= '9'
+		isLetter := ch >= 'a' && ch <= 'z'
+		if !isDigit && !isLetter {
+			if current != "" {
+				parts = append(parts, current)
+				current = ""
+			}
+			continue
+		}
+		if current != "" && isDigit != digit {
+			parts = append(parts, current)
+			current = ""
+		}
+		current += string(ch)
+		digit = isDigit
+	}
+	if current != "" {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// compareVersion 逐段比较版本号，数字段按数值比较，数字段大于字母段，前缀相同时段数多的版本更大
+func compareVersion(a string, b string) int {
+	partsA, partsB := splitVersion(a), splitVersion(b)
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		numA, errA := strconv.Atoi(partsA[i])
+		numB, errB := strconv.Atoi(partsB[i])
+		switch {
+		case errA == nil && errB == nil:
+			if numA != numB {
+				if numA < numB {
+					return -1
+				}
+				return 1
+			}
+		case errA == nil:
+			return 1
+		case errB == nil:
+			return -1
+		default:
+			if c := strings.Compare(partsA[i], partsB[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	if len(partsA) < len(partsB) {
+		return -1
+	} else if len(partsA) > len(partsB) {
+		return 1
+	}
+	return 0
+}
+
+// versionHasPrefix 按段判断前缀，8.2p1以8与8.2开头，不以8.20开头
+func versionHasPrefix(version string, prefix string) bool {
+	parts, prefixParts := splitVersion(version), splitVersion(prefix)
+	if len(prefixParts) == 0 || len(prefixParts) > len(parts) {
+		return false
+	}
+	for i := range prefixParts {
+		if compareVersion(parts[i], prefixParts[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// serviceCheckString 匹配服务详情中的文本字段，与版本号相同，没有识别出该字段时只有!=成立
+func serviceCheckString(op int16, dataSource string, dataRule string) bool {
+	if dataSource == "" {
+		return op == 1
+	}
+	return dataCheckString(op, dataSource, dataRule)
+}
+
+// checkServiceRule 匹配协议识别得到的服务详情，key不属于服务详情时第二个返回值为false。
+// 字段为空视为与任何值都不相等，product!="OpenSSH" 对没有识别出产品名的端口成立
+func checkServiceRule(rule structs.RuleData, detail structs.ServiceFingerprint) (bool, bool) {
+	switch rule.Key {
+	case "product":
+		return serviceCheckString(rule.Op, detail.ProductName, rule.Value), true
+	case "version":
+		return dataCheckVersion(rule.Op, detail.Version, rule.Value), true
+	case "os":
+		return serviceCheckString(rule.Op, detail.OperatingSystem, rule.Value), true
+	case "info":
+		return serviceCheckString(rule.Op, detail.Info, rule.Value), true
+	case "hostname":
+		return serviceCheckString(rule.Op, detail.Hostname, rule.Value), true
+	case "device":
+		return serviceCheckString(rule.Op, detail.DeviceType, rule.Value), true
+	case "cpe":
+		return serviceCheckString(rule.Op, strings.Join(detail.CPE, "\n"), rule.Value), true
+	}
+	return false, false
+}
+
+func checkPath(scan *structs.Scan,
+	Path string,
 	webPath structs.UrlPathEntity,
 	Port int, // 所开放的端口
 	Protocol string, // 协议
 	Banner string, // 响应
 	Cert string, // TLS证书
+	Service structs.ServiceFingerprint, // 协议识别得到的服务详情
 ) []string {
 	var fingerPrintResults []string
 
@@ -351,7 +512,7 @@ func checkPath(Path string,
 
 	hashString := webPath.Hash
 	body := ""
-	bodyBytes, ok := structs.GlobalHttpBodyHMap.Get(hashString)
+	bodyBytes, ok := scan.HttpBodyHMap.Get(hashString)
 	if !ok {
 		body = ""
 	} else {
@@ -359,7 +520,7 @@ func checkPath(Path string,
 	}
 
 	headerString := ""
-	headerBytes, ok := structs.GlobalHttpHeaderHMap.Get(webPath.HeaderHashString)
+	headerBytes, ok := scan.HttpHeaderHMap.Get(webPath.HeaderHashString)
 	if !ok {
 		headerString = ""
 	} else {
@@ -394,7 +555,9 @@ func checkPath(Path string,
 
 				for _, singleRule := range rules {
 					singleRuleResult := false
-					if singleRule.Key == "header" {
+					if result, ok := checkServiceRule(singleRule, Service); ok {
+						singleRuleResult = result
+					} else if singleRule.Key == "header" {
 						if isWeb && dataCheckString(singleRule.Op, headerString, singleRule.Value) {
 							singleRuleResult = true
 						}
@@ -491,34 +654,40 @@ func checkPath(Path string,
 	return utils.RemoveDuplicateElement(fingerPrintResults)
 }
 
-func FingerprintIdentification() {
+func FingerprintIdentification(scan *structs.Scan) {
 	gologger.Info().Msg("指纹识别中")
 
 	// 先识别非Web
-	for hostPort, protocol := range structs.GlobalIPPortMap {
+	for key, protocol := range scan.IPPortMap {
 		if protocol == "http" || protocol == "https" || protocol == "" {
 			continue
 		}
-		t := strings.Split(hostPort, ":")
-		if len(t) != 2 {
+		network, hostPort := structs.SplitServiceKey(key)
+		_, p, err := net.SplitHostPort(hostPort)
+		if err != nil {
 			continue
 		}
-		// host := t[0]
-		port, err := strconv.Atoi(t[1])
+		port, err := strconv.Atoi(p)
 		if err != nil {
 			continue
 		}
 		banner := ""
-		bodyBytes, ok := structs.GlobalBannerHMap.Get(hostPort)
+		bodyBytes, ok := scan.BannerHMap.Get(key)
 		if !ok {
 			banner = ""
 		} else {
 			banner = string(bodyBytes)
 		}
-		results := checkPath("no#web", structs.UrlPathEntity{}, port, protocol, banner, "")
+		results := checkPath(scan, "no#web", structs.UrlPathEntity{}, port, protocol, banner, scan.GetCertificate(key).String(),
+			scan.GetServiceFingerprint(key))
 		if len(results) > 0 {
 			Url := fmt.Sprintf("%s://%s", protocol, hostPort)
-			structs.GlobalResultMap[Url] = results
+			if network == "udp" {
+				Url = structs.UDPServicePrefix + Url
+			}
+			scan.ResultMapLock.Lock()
+			scan.ResultMap[Url] = results
+			scan.ResultMapLock.Unlock()
 
 			msg := "[Finger] " + Url + " ["
 			for _, r := range results {
@@ -526,16 +695,18 @@ func FingerprintIdentification() {
 			}
 			msg = msg[:len(msg)-1] + "]"
 			gologger.Silent().Msg(msg)
-
+			report.AddRecord(scan, report.RecordFinger, structs.FingerRecord{Target: Url, Products: results})
 		}
 
 	}
-	for rootURL, urlEntity := range structs.GlobalURLMap {
+	for rootURL, urlEntity := range scan.URLMap {
 		banner := ""
+		var service structs.ServiceFingerprint
 		if urlEntity.IP != "" {
-			hostPort := fmt.Sprintf("%s:%d", urlEntity.IP, urlEntity.Port)
+			hostPort := net.JoinHostPort(urlEntity.IP, strconv.Itoa(urlEntity.Port))
+			service = scan.GetServiceFingerprint(hostPort)
 
-			bodyBytes, ok := structs.GlobalBannerHMap.Get(hostPort)
+			bodyBytes, ok := scan.BannerHMap.Get(hostPort)
 			if !ok {
 				banner = ""
 			} else {
@@ -546,11 +717,18 @@ func FingerprintIdentification() {
 		URL, _ := url.Parse(rootURL)
 
 		for path, pathEntity := range urlEntity.WebPaths {
-			results := checkPath(path, pathEntity, urlEntity.Port, URL.Scheme, banner, urlEntity.Cert)
+			results := checkPath(scan, path, pathEntity, urlEntity.Port, URL.Scheme, banner, urlEntity.Cert, service)
 			fullURL := rootURL + path
 
+			scan.ResultMapLock.Lock()
+			if len(results) > 0 {
+				scan.ResultMap[fullURL] = results
+			} else {
+				scan.ResultMap[fullURL] = []string{}
+			}
+			scan.ResultMapLock.Unlock()
+
 			if len(results) > 0 {
-				structs.GlobalResultMap[fullURL] = results
 				msg := "[Finger] " + fullURL + " "
 				msg += fmt.Sprintf("[%d] [", pathEntity.StatusCode)
 				for _, r := range results {
@@ -561,8 +739,12 @@ func FingerprintIdentification() {
 					msg += fmt.Sprintf(" [%s]", pathEntity.Title)
 				}
 				gologger.Silent().Msg(msg)
-			} else {
-				structs.GlobalResultMap[fullURL] = []string{}
+				report.AddRecord(scan, report.RecordFinger, structs.FingerRecord{
+					Target:     fullURL,
+					Products:   results,
+					StatusCode: pathEntity.StatusCode,
+					Title:      pathEntity.Title,
+				})
 			}
 		}
 	}
@@ -570,13 +752,15 @@ func FingerprintIdentification() {
 
 func SingleCheck(finger structs.FingerPEntity, Protocol string, headerString string, body string,
 	Server string, Title string, Cert string, Port int, Path string, Hash string, IconHash string, StatusCode int,
-	ContentType string, Banner string) bool {
+	ContentType string, Banner string, Service structs.ServiceFingerprint) bool {
 	rules := finger.Rule
 	expr := finger.AllString
 
 	for _, singleRule := range rules {
 		singleRuleResult := false
-		if singleRule.Key == "header" {
+		if result, ok := checkServiceRule(singleRule, Service); ok {
+			singleRuleResult = result
+		} else if singleRule.Key == "header" {
 			if dataCheckString(singleRule.Op, headerString, singleRule.Value) {
 				singleRuleResult = true
 			}
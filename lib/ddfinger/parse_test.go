@@ -0,0 +1,140 @@
+package ddfinger
+
+import (
+	"dddd/structs"
+	"testing"
+)
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.10", "1.9", 1},
+		{"1.9", "1.10", -1},
+		{"7.4", "7.4", 0},
+		{"8.2p1", "8.2", 1},
+		{"8.2p1", "8.2p2", -1},
+		{"2.4.49-r1", "2.4.49", 1},
+		{"2.4.49-r1", "2.4.50", -1},
+		{"2.4.49-r1", "2.4.49-R1", 0},
+		{"1.0.1", "1.0.beta", 1}, // 数字段大于字母段
+		{"1.0.0", "1.0.0a", -1},
+		{"1.0", "1.0.0", -1},
+		{"", "1", -1},
+		{"1", "", 1},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersion(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVersionHasPrefix(t *testing.T) {
+	tests := []struct {
+		version, prefix string
+		want            bool
+	}{
+		{"8.2p1", "8", true},
+		{"8.2p1", "8.2", true},
+		{"8.2p1", "8.20", false},
+		{"8.20", "8.2", false},
+		{"1.10", "1.1", false},
+		{"2.4.49-r1", "2.4.49", true},
+		{"2.4.49-r1", "2.4.4", false},
+		{"8", "8.2", false},
+		{"8", "", false},
+		{"", "8", false},
+	}
+	for _, tt := range tests {
+		if got := versionHasPrefix(tt.version, tt.prefix); got != tt.want {
+			t.Errorf("versionHasPrefix(%q, %q) = %v, want %v", tt.version, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestGetRuleData(t *testing.T) {
+	tests := []struct {
+		rule string
+		want structs.RuleData
+	}{
+		{`version>"1.9"`, structs.RuleData{Start: 0, End: 13, Op: 6, Key: "version", Value: "1.9", All: `version>"1.9"`}},
+		{`version<"8"`, structs.RuleData{Start: 0, End: 11, Op: 7, Key: "version", Value: "8", All: `version<"8"`}},
+		{`version>="7.4"`, structs.RuleData{Start: 0, End: 14, Op: 3, Key: "version", Value: "7.4", All: `version>="7.4"`}},
+		{`version<="2.4.49-r1"`, structs.RuleData{Start: 0, End: 20, Op: 4, Key: "version", Value: "2.4.49-r1", All: `version<="2.4.49-r1"`}},
+		{`(T && version<"8")`, structs.RuleData{Start: 6, End: 17, Op: 7, Key: "version", Value: "8", All: `version<"8"`}},
+		// 取最先出现的规则
+		{`product="OpenSSH" && version>"7"`, structs.RuleData{Start: 0, End: 17, Op: 0, Key: "product", Value: "OpenSSH", All: `product="OpenSSH"`}},
+		{`version 8`, structs.RuleData{}},
+	}
+	for _, tt := range tests {
+		if got := getRuleData(tt.rule); got != tt.want {
+			t.Errorf("getRuleData(%q) = %+v, want %+v", tt.rule, got, tt.want)
+		}
+	}
+
+	rules := parseRule(`product="OpenSSH" && version>"7" && version<"8.2p1"`)
+	if len(rules) != 3 || rules[1].Op != 6 || rules[1].Value != "7" || rules[2].Op != 7 || rules[2].Value != "8.2p1" {
+		t.Errorf("parseRule = %+v", rules)
+	}
+}
+
+func TestDataCheckVersion(t *testing.T) {
+	tests := []struct {
+		op           int16
+		source, rule string
+		want         bool
+	}{
+		{0, "8.2p1", "8", true},
+		{1, "8.2p1", "8", false},
+		{2, "8.2P1", "8.2p1", true},
+		{3, "1.10", "1.9", true},
+		{4, "1.10", "1.9", false},
+		{6, "2.4.49-r1", "2.4.49", true},
+		{6, "2.4.49", "2.4.49", false},
+		{7, "2.4.49-r1", "2.4.50", true},
+		{7, "2.4.49", "2.4.49", false},
+		{5, "8.2p1", `^8\.\d`, true},
+	}
+	for _, tt := range tests {
+		if got := dataCheckVersion(tt.op, tt.source, tt.rule); got != tt.want {
+			t.Errorf("dataCheckVersion(%d, %q, %q) = %v, want %v", tt.op, tt.source, tt.rule, got, tt.want)
+		}
+	}
+}
+
+// 没有识别出的字段只有!=成立，各字段与版本号一致
+func TestCheckServiceRule(t *testing.T) {
+	for _, key := range []string{"product", "version", "os", "info", "hostname", "device", "cpe"} {
+		for op := int16(0); op <= 7; op++ {
+			got, ok := checkServiceRule(structs.RuleData{Op: op, Key: key, Value: "8"}, structs.ServiceFingerprint{})
+			if !ok {
+				t.Fatalf("%s 不是服务详情字段", key)
+			}
+			if want := op == 1; got != want {
+				t.Errorf("%s 为空时 op %d = %v, want %v", key, op, got, want)
+			}
+		}
+	}
+
+	detail := structs.ServiceFingerprint{ProductName: "OpenSSH", Version: "7.4", CPE: []string{"cpe:/a:openbsd:openssh:7.4"}}
+	tests := []struct {
+		rule structs.RuleData
+		want bool
+	}{
+		{structs.RuleData{Op: 0, Key: "product", Value: "openssh"}, true},
+		{structs.RuleData{Op: 1, Key: "product", Value: "OpenSSH"}, false},
+		{structs.RuleData{Op: 7, Key: "version", Value: "8"}, true},
+		{structs.RuleData{Op: 0, Key: "cpe", Value: "cpe:/a:openbsd:openssh"}, true},
+	}
+	for _, tt := range tests {
+		if got, _ := checkServiceRule(tt.rule, detail); got != tt.want {
+			t.Errorf("checkServiceRule(%+v) = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+	if _, ok := checkServiceRule(structs.RuleData{Key: "body"}, detail); ok {
+		t.Errorf("body 不是服务详情字段")
+	}
+}
@@ -5,9 +5,10 @@ import (
 	"github.com/projectdiscovery/gologger"
 )
 
-func CallDNSx(domain string, threads int) []string {
+// CallDNSx 使用字典wordlist爆破domain的子域名
+func CallDNSx(domain string, wordlist string, threads int) []string {
 	// Parse the command line flags and read config files
-	options := runner.ParseOptions(domain, "config/subdomains.txt", threads)
+	options := runner.ParseOptions(domain, wordlist, threads)
 
 	dnsxRunner, err := runner.New(options)
 	if err != nil {
@@ -1,15 +1,20 @@
 package gonmap
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
 var nmap *Nmap
 
+// repairOnce 内置探针文本只需修复一次，重新加载探针时不再重复替换
+var repairOnce sync.Once
+
 var ProbesCount = 0     //探针数
 var MatchCount = 0      //指纹数
 var UsedProbesCount = 0 //已使用探针数
@@ -29,7 +34,7 @@ func init() {
 
 func initWithFilter(filter int) {
 	//初始化NMAP探针库
-	repairNMAPString()
+	repairOnce.Do(repairNMAPString)
 	nmap = &Nmap{
 		exclude:      emptyPortList,
 		probeNameMap: make(map[string]*probe),
@@ -68,6 +73,7 @@ func initWithFilter(filter int) {
 
 func statistical() {
 	ProbesCount = len(nmap.probeSort)
+	MatchCount, UsedMatchCount = 0, 0
 	for _, p := range nmap.probeNameMap {
 		MatchCount += len(p.matchGroup)
 	}
@@ -81,6 +87,14 @@ func repairNMAPString() {
 	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, "${backquote}", "`")
 	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `q|GET / HTTP/1.0\r\n\r\n|`,
 		`q|GET / HTTP/1.0\r\nHost: {Host}\r\nUser-Agent: Mozilla/5.0 (Windows; U; MSIE 9.0; Windows NT 9.0; en-US)\r\nAccept-Language: zh-CN,zh;q=0.8,zh-TW;q=0.7,zh-HK;q=0.5,en-US;q=0.3,en;q=0.2\r\nAccept: */*\r\n\r\n|`)
+	nmapServiceProbes = repairProbeString(nmapServiceProbes)
+	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `match rtmp`, `# match rtmp`)
+	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `nmap`, `pamn`)
+	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `Nmap`, `pamn`)
+}
+
+// repairProbeString 将nmap-service-probes中Go正则不支持的写法替换为等价或近似的写法，内置探针与用户探针文件都会经过此处理
+func repairProbeString(nmapServiceProbes string) string {
 	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `\1`, `$1`)
 	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `(?=\\)`, `(?:\\)`)
 	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `(?=[\w._-]{5,15}\r?\n$)`, `(?:[\w._-]{5,15}\r?\n$)`)
@@ -98,9 +112,7 @@ func repairNMAPString() {
 	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `(?<=.)`, `(?:.)`)
 	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `(?<=\?)`, `(?:\?)`)
 	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `\x20\x02\x00.`, `\x20\x02..`)
-	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `match rtmp`, `# match rtmp`)
-	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `nmap`, `pamn`)
-	nmapServiceProbes = strings.ReplaceAll(nmapServiceProbes, `Nmap`, `pamn`)
+	return nmapServiceProbes
 }
 
 func customNMAPMatch() {
@@ -169,10 +181,97 @@ func SetFilter(filter int) {
 	initWithFilter(filter)
 }
 
+// ResetProbes 重新加载内置探针，移除LoadProbes加载的探针与指纹，再次加载用户探针前调用
+func ResetProbes() {
+	initWithFilter(nmap.filter)
+}
+
 func SetLogger(v Logger) {
 	logger = v
 }
 
+// LoadProbes 加载nmap-service-probes格式的探针与指纹，name为文件名，用于错误信息
+// 新探针与内置探针一样按rarity排序并加入ports/sslports对应的探针列表；
+// 与已有探针同名的Probe块不替换原探针，其中的match/softmatch先于原有指纹匹配，ports/sslports追加到原有端口
+// 存在错误时不加载文件中的任何内容
+func LoadProbes(name string, s string) error {
+	var probes []*probe
+	for i, line := range strings.Split(repairProbeString(s), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		index := strings.Index(line, " ")
+		if index <= 0 {
+			return fmt.Errorf("%s:%d: 指令格式不正确 %s", name, i+1, line)
+		}
+		commandName := line[:index]
+		if commandName == "Exclude" {
+			continue
+		}
+		if !nmap.isCommand(line) {
+			return fmt.Errorf("%s:%d: 未知的指令 %s", name, i+1, commandName)
+		}
+		if commandName == "Probe" {
+			probes = append(probes, &probe{ports: emptyPortList, sslports: emptyPortList})
+		} else if len(probes) == 0 {
+			return fmt.Errorf("%s:%d: %s 之前缺少 Probe 语句", name, i+1, commandName)
+		}
+		if err := loadProbeLine(probes[len(probes)-1], line); err != nil {
+			return fmt.Errorf("%s:%d: %v", name, i+1, err)
+		}
+	}
+
+	// fallback可指向内置探针或同一文件中的探针
+	for _, p := range probes {
+		if p.fallback == "" || strings.HasPrefix(p.fallback, "TCP_") || strings.HasPrefix(p.fallback, "UDP_") {
+			continue
+		}
+		for _, prefix := range []string{"TCP_", "UDP_"} {
+			if probeExist(probes, prefix+p.fallback) {
+				p.fallback = prefix + p.fallback
+				break
+			}
+		}
+		if !probeExist(probes, p.fallback) {
+			return fmt.Errorf("%s: 探针 %s 的fallback %s 不存在", name, p.name, p.fallback)
+		}
+	}
+
+	for _, p := range probes {
+		nmap.mergeProbe(p)
+	}
+	for index, value := range nmap.portProbeMap {
+		nmap.portProbeMap[index] = nmap.sortOfRarity(value)
+	}
+	statistical()
+	return nil
+}
+
+// loadProbeLine 解析单行指令，将解析时的panic转为错误
+func loadProbeLine(p *probe, line string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	p.loadLine(line)
+	return nil
+}
+
+// probeExist 探针是否为内置探针或probes中的探针
+func probeExist(probes []*probe, name string) bool {
+	if _, ok := nmap.probeNameMap[name]; ok {
+		return true
+	}
+	for _, p := range probes {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // 功能类
 func New() *Nmap {
 	n := *nmap
@@ -0,0 +1,121 @@
+package gonmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadProbesErrors(t *testing.T) {
+	defer ResetProbes()
+	probes, matches := ProbesCount, MatchCount
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"未知指令", "Probe TCP Foo q|foo|\nunknown foo\n", "未知的指令"},
+		{"指令格式", "Probe TCP Foo q|foo|\nrarity\n", "指令格式不正确"},
+		{"缺少Probe", "# 注释\nmatch foo m|^foo|\n", "之前缺少 Probe 语句"},
+		{"fallback不存在", "Probe TCP Foo q|foo|\nfallback NoSuchProbe\n", "fallback NoSuchProbe 不存在"},
+		{"错误的正则", "Probe TCP Foo q|foo|\nmatch foo m|^(foo|\n", "bad.probes:2"},
+		// 出错前的探针也不会被加载
+		{"部分错误", "Probe TCP Foo q|foo|\nmatch foo m|^foo|\nProbe TCP Bar q|bar|\nbad line here\n", "bad.probes:4"},
+	}
+	for _, tt := range tests {
+		err := LoadProbes("bad.probes", tt.content)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: LoadProbes error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+	if ProbesCount != probes || MatchCount != matches {
+		t.Errorf("出错的文件不应加载任何探针: 探针 %d -> %d, 指纹 %d -> %d", probes, ProbesCount, matches, MatchCount)
+	}
+	if _, ok := nmap.probeNameMap["TCP_Foo"]; ok {
+		t.Errorf("出错的文件中的探针被加载")
+	}
+}
+
+func TestLoadProbesNew(t *testing.T) {
+	defer ResetProbes()
+	err := LoadProbes("new.probes", `Probe TCP DdddHello q|HELLO\r\n|
+rarity 1
+ports 31337
+fallback GetRequest
+match dddd-test m|^HELLO (\d+)\r\n| p/Dddd Test/ v/$1/
+Probe TCP DdddChild q|CHILD\r\n|
+rarity 9
+ports 31338
+fallback DdddHello
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := nmap.probeNameMap["TCP_DdddHello"]
+	if !ok {
+		t.Fatal("新探针未加载")
+	}
+	if len(p.matchGroup) != 1 || p.matchGroup[0].service != "dddd-test" || p.fallback != "TCP_GetRequest" {
+		t.Errorf("新探针 = %+v", p)
+	}
+	if fallback := nmap.probeNameMap["TCP_DdddChild"].fallback; fallback != "TCP_DdddHello" {
+		t.Errorf("同一文件中的fallback = %s", fallback)
+	}
+	if !nmap.portProbeMap[31337].exist("TCP_DdddHello") || !nmap.portProbeMap[0].exist("TCP_DdddHello") {
+		t.Errorf("新探针未加入端口探针列表")
+	}
+}
+
+func TestLoadProbesMerge(t *testing.T) {
+	defer ResetProbes()
+	old := nmap.probeNameMap["TCP_GenericLines"]
+	matches := len(old.matchGroup)
+	hasPort := nmap.portProbeMap[31339].exist("TCP_GenericLines")
+
+	err := LoadProbes("merge.probes", `Probe TCP GenericLines q|\r\n\r\n|
+ports 31339
+match dddd-test m|^DDDD nmap banner|
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := nmap.probeNameMap["TCP_GenericLines"]
+	if merged != old {
+		t.Errorf("同名探针不应替换原探针")
+	}
+	if len(merged.matchGroup) != matches+1 || merged.matchGroup[0].service != "dddd-test" {
+		t.Errorf("用户指纹应先于原有指纹匹配, 共 %d 条, 第一条 %s", len(merged.matchGroup), merged.matchGroup[0].service)
+	}
+	// 用户文件中的nmap不会被改写
+	if pattern := merged.matchGroup[0].pattern; !strings.Contains(pattern, "nmap") {
+		t.Errorf("用户指纹被改写: %s", pattern)
+	}
+	if !hasPort && !nmap.portProbeMap[31339].exist("TCP_GenericLines") {
+		t.Errorf("ports未追加到原探针")
+	}
+}
+
+func TestResetProbes(t *testing.T) {
+	defer ResetProbes()
+	ResetProbes()
+	probes, matches := ProbesCount, MatchCount
+	content := "Probe TCP GenericLines q|\\r\\n\\r\\n|\nmatch dddd-test m|^DDDD|\nProbe TCP DdddHello q|HELLO|\n"
+
+	// 每次扫描都重新加载用户探针，指纹不应重复合并
+	for i := 0; i < 3; i++ {
+		ResetProbes()
+		if ProbesCount != probes || MatchCount != matches {
+			t.Fatalf("ResetProbes后 探针 %d 指纹 %d, want %d %d", ProbesCount, MatchCount, probes, matches)
+		}
+		if err := LoadProbes("user.probes", content); err != nil {
+			t.Fatal(err)
+		}
+		if ProbesCount != probes+1 || MatchCount != matches+1 {
+			t.Errorf("第%d次加载后 探针 %d 指纹 %d, want %d %d", i+1, ProbesCount, MatchCount, probes+1, matches+1)
+		}
+	}
+	ResetProbes()
+	if _, ok := nmap.probeNameMap["TCP_DdddHello"]; ok {
+		t.Errorf("ResetProbes后用户探针仍然存在")
+	}
+}
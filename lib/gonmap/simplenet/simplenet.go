@@ -9,9 +9,14 @@ import (
 	"time"
 )
 
+// Dial 建立连接，调用方可替换以统一限速或使用代理
+var Dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout(network, address, timeout)
+}
+
 func tcpSend(protocol string, netloc string, data string, duration time.Duration, size int) (string, error) {
 	protocol = strings.ToLower(protocol)
-	conn, err := net.DialTimeout(protocol, netloc, duration)
+	conn, err := Dial(protocol, netloc, duration)
 	if err != nil {
 		//fmt.Println(conn)
 		return "", errors.New(err.Error() + " STEP1:CONNECT")
@@ -55,15 +60,18 @@ func tlsSend(protocol string, netloc string, data string, duration time.Duration
 		InsecureSkipVerify: true,
 		MinVersion:         tls.VersionTLS10,
 	}
-	dialer := &net.Dialer{
-		Timeout:  duration,
-		Deadline: time.Now().Add(duration * 2),
-	}
-	conn, err := tls.DialWithDialer(dialer, protocol, netloc, config)
+	rawConn, err := Dial(protocol, netloc, duration)
 	if err != nil {
 		return "", errors.New(err.Error() + " STEP1:CONNECT")
 	}
+	_ = rawConn.SetDeadline(time.Now().Add(duration * 2))
+	conn := tls.Client(rawConn, config)
 	defer conn.Close()
+	err = conn.Handshake()
+	if err != nil {
+		return "", errors.New(err.Error() + " STEP1:CONNECT")
+	}
+	_ = rawConn.SetDeadline(time.Time{})
 	_, err = io.WriteString(conn, data)
 	if err != nil {
 		return "", errors.New(err.Error() + " STEP2:WRITE")
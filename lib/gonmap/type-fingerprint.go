@@ -11,10 +11,12 @@ type FingerPrint struct {
 	Hostname        string
 	OperatingSystem string
 	DeviceType      string
+	CPE             []string
 	//  p/vendorproductname/
 	//	v/version/
 	//	i/info/
 	//	h/hostname/
 	//	o/operatingsystem/
 	//	d/devicetype/
+	//	cpe:/cpename/
 }
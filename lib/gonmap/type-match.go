@@ -33,6 +33,8 @@ var matchVersionInfoRegexps = map[string]*regexp.Regexp{
 	"DEVICE":      regexp.MustCompile("d/([^/]+)/"),
 }
 
+var matchCPERegexp = regexp.MustCompile("cpe:/([^/]+)/")
+
 var matchVersionInfoHelperRegxP = regexp.MustCompile(`\$P\((\d)\)`)
 var matchVersionInfoHelperRegx = regexp.MustCompile(`\$(\d)`)
 
@@ -66,6 +68,7 @@ func parseMatch(s string, soft bool) *match {
 		Hostname:         m.getVersionInfo(s, "HOSTNAME"),
 		OperatingSystem:  m.getVersionInfo(s, "OS"),
 		DeviceType:       m.getVersionInfo(s, "DEVICE"),
+		CPE:              m.getCPE(s),
 	}
 	return m
 }
@@ -88,7 +91,11 @@ func (m *match) getPatternRegexp(pattern string, opt string) *regexp.Regexp {
 		}
 	}
 	//pattern = regexp.MustCompile(`\\x[89a-f][0-9a-f]`).ReplaceAllString(pattern,".")
-	return regexp.MustCompile(pattern)
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		panic(fmt.Errorf("match 正则 %s 无法编译: %v", m.pattern, err))
+	}
+	return r
 }
 
 func (m *match) getVersionInfo(s string, regID string) string {
@@ -99,7 +106,21 @@ func (m *match) getVersionInfo(s string, regID string) string {
 	}
 }
 
+func (m *match) getCPE(s string) []string {
+	var r []string
+	for _, sub := range matchCPERegexp.FindAllStringSubmatch(s, -1) {
+		r = append(r, sub[1])
+	}
+	return r
+}
+
 func (m *match) makeVersionInfo(s string, f *FingerPrint) {
+	f.CPE = nil
+	for _, cpe := range m.versionInfo.CPE {
+		//版本等字段未匹配到时去掉末尾多余的冒号
+		cpe = strings.TrimRight(m.makeVersionInfoSubHelper(s, cpe), ":")
+		f.CPE = append(f.CPE, "cpe:/"+cpe)
+	}
 	f.Info = m.makeVersionInfoSubHelper(s, m.versionInfo.Info)
 	f.DeviceType = m.makeVersionInfoSubHelper(s, m.versionInfo.DeviceType)
 	f.Hostname = m.makeVersionInfoSubHelper(s, m.versionInfo.Hostname)
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"github.com/miekg/dns"
+	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -79,6 +82,49 @@ func (n *Nmap) Scan(ip string, port int) (status Status, response *Response) {
 	return n.getRealResponse(ip, port, 3*time.Second, otherProbes...)
 }
 
+// ScanUDP 依次发送端口对应的UDP探针，收到响应即为开放，无对应探针的端口发送空数据包
+// 无响应时返回Open，表示端口开放或被过滤
+func (n *Nmap) ScanUDP(ip string, port int) (status Status, response *Response) {
+	var probes []*probe
+	for _, name := range n.probeSort {
+		p := n.probeNameMap[name]
+		if p.protocol == "UDP" && (p.ports.exist(port) || p.sslports.exist(port)) {
+			probes = append(probes, p)
+		}
+	}
+	sort.SliceStable(probes, func(i, j int) bool {
+		return probes[i].rarity < probes[j].rarity
+	})
+
+	if len(probes) == 0 {
+		emptyProbe := &probe{protocol: "UDP"}
+		text, _, err := emptyProbe.scan(ip, port, false, n.timeout, 10240)
+		if err != nil {
+			if strings.Contains(err.Error(), "refused") {
+				return Closed, nil
+			}
+			return Open, nil
+		}
+		return NotMatched, &Response{Raw: text, FingerPrint: &FingerPrint{}}
+	}
+
+	status = Open
+	for _, p := range probes {
+		s, r := n.getResponse(ip, port, false, n.timeout, p)
+		switch s {
+		case Matched:
+			return s, r
+		case NotMatched:
+			status, response = s, r
+		case Closed:
+			if status == Open {
+				return Closed, nil
+			}
+		}
+	}
+	return status, response
+}
+
 func (n *Nmap) getRealResponse(host string, port int, timeout time.Duration, probes ...string) (status Status, response *Response) {
 	status, response = n.getResponseByProbes(host, port, timeout, probes...)
 	if status != Matched {
@@ -301,6 +347,25 @@ func (n *Nmap) pushProbe(p probe) {
 
 }
 
+// mergeProbe 加入新探针，同名探针已存在时合并指纹与端口
+func (n *Nmap) mergeProbe(p *probe) {
+	old, ok := n.probeNameMap[p.name]
+	if !ok {
+		n.pushProbe(*p)
+		return
+	}
+	old.matchGroup = append(p.matchGroup, old.matchGroup...)
+	for _, ports := range []PortList{p.ports, p.sslports} {
+		for _, port := range ports {
+			if old.rarity <= n.filter && !old.ports.exist(port) && !old.sslports.exist(port) {
+				n.portProbeMap[port] = append(n.portProbeMap[port], old.name)
+			}
+		}
+	}
+	old.ports = old.ports.append(p.ports...)
+	old.sslports = old.sslports.append(p.sslports...)
+}
+
 func (n *Nmap) fixFallback() {
 	for probeName, probeType := range n.probeNameMap {
 		fallback := probeType.fallback
@@ -369,7 +434,7 @@ func (n *Nmap) sortOfRarity(list ProbeList) ProbeList {
 
 // 工具函数
 func DnsScan(host string, port int) bool {
-	domainServer := fmt.Sprintf("%s:%d", host, port)
+	domainServer := net.JoinHostPort(host, strconv.Itoa(port))
 	c := dns.Client{
 		Timeout: 2 * time.Second,
 	}
@@ -1,6 +1,7 @@
 package gonmap
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,7 +17,7 @@ var emptyPortList = PortList([]int{})
 func parsePortList(express string) PortList {
 	var list = PortList([]int{})
 	if portGroupRegx.MatchString(express) == false {
-		panic("port expression string invalid")
+		panic(fmt.Errorf("端口列表 %s 格式不正确", express))
 	}
 	for _, expr := range strings.Split(express, ",") {
 		rArr := portRangeRegx.FindStringSubmatch(expr)
@@ -2,8 +2,8 @@ package gonmap
 
 import (
 	"errors"
-	"fmt"
 	"github.com/lcvvvv/gonmap/simplenet"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
@@ -35,9 +35,9 @@ type probe struct {
 }
 
 func (p *probe) scan(host string, port int, tls bool, timeout time.Duration, size int) (string, bool, error) {
-	uri := fmt.Sprintf("%s:%d", host, port)
+	uri := net.JoinHostPort(host, strconv.Itoa(port))
 
-	sendRaw := strings.Replace(p.sendRaw, "{Host}", fmt.Sprintf("%s:%d", host, port), -1)
+	sendRaw := strings.Replace(p.sendRaw, "{Host}", uri, -1)
 
 	text, err := simplenet.Send(p.protocol, tls, uri, sendRaw, timeout, size)
 	if err == nil {
@@ -5,6 +5,7 @@ import (
 	"github.com/projectdiscovery/httpx/runner"
 	errorutil "github.com/projectdiscovery/utils/errors"
 	"os"
+	"sync"
 )
 
 func RemoveDuplicateElement(input []string) []string {
@@ -19,68 +20,85 @@ func RemoveDuplicateElement(input []string) []string {
 	return result
 }
 
-func RemoveUsedUrl(urls []string) []string {
+// Session 一次扫描内的Web探测，记录已探测的URL，页面跳转得到的URL不重复探测
+type Session struct {
+	lock sync.Mutex
+	used map[string]struct{}
+}
+
+func NewSession() *Session {
+	return &Session{used: make(map[string]struct{})}
+}
+
+func (s *Session) markUsed(urls ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, u := range urls {
+		s.used[u] = struct{}{}
+	}
+}
+
+// removeUsed 去重并移除已探测过的URL
+func (s *Session) removeUsed(urls []string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	var result []string
 	for _, u := range urls {
-		flag := false
-		for _, gu := range GlobalUsedUrl {
-			if gu == u {
-				flag = true
-				break
-			}
-		}
-		if !flag {
+		if _, ok := s.used[u]; !ok {
 			result = append(result, u)
 		}
 	}
 	return RemoveDuplicateElement(result)
 }
 
-var GlobalUsedUrl []string
-
-func CallHTTPx(urls []string, callBack func(resp runner.Result), proxy string, threads int, timeout int) {
+// CallHTTPx 探测urls，onProgress在每个目标探测结束后调用，可为nil
+func (s *Session) CallHTTPx(urls []string, callBack func(resp runner.Result), onProgress func(), proxy string, threads int, timeout int) {
 	gologger.Info().Msg("获取Web响应中")
+	s.callHTTPxRounds(RemoveDuplicateElement(urls), 0, callBack, onProgress, proxy, threads, timeout)
+}
 
-	nextUrls := RemoveDuplicateElement(urls)
+// CallHTTPxStream 边接收边探测urls中的目标，通道关闭且探测结束后再探测页面跳转得到的URL
+func (s *Session) CallHTTPxStream(urls <-chan string, callBack func(resp runner.Result), onProgress func(), proxy string, threads int, timeout int) {
+	gologger.Info().Msg("获取Web响应中")
 
-	times := 0
-	for len(nextUrls) > 0 && times < 3 {
-		options := runner.Options{
-			Methods:                   "GET",
-			InputTargetHost:           nextUrls,
-			Favicon:                   true,
-			Hashes:                    "md5",
-			OutputServerHeader:        true,
-			TLSProbe:                  true,
-			MaxResponseBodySizeToRead: 1048576,
-			FollowHostRedirects:       true,
-			MaxRedirects:              5,
-			ExtractTitle:              true,
-			Timeout:                   timeout,
-			Retries:                   2,
-			HTTPProxy:                 proxy,
-			NoFallbackScheme:          true,
-			RandomAgent:               true,
-			Threads:                   threads,
+	input := make(chan string)
+	go func() {
+		defer close(input)
+		for u := range urls {
+			s.markUsed(u)
+			input <- u
 		}
-
-		if err := options.ValidateOptions(); err != nil {
-			gologger.Error().Msgf("params error")
+	}()
+
+	options := newProbeOptions(nil, onProgress, proxy, threads, timeout)
+	options.Stream = true
+	options.InputTargetChan = input
+	httpxRunner := newRunner(&options, callBack)
+	if httpxRunner == nil {
+		for range input {
 		}
+		return
+	}
+	httpxRunner.RunEnumeration()
+	nextUrls := s.removeUsed(httpxRunner.NextCheckUrl)
+	httpxRunner.Close()
 
-		httpxRunner, err := runner.New(&options)
-		if err != nil {
-			gologger.Error().Msgf("runner.New(&options) error")
-		}
-		httpxRunner.CallBack = callBack
+	s.callHTTPxRounds(nextUrls, 1, callBack, onProgress, proxy, threads, timeout)
+}
 
-		for _, u := range nextUrls {
-			GlobalUsedUrl = append(GlobalUsedUrl, u)
+// callHTTPxRounds 探测nextUrls，页面跳转得到的新URL继续探测，共探测3轮
+func (s *Session) callHTTPxRounds(nextUrls []string, times int, callBack func(resp runner.Result), onProgress func(), proxy string, threads int, timeout int) {
+	for len(nextUrls) > 0 && times < 3 {
+		options := newProbeOptions(nextUrls, onProgress, proxy, threads, timeout)
+		httpxRunner := newRunner(&options, callBack)
+		if httpxRunner == nil {
+			return
 		}
-		GlobalUsedUrl = RemoveDuplicateElement(GlobalUsedUrl)
+
+		s.markUsed(nextUrls...)
 
 		httpxRunner.RunEnumeration()
-		nextUrls = RemoveUsedUrl(httpxRunner.NextCheckUrl)
+		nextUrls = s.removeUsed(httpxRunner.NextCheckUrl)
 		httpxRunner.Close()
 		times += 1
 
@@ -88,13 +106,54 @@ func CallHTTPx(urls []string, callBack func(resp runner.Result), proxy string, t
 
 }
 
+func newProbeOptions(urls []string, onProgress func(), proxy string, threads int, timeout int) runner.Options {
+	return runner.Options{
+		Methods:                   "GET",
+		InputTargetHost:           urls,
+		Favicon:                   true,
+		Hashes:                    "md5",
+		OutputServerHeader:        true,
+		TLSProbe:                  true,
+		MaxResponseBodySizeToRead: 1048576,
+		FollowHostRedirects:       true,
+		MaxRedirects:              5,
+		ExtractTitle:              true,
+		Timeout:                   timeout,
+		Retries:                   2,
+		HTTPProxy:                 proxy,
+		NoFallbackScheme:          true,
+		RandomAgent:               true,
+		Threads:                   threads,
+		OnProgress:                onProgress,
+	}
+}
+
+func newRunner(options *runner.Options, callBack func(resp runner.Result)) *runner.Runner {
+	if err := options.ValidateOptions(); err != nil {
+		gologger.Error().Msgf("params error")
+	}
+
+	httpxRunner, err := runner.New(options)
+	if err != nil {
+		gologger.Error().Msgf("runner.New(&options) error")
+		return nil
+	}
+	httpxRunner.CallBack = callBack
+	return httpxRunner
+}
+
 func init() {
 	if os.Getenv("DEBUG") != "" {
 		errorutil.ShowStackTrace = true
 	}
 }
 
-func DirBrute(urls []string, callBack func(resp runner.Result), proxy string, threads int, timeout int) {
+func DirBrute(urls []string, callBack func(resp runner.Result), onProgress func(), proxy string, threads int, timeout int) {
+	DirBruteWithHosts(urls, nil, callBack, onProgress, proxy, threads, timeout)
+}
+
+// DirBruteWithHosts 与DirBrute相同，hostIPs中的host:port不解析域名，依次连接为其指定的每个IP
+func DirBruteWithHosts(urls []string, hostIPs map[string][]string, callBack func(resp runner.Result), onProgress func(), proxy string, threads int, timeout int) {
 	urls = RemoveDuplicateElement(urls)
 
 	options := runner.Options{
@@ -114,6 +173,8 @@ func DirBrute(urls []string, callBack func(resp runner.Result), proxy string, th
 		NoFallbackScheme:          true,
 		RandomAgent:               true,
 		Threads:                   threads,
+		HostIPs:                   hostIPs,
+		OnProgress:                onProgress,
 	}
 
 	if err := options.ValidateOptions(); err != nil {
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -35,6 +36,19 @@ type HTTPX struct {
 	Dialer        *fastdialer.Dialer
 }
 
+// fixedIPDial 请求指定了IP时直接连接该IP，不再解析域名，用于无法解析的域名
+// 经过代理时连接的是代理地址，不做修改
+func fixedIPDial(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		ip, _ := ctx.Value(fastdialer.IP).(string)
+		host, port, err := net.SplitHostPort(address)
+		if ip != "" && err == nil && host == ctx.Value(fastdialer.SniName) {
+			address = net.JoinHostPort(strings.Trim(ip, "[]"), port)
+		}
+		return dial(ctx, network, address)
+	}
+}
+
 // New httpx instance
 func New(options *Options) (*HTTPX, error) {
 	httpx := &HTTPX{}
@@ -104,8 +118,8 @@ func New(options *Options) (*HTTPX, error) {
 		}
 	}
 	transport := &http.Transport{
-		DialContext:         httpx.Dialer.Dial,
-		DialTLSContext:      httpx.Dialer.DialTLS,
+		DialContext:         fixedIPDial(httpx.Dialer.Dial),
+		DialTLSContext:      fixedIPDial(httpx.Dialer.DialTLS),
 		MaxIdleConnsPerHost: -1,
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: true,
@@ -62,6 +62,9 @@ func convertCertificateToResponse(hostname string, cert *x509.Certificate) *clie
 		IssuerOrg:    cert.Issuer.Organization,
 		SubjectCN:    cert.Subject.CommonName,
 		SubjectOrg:   cert.Subject.Organization,
+		Serial:       clients.FormatToSerialNumber(cert.SerialNumber),
+		// 保留完整证书，供dddd提取公钥类型等信息
+		Certificate: clients.PemEncode(cert.Raw),
 		FingerprintHash: clients.CertificateResponseFingerprintHash{
 			MD5:    clients.MD5Fingerprint(cert.Raw),
 			SHA1:   clients.SHA1Fingerprint(cert.Raw),
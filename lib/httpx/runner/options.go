@@ -150,6 +150,7 @@ type Options struct {
 	SocksProxy                string
 	InputFile                 string
 	InputTargetHost           goflags.StringSlice
+	InputTargetChan           <-chan string // Stream模式下从通道读取目标直到通道关闭
 	Methods                   string
 	RequestURI                string
 	RequestURIs               string
@@ -267,7 +268,9 @@ type Options struct {
 	NoDecode                  bool
 	Screenshot                bool
 	UseInstalledChrome        bool
-	IsBrute                   bool // 是否为目录爆破，如果是目录爆破默认不存响应
+	IsBrute                   bool                // 是否为目录爆破，如果是目录爆破默认不存响应
+	HostIPs                   map[string][]string // host:port : IP，依次连接每个指定的IP而不解析域名，用于域名绑定与证书中无法解析的域名
+	OnProgress                func()              // 每个目标探测结束(无论成功与否)后调用，供调用方统计进度
 }
 
 // ParseOptions parses the command line options for application
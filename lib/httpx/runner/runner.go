@@ -81,6 +81,9 @@ type Runner struct {
 	CallBack        func(resp Result)
 }
 
+// RequestHook 每个请求发出前调用，返回的函数在请求结束后调用，供调用方统一限速
+var RequestHook func(target string) (release func())
+
 // New creates a new client for running enumeration process.
 func New(options *Options) (*Runner, error) {
 	runner := &Runner{
@@ -417,6 +420,14 @@ func (r *Runner) streamInput() (chan string, error) {
 	go func() {
 		defer close(out)
 
+		if r.options.InputTargetChan != nil {
+			for item := range r.options.InputTargetChan {
+				if r.options.SkipDedupe || r.testAndSet(item) {
+					out <- item
+				}
+			}
+			return
+		}
 		if fileutil.FileExists(r.options.InputFile) {
 			fchan, err := fileutil.ReadFile(r.options.InputFile)
 			if err != nil {
@@ -697,6 +708,9 @@ func (r *Runner) RunEnumeration() {
 		}
 
 		for resp := range output {
+			if r.options.OnProgress != nil {
+				r.options.OnProgress()
+			}
 			if resp.Err != nil {
 				// Change the error message if any port value passed explicitly
 				if url, err := r.parseURL(resp.URL); err == nil && url.Port() != "" {
@@ -1110,6 +1124,10 @@ func (r *Runner) targets(hp *httpx.HTTPX, target string) chan httpx.Target {
 			for _, ip := range ips {
 				results <- httpx.Target{Host: target, CustomIP: ip}
 			}
+		case len(r.customIPs(target)) > 0:
+			for _, ip := range r.customIPs(target) {
+				results <- httpx.Target{Host: target, CustomIP: ip}
+			}
 		case !stringsutil.HasPrefixAny(target, "http://", "https://") && stringsutil.ContainsAny(target, ","):
 			idxComma := strings.Index(target, ",")
 			results <- httpx.Target{Host: target[idxComma+1:], CustomHost: target[:idxComma]}
@@ -1169,7 +1187,8 @@ retry:
 		} else {
 			requestIP = target.CustomIP
 		}
-		ctx := context.WithValue(context.Background(), "ip", requestIP) //nolint
+		ctx := context.WithValue(context.Background(), fastdialer.IP, requestIP)
+		ctx = context.WithValue(ctx, fastdialer.SniName, URL.Hostname())
 		req, err = hp.NewRequestWithContext(ctx, method, URL.String())
 	} else {
 		req, err = hp.NewRequest(method, URL.String())
@@ -1202,6 +1221,9 @@ retry:
 	}
 
 	r.ratelimiter.Take()
+	if RequestHook != nil {
+		defer RequestHook(URL.String())()
+	}
 
 	// with rawhttp we should say to the server to close the connection, otherwise it will remain open
 	if scanopts.Unsafe {
@@ -2006,6 +2028,25 @@ func (r *Runner) skipCDNPort(host string, port string) bool {
 	return false
 }
 
+// customIPs 返回HostIPs中为目标host:port指定的IP
+func (r *Runner) customIPs(target string) []string {
+	if len(r.options.HostIPs) == 0 {
+		return nil
+	}
+	URL, err := r.parseURL(target)
+	if err != nil {
+		return nil
+	}
+	port := URL.Port()
+	if port == "" {
+		port = "80"
+		if URL.Scheme == httpx.HTTPS {
+			port = "443"
+		}
+	}
+	return r.options.HostIPs[net.JoinHostPort(URL.Hostname(), port)]
+}
+
 // parseURL parses url based on cli option(unsafe)
 func (r *Runner) parseURL(url string) (*urlutil.URL, error) {
 	urlx, err := urlutil.ParseURL(url, r.options.Unsafe)
@@ -3,6 +3,7 @@ package runner
 import (
 	"context"
 	"encoding/json"
+	"io/fs"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -79,6 +80,9 @@ type Runner struct {
 
 const pprofServerAddress = "127.0.0.1:8086"
 
+// TemplatesFS 不为nil时从该文件系统读取模板，options.Templates为其中的相对路径，用于内置模板的构建
+var TemplatesFS fs.FS
+
 // New creates a new client for running the enumeration process.
 func New(options *types.Options) (*Runner, error) {
 	runner := &Runner{
@@ -159,7 +163,11 @@ func New(options *types.Options) (*Runner, error) {
 		runner.browser = browser
 	}
 
-	runner.catalog = disk.NewCatalog(config.DefaultConfig.TemplatesDirectory)
+	if TemplatesFS != nil {
+		runner.catalog = disk.NewFSCatalog(TemplatesFS)
+	} else {
+		runner.catalog = disk.NewCatalog(config.DefaultConfig.TemplatesDirectory)
+	}
 
 	var httpclient *retryablehttp.Client
 	if options.ProxyInternal && types.ProxyURL != "" || types.ProxySocksURL != "" {
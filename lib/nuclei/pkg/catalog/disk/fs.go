@@ -0,0 +1,140 @@
+package disk
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/nuclei/v3/pkg/catalog/config"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+// FSCatalog 从虚拟文件系统(如embed.FS)中读取模板，路径均为相对于文件系统根目录的/分隔路径
+type FSCatalog struct {
+	templatesFS fs.FS
+}
+
+// NewFSCatalog 创建基于fs.FS的模板目录
+func NewFSCatalog(fsys fs.FS) *FSCatalog {
+	return &FSCatalog{templatesFS: fsys}
+}
+
+// fsPath 将模板路径转换为fs.FS可用的路径
+func fsPath(name string) string {
+	name = filepath.ToSlash(name)
+	name = strings.TrimPrefix(name, "/")
+	name = path.Clean(name)
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// OpenFile opens a file and returns an io.ReadCloser to the file.
+func (c *FSCatalog) OpenFile(filename string) (io.ReadCloser, error) {
+	return c.templatesFS.Open(fsPath(filename))
+}
+
+// GetTemplatesPath returns a list of paths for the provided template list.
+func (c *FSCatalog) GetTemplatesPath(definitions []string) ([]string, map[string]error) {
+	processed := make(map[string]struct{})
+	allTemplates := []string{}
+	erred := make(map[string]error)
+
+	for _, t := range definitions {
+		if stringsutil.ContainsAny(t, knownConfigFiles...) {
+			continue
+		}
+		paths, err := c.GetTemplatePath(t)
+		if err != nil {
+			erred[t] = err
+		}
+		for _, p := range paths {
+			if _, ok := processed[p]; !ok {
+				processed[p] = struct{}{}
+				allTemplates = append(allTemplates, p)
+			}
+		}
+	}
+	return allTemplates, erred
+}
+
+// GetTemplatePath returns the template files matched by a file, folder or glob path.
+func (c *FSCatalog) GetTemplatePath(target string) ([]string, error) {
+	target = fsPath(target)
+	if strings.Contains(target, "*") {
+		matches, err := fs.Glob(c.templatesFS, target)
+		if err != nil {
+			return nil, errors.Errorf("wildcard found, but unable to glob: %s\n", err)
+		}
+		if len(matches) == 0 {
+			return nil, errors.Errorf("no templates found for path")
+		}
+		return matches, nil
+	}
+
+	info, err := fs.Stat(c.templatesFS, target)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not find file")
+	}
+	if !info.IsDir() {
+		if config.GetTemplateFormatFromExt(target) == config.Unknown {
+			return nil, nil
+		}
+		return []string{target}, nil
+	}
+
+	var results []string
+	err = fs.WalkDir(c.templatesFS, target, func(p string, d fs.DirEntry, err error) error {
+		// continue on errors
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() && config.GetTemplateFormatFromExt(p) != config.Unknown &&
+			!stringsutil.ContainsAny(p, knownConfigFiles...) {
+			results = append(results, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not find directory matches")
+	}
+	if len(results) == 0 {
+		return nil, errors.Errorf("no templates found in path %s", target)
+	}
+	return results, nil
+}
+
+// ResolvePath resolves the path relative to the second path if given, otherwise relative to the root.
+func (c *FSCatalog) ResolvePath(templateName, second string) (string, error) {
+	if second != "" {
+		p := fsPath(path.Join(path.Dir(fsPath(second)), filepath.ToSlash(templateName)))
+		if _, err := fs.Stat(c.templatesFS, p); err == nil {
+			return p, nil
+		}
+	}
+	p := fsPath(templateName)
+	if _, err := fs.Stat(c.templatesFS, p); err == nil {
+		return p, nil
+	}
+	return "", fmt.Errorf("no such path found: %s", templateName)
+}
+
+// ResolveHelperFile 从模板所在目录起逐级向上查找辅助文件(如payload字典)，虚拟文件系统中的文件均允许读取
+func (c *FSCatalog) ResolveHelperFile(helperFile, templatePath string) (string, error) {
+	helperFile = filepath.ToSlash(helperFile)
+	for dir := path.Dir(fsPath(templatePath)); ; dir = path.Dir(dir) {
+		p := fsPath(path.Join(dir, helperFile))
+		if _, err := fs.Stat(c.templatesFS, p); err == nil {
+			return p, nil
+		}
+		if dir == "." || dir == "/" {
+			break
+		}
+	}
+	return "", fmt.Errorf("no such path found: %s", helperFile)
+}
@@ -1,6 +1,8 @@
 package exportrunner
 
 import (
+	"io/fs"
+
 	"github.com/projectdiscovery/nuclei/v3/internal/runner"
 	"github.com/projectdiscovery/nuclei/v3/pkg/types"
 )
@@ -16,3 +18,8 @@ func ExportRunnerParseOptions(options *types.Options) {
 func ExportRunnerNew(options *types.Options) (*runner.Runner, error) {
 	return runner.New(options)
 }
+
+// ExportRunnerSetTemplatesFS 设置读取模板的文件系统，传入nil则从磁盘读取
+func ExportRunnerSetTemplatesFS(fsys fs.FS) {
+	runner.TemplatesFS = fsys
+}
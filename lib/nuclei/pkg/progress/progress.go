@@ -36,6 +36,15 @@ type Progress interface {
 
 var _ Progress = &StatsTicker{}
 
+// StatsHook 已完成请求数与请求总数变化时调用，参数为增量，供调用方统计进度
+var StatsHook func(requests int64, total int64)
+
+func callStatsHook(requests int64, total int64) {
+	if StatsHook != nil {
+		StatsHook(requests, total)
+	}
+}
+
 // StatsTicker is a progress instance for showing program stats
 type StatsTicker struct {
 	cloud        bool
@@ -85,6 +94,7 @@ func (p *StatsTicker) Init(hostCount int64, rulesCount int, requestCount int64)
 	p.stats.AddCounter("errors", uint64(0))
 	p.stats.AddCounter("matched", uint64(0))
 	p.stats.AddCounter("total", uint64(requestCount))
+	callStatsHook(0, requestCount)
 
 	if p.active {
 		var printCallbackFunc clistats.DynamicCallback
@@ -111,11 +121,13 @@ func (p *StatsTicker) Init(hostCount int64, rulesCount int, requestCount int64)
 // AddToTotal adds a value to the total request count
 func (p *StatsTicker) AddToTotal(delta int64) {
 	p.stats.IncrementCounter("total", int(delta))
+	callStatsHook(0, delta)
 }
 
 // IncrementRequests increments the requests counter by 1.
 func (p *StatsTicker) IncrementRequests() {
 	p.stats.IncrementCounter("requests", 1)
+	callStatsHook(1, 0)
 }
 
 // SetRequests sets the counter by incrementing it with a delta
@@ -123,6 +135,7 @@ func (p *StatsTicker) SetRequests(count uint64) {
 	value, _ := p.stats.GetCounter("requests")
 	delta := count - value
 	p.stats.IncrementCounter("requests", int(delta))
+	callStatsHook(int64(delta), 0)
 }
 
 // IncrementMatched increments the matched counter by 1.
@@ -140,6 +153,7 @@ func (p *StatsTicker) IncrementFailedRequestsBy(count int64) {
 	// mimic dropping by incrementing the completed requests
 	p.stats.IncrementCounter("requests", int(count))
 	p.stats.IncrementCounter("errors", int(count))
+	callStatsHook(count, 0)
 }
 
 func (p *StatsTicker) makePrintCallback() func(stats clistats.StatisticsClient) interface{} {
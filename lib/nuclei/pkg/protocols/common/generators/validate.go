@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/projectdiscovery/nuclei/v3/pkg/catalog/config"
+	"github.com/projectdiscovery/nuclei/v3/pkg/catalog/disk"
 	"github.com/projectdiscovery/nuclei/v3/pkg/types"
 	fileutil "github.com/projectdiscovery/utils/file"
 	folderutil "github.com/projectdiscovery/utils/folder"
@@ -22,6 +23,16 @@ func (g *PayloadGenerator) validate(payloads map[string]interface{}, templatePat
 				return errors.New("invalid number of lines in payload")
 			}
 
+			// templates loaded from a virtual filesystem resolve helper files inside it
+			if fsCatalog, ok := g.catalog.(*disk.FSCatalog); ok {
+				resolved, err := fsCatalog.ResolveHelperFile(payloadType, templatePath)
+				if err != nil {
+					return fmt.Errorf("the %s file for payload %s does not exist or does not contain enough elements", payloadType, name)
+				}
+				payloads[name] = resolved
+				continue
+			}
+
 			// check if it's a file and try to load it
 			if fileutil.FileExists(payloadType) {
 				continue
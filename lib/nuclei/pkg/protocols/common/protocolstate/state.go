@@ -16,6 +16,12 @@ import (
 // Dialer is a shared fastdialer instance for host DNS resolution
 var Dialer *fastdialer.Dialer
 
+// RequestHook 每个http/network请求发出前调用，返回的函数在请求结束后调用，供调用方统一限速
+var RequestHook func(target string) (release func())
+
+// ProxyDialer 不为nil时network等协议的明文连接经过此Dialer，优先于-proxy中的socks5代理，供调用方使用HTTP CONNECT等代理
+var ProxyDialer proxy.Dialer
+
 // Init creates the Dialer instance based on user configuration
 func Init(options *types.Options) error {
 	if Dialer != nil {
@@ -86,6 +92,9 @@ func Init(options *types.Options) error {
 		}
 		opts.ProxyDialer = &dialer
 	}
+	if ProxyDialer != nil {
+		opts.ProxyDialer = &ProxyDialer
+	}
 
 	if options.SystemResolvers {
 		opts.EnableFallback = true
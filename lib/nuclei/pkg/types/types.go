@@ -10,6 +10,7 @@ import (
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/nuclei/v3/pkg/catalog"
 	"github.com/projectdiscovery/nuclei/v3/pkg/catalog/config"
+	"github.com/projectdiscovery/nuclei/v3/pkg/catalog/disk"
 	"github.com/projectdiscovery/nuclei/v3/pkg/model/types/severity"
 	"github.com/projectdiscovery/nuclei/v3/pkg/templates/types"
 	errorutil "github.com/projectdiscovery/utils/errors"
@@ -477,6 +478,14 @@ func (options *Options) ParseHeadlessOptionalArguments() map[string]string {
 // this respects the sandbox rules and only loads files from
 // allowed directories
 func (options *Options) LoadHelperFile(helperFile, templatePath string, catalog catalog.Catalog) (io.ReadCloser, error) {
+	// templates loaded from a virtual filesystem can only reference files inside it
+	if fsCatalog, ok := catalog.(*disk.FSCatalog); ok {
+		resolved, err := fsCatalog.ResolveHelperFile(helperFile, templatePath)
+		if err != nil {
+			return nil, errorutil.NewWithErr(err).Msgf("could not open file %v", helperFile)
+		}
+		return fsCatalog.OpenFile(resolved)
+	}
 	if !options.AllowLocalFileAccess {
 		// if global file access is disabled try loading with restrictions
 		absPath, err := options.GetValidAbsPath(helperFile, templatePath)
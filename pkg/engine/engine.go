@@ -0,0 +1,253 @@
+// Package engine 将dddd的扫描流程封装为可嵌入的库
+//
+//	e, err := engine.New(engine.Options{
+//		Config: engine.DefaultConfig([]string{"192.168.0.0/24"}),
+//		OnFinger: func(r structs.FingerRecord) {
+//			fmt.Println(r.Target, r.Products)
+//		},
+//	})
+//	err = e.Run(ctx)
+package engine
+
+import (
+	"context"
+	"dddd/common"
+	"dddd/common/project"
+	"dddd/gopocs"
+	"dddd/structs"
+	"dddd/utils"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/nuclei/v3/pkg/output"
+	"sync"
+	"time"
+)
+
+// Options 扫描参数与结果回调，回调可能在多个协程中被并发调用
+type Options struct {
+	Config structs.Config
+
+	OnHost    func(structs.HostRecord)
+	OnPort    func(structs.PortRecord)
+	OnService func(structs.ServiceRecord)
+	OnCert    func(structs.CertRecord)
+	OnWeb     func(structs.WebRecord)
+	OnFinger  func(structs.FingerRecord)
+	OnNuclei  func(output.ResultEvent)
+	OnGoPoc   func(structs.GoPocsResultType)
+	OnDiff    func(structs.DiffRecord)
+	OnFlag    func(structs.HostFlagRecord) // 主机被判定为tarpit或蜜罐
+}
+
+type Engine struct {
+	opts Options
+}
+
+// DefaultProjectDB 未指定时保存项目扫描记录的数据库文件
+const DefaultProjectDB = "projects.db"
+
+// 配置目录、数据库、代理、SSH跳板与限速由同一进程内同时运行的扫描共享，最后一个扫描结束时释放
+var (
+	runtimeLock sync.Mutex
+	runtimeRefs int
+	runtimeKey  string
+)
+
+// acquireRuntime 第一个扫描初始化共享的运行环境，之后的扫描要求相关参数与其一致
+func acquireRuntime(c structs.Config) error {
+	key := fmt.Sprintf("%s|%s|%s|%s|%s|%d|%s|%d|%d|%d", c.ConfigDir, c.TCPProxy,
+		c.SSHJump, c.SSHJumpPassword, c.SSHJumpKey, c.SSHJumpConns, c.SSHJumpFingerprint,
+		c.RateLimit, c.HostRateLimit, c.HostConcurrency)
+
+	runtimeLock.Lock()
+	defer runtimeLock.Unlock()
+	if runtimeRefs > 0 {
+		if key != runtimeKey {
+			return errors.New("同一进程内同时运行的扫描，配置目录、代理、SSH跳板与限速参数必须一致")
+		}
+		runtimeRefs++
+		return nil
+	}
+	if err := common.InitRuntime(c); err != nil {
+		return err
+	}
+	gopocs.InitDic()
+	runtimeKey = key
+	runtimeRefs = 1
+	return nil
+}
+
+func releaseRuntime() {
+	runtimeLock.Lock()
+	defer runtimeLock.Unlock()
+	runtimeRefs--
+	if runtimeRefs == 0 {
+		common.CloseRuntime()
+	}
+}
+
+// DefaultConfig 返回与命令行默认参数一致的扫描配置
+func DefaultConfig(targets []string) structs.Config {
+	return structs.Config{
+		Targets:                    targets,
+		Ports:                      common.PortTOP1000,
+		SubdomainBruteForceThreads: 150,
+		PortScanType:               "tcp",
+		GetBannerThreads:           30,
+		TCPPortScanThreads:         600,
+		SYNPortScanThreads:         10000,
+		SYNRetries:                 1,
+		PortsThreshold:             300,
+		TCPPortScanTimeout:         6,
+		TCPPortScanRetries:         1,
+		HoneypotFingerThreshold:    15,
+		MasscanPath:                "masscan",
+		HunterPageSize:             100,
+		HunterMaxPageCount:         10,
+		FofaMaxCount:               100,
+		GoPocThreads:               50,
+		WebThreads:                 100,
+		WebTimeout:                 12,
+		QuakeSize:                  100,
+		ProgressInterval:           30,
+		UDPPorts:                   common.PortUDPDefault,
+		UDPScanThreads:             100,
+		TCPPingPorts:               common.PortTCPPingDefault,
+		UDPPingPorts:               common.PortUDPPingDefault,
+		SSHJumpConns:               4,
+	}
+}
+
+func New(opts Options) (*Engine, error) {
+	if len(opts.Config.Targets) == 0 && opts.Config.ResumeDir == "" &&
+		opts.Config.ImportDir == "" && opts.Config.ImportService == "" && opts.Config.ImportScan == "" {
+		return nil, errors.New("无目标输入")
+	}
+	for _, stage := range opts.Config.Stages {
+		if utils.GetItemInArray(common.AllStages, stage) == -1 {
+			return nil, fmt.Errorf("未知阶段: %s", stage)
+		}
+	}
+	if opts.Config.Diff && opts.Config.Project == "" {
+		return nil, errors.New("-diff 需要配合 -project 指定项目")
+	}
+	if opts.Config.Ports == "" {
+		opts.Config.Ports = common.PortTOP1000
+	}
+	return &Engine{opts: opts}, nil
+}
+
+// Run 执行完整的扫描流程，同一Engine或多个Engine的Run可以并发执行。
+// ctx取消后在当前阶段结束时返回，已完成阶段的断点可用于续扫
+func (e *Engine) Run(ctx context.Context) error {
+	err := acquireRuntime(e.opts.Config)
+	if err != nil {
+		return err
+	}
+	defer releaseRuntime()
+
+	scan, err := common.NewScan(e.opts.Config)
+	if err != nil {
+		return err
+	}
+	defer common.CloseScan(scan)
+	scan.Report.Hook = e.dispatch
+
+	if scan.Config.ProgressInterval > 0 {
+		stop := scan.Progress.StartReporter(time.Duration(scan.Config.ProgressInterval) * time.Second)
+		defer stop()
+	}
+	if scan.Config.StatusAddr != "" {
+		stop, err := scan.Progress.Serve(scan.Config.StatusAddr)
+		if err != nil {
+			return fmt.Errorf("进度查询接口监听失败: %v", err)
+		}
+		defer stop()
+		gologger.Info().Msgf("进度查询接口: http://%s/", scan.Config.StatusAddr)
+	}
+
+	st, err := common.LoadCheckpoint(scan)
+	if err != nil {
+		return err
+	}
+	err = workflow(ctx, scan, st)
+	if err != nil {
+		return err
+	}
+	if scan.Config.Project != "" {
+		saveProject(scan, st)
+	}
+	common.RemoveTempCheckpoint(scan)
+	return nil
+}
+
+// saveProject 保存本次扫描的资产快照，并按需输出与上一次扫描的差异
+func saveProject(scan *structs.Scan, st *structs.CheckpointState) {
+	config := scan.Config
+	dbPath := config.ProjectDB
+	if dbPath == "" {
+		dbPath = DefaultProjectDB
+	}
+	cur := project.NewSnapshot(scan, st)
+	prev, err := project.Save(dbPath, config.Project, cur)
+	if err != nil {
+		gologger.Error().Msgf("保存项目 %s 的扫描记录失败: %v", config.Project, err)
+		return
+	}
+	gologger.Info().Msgf("已保存项目 %s 的扫描记录: %s", config.Project, dbPath)
+	if !config.Diff {
+		return
+	}
+	if prev == nil {
+		gologger.Info().Msgf("项目 %s 无历史扫描记录，下次扫描时对比", config.Project)
+		return
+	}
+	project.ReportDiff(scan, prev, cur)
+}
+
+// dispatch 将结果记录分发到对应的回调
+func (e *Engine) dispatch(recordType string, data interface{}) {
+	switch v := data.(type) {
+	case structs.HostRecord:
+		if e.opts.OnHost != nil {
+			e.opts.OnHost(v)
+		}
+	case structs.PortRecord:
+		if e.opts.OnPort != nil {
+			e.opts.OnPort(v)
+		}
+	case structs.ServiceRecord:
+		if e.opts.OnService != nil {
+			e.opts.OnService(v)
+		}
+	case structs.CertRecord:
+		if e.opts.OnCert != nil {
+			e.opts.OnCert(v)
+		}
+	case structs.WebRecord:
+		if e.opts.OnWeb != nil {
+			e.opts.OnWeb(v)
+		}
+	case structs.FingerRecord:
+		if e.opts.OnFinger != nil {
+			e.opts.OnFinger(v)
+		}
+	case output.ResultEvent:
+		if e.opts.OnNuclei != nil {
+			e.opts.OnNuclei(v)
+		}
+	case structs.GoPocsResultType:
+		if e.opts.OnGoPoc != nil {
+			e.opts.OnGoPoc(v)
+		}
+	case structs.DiffRecord:
+		if e.opts.OnDiff != nil {
+			e.opts.OnDiff(v)
+		}
+	case structs.HostFlagRecord:
+		if e.opts.OnFlag != nil {
+			e.opts.OnFlag(v)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"dddd/common"
+	"dddd/common/http"
+	"dddd/structs"
+	"dddd/utils"
+	"github.com/projectdiscovery/httpx/runner"
+	"net"
+	"sync"
+)
+
+// pipelineBuffer 流水线各阶段之间通道的容量，下游处理不过来时上游阻塞等待
+const pipelineBuffer = 256
+
+// usePipeline 端口扫描、协议识别与Web探测都需要执行时使用流水线
+func usePipeline(scan *structs.Scan, st *structs.CheckpointState) bool {
+	return !scan.Config.NoPipeline &&
+		common.ShouldRunStage(scan, st, common.StagePortScan) &&
+		common.ShouldRunStage(scan, st, common.StageProtocol) &&
+		common.ShouldRunStage(scan, st, common.StageWeb)
+}
+
+// pipeline 各阶段通过有界通道连接，存活主机立即进行端口扫描，开放端口立即进行协议识别，识别出的Web服务立即进行Web探测
+// 全部结束后依次保存各阶段的断点，中途取消时只保留已完成的主机发现
+func pipeline(ctx context.Context, scan *structs.Scan, st *structs.CheckpointState) error {
+	runDiscovery := common.ShouldRunStage(scan, st, common.StageDiscovery)
+	if !runDiscovery && !common.StageFinished(st, common.StageDiscovery) {
+		domainURLs(scan, st)
+	}
+
+	// 主机发现，存活主机送入有界通道，端口扫描处理不过来时主机发现等待，ctx取消后丢弃
+	hosts := make(chan string, pipelineBuffer)
+	discovered := make(chan struct{})
+	go func() {
+		defer close(discovered)
+		defer close(hosts)
+		if runDiscovery {
+			discovery(scan, st, func(ip string) {
+				select {
+				case hosts <- ip:
+				case <-ctx.Done():
+				}
+			})
+			common.SaveCheckpoint(scan, st, common.StageDiscovery)
+			if !scan.Config.SkipHostDiscovery {
+				return
+			}
+		}
+		// 未进行存活探测的IP全部进行端口扫描
+		feedTargets(ctx, scan, st, hosts)
+	}()
+
+	// 端口扫描
+	ipPorts := make(chan string, pipelineBuffer)
+	var udpHosts []string
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		udpHosts = streamPortScan(scan, hosts, ipPorts)
+	}()
+
+	// 协议识别，已有的端口先于扫描结果识别
+	initial := append(append([]string{}, st.IPPort...), st.DomainPort...)
+	protocolInput := make(chan string, pipelineBuffer)
+	var newIPPort []string
+	go func() {
+		defer close(protocolInput)
+		for _, each := range initial {
+			protocolInput <- each
+		}
+		for each := range ipPorts {
+			newIPPort = append(newIPPort, each)
+			protocolInput <- each
+		}
+	}()
+	services := make(chan common.Service, pipelineBuffer)
+	go common.ScanProtocol(scan, protocolInput, scan.Config.GetBannerThreads, services)
+
+	// Web探测
+	var known []string
+	scan.IPPortMapLock.Lock()
+	for hostPort, service := range scan.IPPortMap {
+		if u := webURL(hostPort, service); u != "" {
+			known = append(known, u)
+		}
+	}
+	scan.IPPortMapLock.Unlock()
+
+	urls := make(chan string, pipelineBuffer)
+	add, onProgress, finish := http.TrackStreamProgress(scan, "Web探测")
+	var lock sync.Mutex
+	var submitted []string
+	seen := make(map[string]struct{})
+	submit := func(u string) {
+		lock.Lock()
+		_, ok := seen[u]
+		if !ok {
+			seen[u] = struct{}{}
+			submitted = append(submitted, u)
+		}
+		lock.Unlock()
+		if !ok {
+			add()
+			urls <- u
+		}
+	}
+
+	var feeders sync.WaitGroup
+	feeders.Add(2)
+	go func() {
+		defer feeders.Done()
+		for _, u := range known {
+			submit(u)
+		}
+		// 输入的URL与CDN域名在主机发现结束后确定
+		<-discovered
+		for _, u := range st.URLs {
+			submit(u)
+		}
+	}()
+	go func() {
+		defer feeders.Done()
+		for service := range services {
+			if u := webURL(service.HostPort, service.Protocol); u != "" {
+				submit(u)
+			}
+		}
+	}()
+	go func() {
+		feeders.Wait()
+		close(urls)
+	}()
+
+	scan.HTTPx.CallHTTPxStream(urls,
+		func(resp runner.Result) {
+			http.UrlCallBack(scan, resp)
+		},
+		onProgress,
+		scan.Config.HTTPProxy,
+		scan.Config.WebThreads,
+		scan.Config.WebTimeout)
+	finish()
+	<-scanned
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	for _, each := range newIPPort {
+		st.IPPort = append(st.IPPort, each)
+		if host, _, err := net.SplitHostPort(each); err == nil {
+			st.AliveHosts = append(st.AliveHosts, host)
+		}
+	}
+	st.IPPort = utils.RemoveDuplicateElement(st.IPPort)
+	st.AliveHosts = append(st.AliveHosts, udpHosts...)
+	st.AliveHosts = utils.RemoveDuplicateElement(st.AliveHosts)
+	common.SaveCheckpoint(scan, st, common.StagePortScan)
+	common.SaveCheckpoint(scan, st, common.StageProtocol)
+
+	st.URLs = utils.RemoveDuplicateElement(append(st.URLs, submitted...))
+	webFinish(ctx, scan, st)
+	common.SaveCheckpoint(scan, st, common.StageWeb)
+	return nil
+}
@@ -0,0 +1,721 @@
+package engine
+
+import (
+	"context"
+	"dddd/common"
+	"dddd/common/callnuclei"
+	"dddd/common/http"
+	"dddd/common/report"
+	"dddd/common/uncover"
+	"dddd/gopocs"
+	"dddd/lib/ddfinger"
+	"dddd/structs"
+	"dddd/utils"
+	"dddd/utils/cdn"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/httpx"
+	"github.com/projectdiscovery/httpx/runner"
+	"net"
+)
+
+func workflow(ctx context.Context, scan *structs.Scan, st *structs.CheckpointState) error {
+	// 导入之前的扫描结果
+	if scan.Config.ImportDir != "" {
+		if err := common.ImportCheckpoint(scan, st, scan.Config.ImportDir); err != nil {
+			return err
+		}
+	}
+	if scan.Config.ImportService != "" {
+		if err := common.ImportServices(scan, st, scan.Config.ImportService); err != nil {
+			return err
+		}
+	}
+	if scan.Config.ImportScan != "" {
+		if err := common.ImportScanResults(scan, st, scan.Config.ImportScan); err != nil {
+			return err
+		}
+	}
+
+	if !common.StageFinished(st, common.StageInput) {
+		if err := parseInput(scan, st); err != nil {
+			return err
+		}
+		common.SaveCheckpoint(scan, st, common.StageInput)
+	}
+
+	if usePipeline(scan, st) {
+		// 主机发现、端口扫描、协议识别与Web探测同时进行
+		if err := pipeline(ctx, scan, st); err != nil {
+			return err
+		}
+	} else if err := serialScan(ctx, scan, st); err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// 模糊搜索Yaml Poc直接打
+	if scan.Config.PocNameForSearch != "" {
+		TargetAndPocsName := make(map[string][]string)
+		for _, url := range st.AliveURLs {
+			if scan.HostFlagged(utils.TargetHost(url)) {
+				continue
+			}
+			TargetAndPocsName[url] = []string{}
+		}
+		report.GenerateHTMLReportHeader(scan)
+		_, err := callnuclei.CallNuclei(scan, TargetAndPocsName,
+			scan.Config.HTTPProxy,
+			scan.Config.PocNameForSearch)
+		if err != nil {
+			return err
+		}
+		utils.DeleteReportWithNoResult(scan.Config.ReportName)
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// 目录爆破
+	if !scan.Config.NoDirSearch && common.ShouldRunStage(scan, st, common.StageDirBrute) {
+		var checkURLs []string
+		for path, _ := range structs.DirDB {
+			for _, u := range st.AliveURLs {
+				Url := ""
+				if u[len(u)-1:] == "/" && path[0:1] == "/" {
+					Url = u[:len(u)-1] + path
+				} else {
+					Url = u + path
+				}
+				checkURLs = append(checkURLs, Url)
+			}
+		}
+		checkURLs = utils.RemoveDuplicateElement(checkURLs)
+		gologger.Info().Msg("开始主动指纹探测")
+		onProgress, finish := http.TrackProgress(scan, "主动指纹", len(checkURLs))
+		httpx.DirBrute(checkURLs,
+			func(resp runner.Result) {
+				http.DirBruteCallBack(scan, resp)
+			},
+			onProgress,
+			scan.Config.HTTPProxy,
+			scan.Config.WebThreads,
+			scan.Config.WebTimeout)
+		finish()
+		common.SaveCheckpoint(scan, st, common.StageDirBrute)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if common.ShouldRunStage(scan, st, common.StageFinger) {
+		ddfinger.FingerprintIdentification(scan)
+		if !scan.Config.NoHoneypotCheck {
+			common.HoneypotCheck(scan)
+		}
+		common.SaveCheckpoint(scan, st, common.StageFinger)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if scan.Config.NoPoc {
+		gologger.Info().Msg("跳过漏洞探测")
+		return nil
+	}
+
+	// 生成报告头部
+	if common.ShouldRunStage(scan, st, common.StageNuclei) || common.ShouldRunStage(scan, st, common.StageGoPoc) {
+		report.GenerateHTMLReportHeader(scan)
+	}
+
+	// 调用Nuclei
+	if common.ShouldRunStage(scan, st, common.StageNuclei) {
+		TargetAndPocsName, count := http.GetPocs(scan, structs.WorkFlowDB)
+		if count > 0 {
+			results, err := callnuclei.CallNuclei(scan, TargetAndPocsName,
+				scan.Config.HTTPProxy,
+				"")
+			if err != nil {
+				return err
+			}
+			st.NucleiResults = results
+		}
+		common.SaveCheckpoint(scan, st, common.StageNuclei)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// GoPoc引擎
+	if !scan.Config.NoGolangPoc && common.ShouldRunStage(scan, st, common.StageGoPoc) {
+		gopocs.GoPocsDispatcher(scan, st.NucleiResults)
+		common.SaveCheckpoint(scan, st, common.StageGoPoc)
+	}
+
+	// 没有漏洞结果，删除生成的HTML
+	utils.DeleteReportWithNoResult(scan.Config.ReportName)
+	return nil
+}
+
+// serialScan 依次执行主机发现、端口扫描、协议识别与Web探测，每个阶段等待上一阶段全部结束
+func serialScan(ctx context.Context, scan *structs.Scan, st *structs.CheckpointState) error {
+	if common.ShouldRunStage(scan, st, common.StageDiscovery) {
+		discovery(scan, st, nil)
+		common.SaveCheckpoint(scan, st, common.StageDiscovery)
+	} else if !common.StageFinished(st, common.StageDiscovery) {
+		domainURLs(scan, st)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// 端口扫描
+	if common.ShouldRunStage(scan, st, common.StagePortScan) {
+		portScan(ctx, scan, st)
+		common.SaveCheckpoint(scan, st, common.StagePortScan)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if common.ShouldRunStage(scan, st, common.StageProtocol) {
+		getProtocalInput := st.IPPort
+		for _, each := range st.DomainPort {
+			getProtocalInput = append(getProtocalInput, each)
+		}
+		if len(getProtocalInput) > 0 {
+			common.GetProtocol(scan, getProtocalInput, scan.Config.GetBannerThreads)
+		}
+		common.SaveCheckpoint(scan, st, common.StageProtocol)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// 获取http响应
+	if common.ShouldRunStage(scan, st, common.StageWeb) {
+		for hostPort, service := range scan.IPPortMap {
+			if u := webURL(hostPort, service); u != "" {
+				st.URLs = append(st.URLs, u)
+			}
+		}
+		st.URLs = utils.RemoveDuplicateElement(st.URLs)
+
+		onProgress, finish := http.TrackProgress(scan, "Web探测", len(st.URLs))
+		scan.HTTPx.CallHTTPx(st.URLs,
+			func(resp runner.Result) {
+				http.UrlCallBack(scan, resp)
+			},
+			onProgress,
+			scan.Config.HTTPProxy,
+			scan.Config.WebThreads,
+			scan.Config.WebTimeout)
+		finish()
+
+		webFinish(ctx, scan, st)
+		common.SaveCheckpoint(scan, st, common.StageWeb)
+	}
+	return nil
+}
+
+// domainURLs 跳过CDN识别时域名直接作为Web目标
+func domainURLs(scan *structs.Scan, st *structs.CheckpointState) {
+	for _, domain := range st.Domains {
+		st.URLs = append(st.URLs, "http://"+domain)
+		st.URLs = append(st.URLs, "https://"+domain)
+	}
+	st.URLs = utils.RemoveDuplicateElement(st.URLs)
+}
+
+// webURL 返回TCP上http/https服务的URL，其他服务返回空
+func webURL(hostPort string, service string) string {
+	if network, _ := structs.SplitServiceKey(hostPort); network != "tcp" {
+		return ""
+	}
+	if service == "http" || service == "https" {
+		return service + "://" + hostPort
+	}
+	return ""
+}
+
+// webFinish Web探测结束后检测域名绑定资产，记录存活的Web
+func webFinish(ctx context.Context, scan *structs.Scan, st *structs.CheckpointState) {
+	// 非CDN域名 探测域名绑定资产
+	// 把只允许域名访问的资产扒拉出来
+	certIPs := common.HostBindCheck(scan)
+	if ctx.Err() == nil {
+		scanCertIPs(ctx, scan, st, certIPs)
+	}
+
+	st.AliveURLs = []string{}
+	for rootURL, _ := range scan.URLMap {
+		st.AliveURLs = append(st.AliveURLs, rootURL)
+	}
+}
+
+// scanCertIPs 证书根域名解析出的IP中未扫描过的进行端口扫描、协议识别与Web探测，只进行一轮
+func scanCertIPs(ctx context.Context, scan *structs.Scan, st *structs.CheckpointState, ips []string) {
+	scanned := make(map[string]struct{})
+	for _, each := range append(append([]string{}, st.IPs...), st.AliveHosts...) {
+		scanned[each] = struct{}{}
+	}
+	scan.IPPortMapLock.Lock()
+	for key := range scan.IPPortMap {
+		_, hostPort := structs.SplitServiceKey(key)
+		if host, _, err := net.SplitHostPort(hostPort); err == nil {
+			scanned[host] = struct{}{}
+		}
+	}
+	scan.IPPortMapLock.Unlock()
+
+	sub := &structs.CheckpointState{}
+	for _, ip := range ips {
+		if _, ok := scanned[ip]; !ok {
+			sub.IPs = append(sub.IPs, ip)
+		}
+	}
+	if len(sub.IPs) == 0 {
+		return
+	}
+	gologger.Info().Msgf("证书根域名解析出 %d 个新IP，进行端口扫描", len(sub.IPs))
+	portScan(ctx, scan, sub)
+	st.IPs = utils.RemoveDuplicateElement(append(st.IPs, sub.IPs...))
+	st.IPPort = utils.RemoveDuplicateElement(append(st.IPPort, sub.IPPort...))
+	st.AliveHosts = utils.RemoveDuplicateElement(append(st.AliveHosts, sub.AliveHosts...))
+	if len(sub.IPPort) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	common.GetProtocol(scan, sub.IPPort, scan.Config.GetBannerThreads)
+	var urls []string
+	scan.IPPortMapLock.Lock()
+	for _, hostPort := range sub.IPPort {
+		if u := webURL(hostPort, scan.IPPortMap[hostPort]); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	scan.IPPortMapLock.Unlock()
+	if len(urls) == 0 || ctx.Err() != nil {
+		return
+	}
+	st.URLs = utils.RemoveDuplicateElement(append(st.URLs, urls...))
+	onProgress, finish := http.TrackProgress(scan, "证书IP Web探测", len(urls))
+	scan.HTTPx.CallHTTPx(urls,
+		func(resp runner.Result) {
+			http.UrlCallBack(scan, resp)
+		},
+		onProgress,
+		scan.Config.HTTPProxy,
+		scan.Config.WebThreads,
+		scan.Config.WebTimeout)
+	finish()
+}
+
+// parseInput 从网络空间搜索引擎获取目标并按输入类型分类
+func parseInput(scan *structs.Scan, st *structs.CheckpointState) error {
+	if err := searchEngine(scan); err != nil {
+		return err
+	}
+
+	for _, input := range scan.Config.Targets {
+		inputType := utils.GetInputType(input)
+		if inputType != structs.TypeCIDR && inputType != structs.TypeIPRange && scan.IsExcluded(input) {
+			continue
+		}
+		if inputType == structs.TypeDomain {
+			st.Domains = append(st.Domains, input)
+			continue
+		} else if inputType == structs.TypeDomainPort {
+			st.DomainPort = append(st.DomainPort, input)
+			continue
+		} else if inputType == structs.TypeCIDR || inputType == structs.TypeIPRange {
+			// 网段不展开，扫描时按需生成地址
+			if _, err := utils.NewIPIterator([]string{input}, false); err != nil {
+				gologger.Error().Msg(err.Error())
+				continue
+			}
+			st.IPRanges = append(st.IPRanges, input)
+		} else if inputType == structs.TypeIP {
+			// 统一IPv6的书写形式
+			st.IPs = append(st.IPs, net.ParseIP(input).String())
+		} else if inputType == structs.TypeIPPort {
+			host, port, _ := net.SplitHostPort(input)
+			st.IPPort = append(st.IPPort, net.JoinHostPort(net.ParseIP(host).String(), port))
+		} else if inputType == structs.TypeURL {
+			st.URLs = append(st.URLs, input)
+		}
+	}
+	return nil
+}
+
+// discovery 进行子域名枚举、CDN识别与存活探测，onAlive不为空时每确认一个待扫描的IP立即调用
+func discovery(scan *structs.Scan, st *structs.CheckpointState, onAlive func(ip string)) {
+	if scan.Config.Subdomain && len(st.Domains) > 0 {
+		subdomains := common.GetSubDomain(scan, st.Domains)
+		for _, each := range subdomains {
+			st.Domains = append(st.Domains, each)
+		}
+	}
+	st.Domains = utils.RemoveDuplicateElement(st.Domains)
+
+	var cdnDomains []string
+	var tIPs []string
+	if len(st.Domains) > 0 {
+		cdnDomains, _, tIPs = cdn.CheckCDNs(scan, st.Domains, scan.Config.SubdomainBruteForceThreads)
+		for _, each := range tIPs {
+			if scan.Config.AllowLocalAreaDomain && utils.IsLocalIP(each) {
+				continue
+			}
+			if scan.IsExcluded(each) {
+				continue
+			}
+			st.IPs = append(st.IPs, each)
+		}
+	}
+	st.IPs = utils.RemoveDuplicateElement(st.IPs)
+
+	// 处理带CDN的域名，只进行https,http的探测，不进行端口扫描
+	for _, cd := range cdnDomains {
+		st.URLs = append(st.URLs, "http://"+cd)
+		st.URLs = append(st.URLs, "https://"+cd)
+	}
+	st.URLs = utils.RemoveDuplicateElement(st.URLs)
+
+	if (len(st.IPs) > 0 || len(st.IPRanges) > 0) && !scan.Config.SkipHostDiscovery {
+		// 域名解析得到的IP不进行存活过滤
+		resolved := make(map[string]struct{}, len(tIPs))
+		for _, each := range tIPs {
+			resolved[each] = struct{}{}
+		}
+		probeInputs := append([]string{}, st.IPRanges...)
+		var ips []string
+		for _, each := range st.IPs {
+			if _, ok := resolved[each]; ok {
+				ips = append(ips, each)
+			} else {
+				probeInputs = append(probeInputs, each)
+			}
+		}
+
+		opts := common.DefaultDiscoveryOptions(scan)
+		if onAlive != nil {
+			for _, each := range ips {
+				onAlive(each)
+			}
+			opts.OnAlive = func(host common.AliveHost) {
+				onAlive(host.IP)
+			}
+		}
+
+		// 大网段分批探测，每批结束后释放
+		probed, aliveCount := 0, 0
+		batch := make([]string, 0, discoveryBatch)
+		probe := func() {
+			alive := common.DiscoverHosts(batch, opts)
+			for _, host := range alive {
+				ips = append(ips, host.IP)
+				st.AliveHosts = append(st.AliveHosts, host.IP)
+			}
+			probed += len(batch)
+			aliveCount += len(alive)
+			batch = batch[:0]
+		}
+		eachTarget(scan, newIPIterator(scan, probeInputs), func(ip string) bool {
+			batch = append(batch, ip)
+			if len(batch) >= discoveryBatch {
+				probe()
+			}
+			return true
+		})
+		if len(batch) > 0 {
+			probe()
+		}
+		gologger.Info().Msgf("主机发现完成，存活主机 %d/%d", aliveCount, probed)
+		// 只对存活主机进行端口扫描
+		st.IPs = utils.RemoveDuplicateElement(ips)
+		st.IPRanges = nil
+		st.AliveHosts = utils.RemoveDuplicateElement(st.AliveHosts)
+	}
+}
+
+// discoveryBatch 每批进行主机发现的IP数量
+const discoveryBatch = 65536
+
+// hostBatch SYN扫描与UDP扫描每批的主机数量
+const hostBatch = 65536
+
+// newIPIterator 遍历IP与网段，除非指定-nshuffle，否则以随机顺序遍历
+func newIPIterator(scan *structs.Scan, inputs []string) *utils.IPIterator {
+	it, err := utils.NewIPIterator(inputs, !scan.Config.NoShuffle)
+	if err != nil {
+		// 网段在解析输入时已校验，断点文件被修改时才会出错
+		gologger.Error().Msg(err.Error())
+		it, _ = utils.NewIPIterator(nil, false)
+	}
+	return it
+}
+
+// eachTarget 依次处理未被排除的IP，fn返回false时停止
+func eachTarget(scan *structs.Scan, it *utils.IPIterator, fn func(ip string) bool) {
+	for ip, ok := it.Next(); ok; ip, ok = it.Next() {
+		if scan.IsExcluded(ip) {
+			continue
+		}
+		if !fn(ip) {
+			return
+		}
+	}
+}
+
+// feedTargets 将待扫描的IP与网段中的地址写入out，ctx取消后停止
+func feedTargets(ctx context.Context, scan *structs.Scan, st *structs.CheckpointState, out chan<- string) {
+	eachTarget(scan, newIPIterator(scan, append(append([]string{}, st.IPs...), st.IPRanges...)), func(ip string) bool {
+		select {
+		case out <- ip:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// portScanTCP TCP全连接扫描
+func portScanTCP(scan *structs.Scan, IPs []string) []string {
+	return common.PortScanTCP(scan, IPs, scan.Config.Ports,
+		scan.Config.TCPPortScanTimeout)
+}
+
+// portScan 对存活IP进行端口扫描
+func portScan(ctx context.Context, scan *structs.Scan, st *structs.CheckpointState) {
+	if len(st.IPs) == 0 && len(st.IPRanges) == 0 {
+		return
+	}
+
+	hosts := make(chan string, pipelineBuffer)
+	go func() {
+		defer close(hosts)
+		feedTargets(ctx, scan, st, hosts)
+	}()
+	found := make(chan string, pipelineBuffer)
+	var udpHosts []string
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		udpHosts = streamPortScan(scan, hosts, found)
+	}()
+
+	for each := range found {
+		st.IPPort = append(st.IPPort, each)
+		if host, _, err := net.SplitHostPort(each); err == nil {
+			st.AliveHosts = append(st.AliveHosts, host)
+		}
+	}
+	<-scanned
+	st.IPPort = utils.RemoveDuplicateElement(st.IPPort)
+
+	st.AliveHosts = append(st.AliveHosts, udpHosts...)
+	st.AliveHosts = utils.RemoveDuplicateElement(st.AliveHosts)
+}
+
+// streamPortScan 对hosts中的主机进行端口扫描，开放端口写入out，TCP扫描结束后关闭out
+// SYN与masscan扫描按批进行，UDP扫描与TCP扫描同时按批进行。返回有UDP服务的主机
+func streamPortScan(scan *structs.Scan, hosts <-chan string, out chan<- string) []string {
+	var udpHosts []string
+	var udpInput chan string
+	udpDone := make(chan struct{})
+	if scan.Config.UDPScan {
+		udpInput = make(chan string, pipelineBuffer)
+		go func() {
+			defer close(udpDone)
+			udpHosts = batchUDPScan(scan, udpInput)
+		}()
+	} else {
+		close(udpDone)
+	}
+	tee := func(host string) {
+		if udpInput != nil {
+			udpInput <- host
+		}
+	}
+
+	switch scan.Config.PortScanType {
+	case "syn", "masscan":
+		batch := make([]string, 0, hostBatch)
+		flush := func() {
+			ips := batch
+			if !scan.Config.NoTarpitCheck {
+				ips = common.TarpitCheck(scan, ips, scan.Config.TCPPortScanTimeout)
+			}
+			for _, each := range scanTCPPorts(scan, ips) {
+				out <- each
+			}
+			batch = batch[:0]
+		}
+		for host := range hosts {
+			tee(host)
+			batch = append(batch, host)
+			if len(batch) >= hostBatch {
+				flush()
+			}
+		}
+		if len(batch) > 0 {
+			flush()
+		}
+	default:
+		input := make(chan string)
+		go func() {
+			defer close(input)
+			for host := range hosts {
+				tee(host)
+				input <- host
+			}
+		}()
+
+		// 随机高端口全部开放的主机不进行完整扫描
+		var skip func(host string) bool
+		if !scan.Config.NoTarpitCheck {
+			skip = func(host string) bool {
+				return common.IsTarpit(scan, host, scan.Config.TCPPortScanTimeout)
+			}
+		}
+		found := make(chan string, pipelineBuffer)
+		go common.PortScanTCPStream(scan, input, scan.Config.Ports,
+			scan.Config.TCPPortScanTimeout, skip, found)
+		for each := range found {
+			if !scan.IsExcluded(each) {
+				out <- each
+			}
+		}
+	}
+	close(out)
+
+	if udpInput != nil {
+		close(udpInput)
+	}
+	<-udpDone
+	return udpHosts
+}
+
+// batchUDPScan 按批对hosts进行UDP扫描，跳过已标记的主机
+func batchUDPScan(scan *structs.Scan, hosts <-chan string) []string {
+	var results []string
+	batch := make([]string, 0, hostBatch)
+	flush := func() {
+		var ips []string
+		for _, ip := range batch {
+			if !scan.HostFlagged(ip) {
+				ips = append(ips, ip)
+			}
+		}
+		results = append(results, scanUDPPorts(scan, ips)...)
+		batch = batch[:0]
+	}
+	for host := range hosts {
+		batch = append(batch, host)
+		if len(batch) >= hostBatch {
+			flush()
+		}
+	}
+	if len(batch) > 0 {
+		flush()
+	}
+	return results
+}
+
+// scanTCPPorts 按扫描方式进行TCP端口扫描，返回过滤后的开放端口
+func scanTCPPorts(scan *structs.Scan, ips []string) []string {
+	var tmpIPPort []string
+	switch scan.Config.PortScanType {
+	case "syn":
+		var err error
+		tmpIPPort, err = common.PortScanSYN(scan, ips, scan.Config.Ports)
+		if err != nil {
+			gologger.Error().Msgf("原生SYN扫描不可用: %v", err)
+			if common.CheckMasScan(scan) {
+				gologger.Info().Msg("使用masscan进行SYN扫描")
+				tmpIPPort = common.PortScanMasscan(scan, ips, scan.Config.Ports)
+			} else {
+				gologger.Error().Msg("降级TCP扫描")
+				tmpIPPort = portScanTCP(scan, ips)
+			}
+		}
+	case "masscan":
+		if common.CheckMasScan(scan) {
+			tmpIPPort = common.PortScanMasscan(scan, ips, scan.Config.Ports)
+		} else {
+			gologger.Error().Msg("降级TCP扫描")
+			tmpIPPort = portScanTCP(scan, ips)
+		}
+	default:
+		tmpIPPort = portScanTCP(scan, ips)
+	}
+
+	// 单个IP阈值过滤
+	tmpIPPort = common.RemoveFirewall(scan, tmpIPPort)
+	return scan.FilterExcluded(tmpIPPort)
+}
+
+// scanUDPPorts UDP扫描同时完成服务识别，结果直接写入Scan.IPPortMap，返回有UDP服务的主机
+func scanUDPPorts(scan *structs.Scan, ips []string) []string {
+	if !scan.Config.UDPScan {
+		return nil
+	}
+	var hosts []string
+	for _, key := range common.PortScanUDP(scan, ips, scan.Config.UDPPorts,
+		scan.Config.UDPScanThreads) {
+		_, hostPort := structs.SplitServiceKey(key)
+		if host, _, err := net.SplitHostPort(hostPort); err == nil {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+func searchEngine(scan *structs.Scan) error {
+	var err error
+	// 从Hunter中获取资产
+	if scan.Config.Hunter && !scan.Config.Fofa {
+		scan.Config.Targets, _, err = uncover.HunterSearch(scan, scan.Config.Targets)
+		return err
+	}
+	// 从Fofa中获取资产
+	if scan.Config.Fofa && !scan.Config.Hunter {
+		scan.Config.Targets, err = uncover.FOFASearch(scan, scan.Config.Targets)
+		return err
+	}
+	// 从Hunter中获取资产后使用Fofa进行端口补充。
+	if scan.Config.Fofa && scan.Config.Hunter {
+		targets, tIPs, err := uncover.HunterSearch(scan, scan.Config.Targets)
+		if err != nil {
+			return err
+		}
+		var querys []string
+		for _, i := range tIPs {
+			querys = append(querys, "ip=\""+i+"\"")
+		}
+		querys = utils.RemoveDuplicateElement(querys)
+		scan.Config.Targets, err = uncover.FOFASearch(scan, querys)
+		if err != nil {
+			return err
+		}
+		scan.Config.Targets = append(scan.Config.Targets, targets...)
+		scan.Config.Targets = utils.RemoveDuplicateElement(scan.Config.Targets)
+		return nil
+	}
+	// 从Quake获取资产
+	if scan.Config.Quake {
+		scan.Config.Targets, err = uncover.QuakeSearch(scan, scan.Config.Targets)
+	}
+	return err
+}
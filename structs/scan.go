@@ -0,0 +1,163 @@
+package structs
+
+import (
+	"dddd/common/progress"
+	"github.com/projectdiscovery/hmap/store/hybrid"
+	"github.com/projectdiscovery/httpx"
+	"os"
+	"sync"
+)
+
+// Excluder 不允许触碰的目标范围，由utils.NewExcludeScope创建
+type Excluder interface {
+	// IsExcluded 判断IP、域名、host:port或URL是否在排除列表中
+	IsExcluded(target string) bool
+	// Networks 排除列表中的IP、CIDR与IP段，用于masscan的--exclude参数
+	Networks() []string
+}
+
+// ReportState 一次扫描的JSONL文件、HTML报告序号与结果回调，由report包维护
+type ReportState struct {
+	Lock        sync.Mutex
+	JSONL       *os.File
+	JSONLFailed bool // JSONL文件打开失败，之后的记录不再写入
+	Index       int
+	Hook        func(recordType string, data interface{}) // 结果回调，供嵌入dddd的调用方实时获取结果
+}
+
+// Scan 一次扫描的参数与资产状态，由engine在每次Run时创建并传给各阶段，同一进程内的多次扫描互不影响
+type Scan struct {
+	Config Config
+
+	// IPPortMap IP:Port : Protocol，UDP服务的键为 udp/IP:Port
+	IPPortMap map[string]string
+	// ServiceMap 与IPPortMap使用相同的键和锁，只记录识别出产品或版本等详情的服务
+	ServiceMap map[string]ServiceFingerprint
+	// CertMap IP:Port : 证书，与IPPortMap使用相同的锁
+	CertMap       map[string]CertInfo
+	IPPortMapLock sync.Mutex
+
+	// IPDomainMap 存储ip->domains的关系
+	IPDomainMap     map[string][]string
+	IPDomainMapLock sync.Mutex
+
+	// URLMap RootURL:URLEntity
+	URLMap     map[string]URLEntity
+	URLMapLock sync.Mutex
+
+	// ResultMap 存储识别到的指纹
+	ResultMap     map[string][]string
+	ResultMapLock sync.Mutex
+
+	// FlaggedHosts 被判定为tarpit或蜜罐的主机 Host : 原因，不进行漏洞探测
+	FlaggedHosts     map[string]string
+	FlaggedHostsLock sync.Mutex
+
+	// 响应体、响应头与Banner缓存数据库，保存在断点目录的cache下
+	HttpBodyHMap   *hybrid.HybridMap
+	HttpHeaderHMap *hybrid.HybridMap
+	BannerHMap     *hybrid.HybridMap
+	// CheckpointTemp 断点目录由本次扫描自动创建，扫描成功结束后删除
+	CheckpointTemp bool
+
+	// GoPocsResults 存储Go Poc的输出，AddScanNum与AddScanEnd为已提交与已结束的Go Poc任务数
+	GoPocsResults []GoPocsResultType
+	AddScanNum    int
+	AddScanEnd    int
+	GoPocsLock    sync.Mutex
+
+	Exclude  Excluder // 为nil时不排除
+	Report   ReportState
+	Progress *progress.Tracker
+	HTTPx    *httpx.Session // 记录已探测的URL，页面跳转得到的URL不重复探测
+}
+
+// NewScan 创建空的资产状态，缓存数据库、排除列表与进度由调用方设置
+func NewScan(config Config) *Scan {
+	return &Scan{
+		Config:       config,
+		IPPortMap:    make(map[string]string),
+		ServiceMap:   make(map[string]ServiceFingerprint),
+		CertMap:      make(map[string]CertInfo),
+		IPDomainMap:  make(map[string][]string),
+		URLMap:       make(map[string]URLEntity),
+		ResultMap:    make(map[string][]string),
+		FlaggedHosts: make(map[string]string),
+		Report:       ReportState{Index: 1},
+		Progress:     progress.New(),
+		HTTPx:        httpx.NewSession(),
+	}
+}
+
+// SetServiceFingerprint 记录服务详情，没有任何详情时不记录
+func (s *Scan) SetServiceFingerprint(key string, fp ServiceFingerprint) {
+	if fp.String() == "" && fp.Hostname == "" && fp.OperatingSystem == "" && fp.DeviceType == "" && len(fp.CPE) == 0 {
+		return
+	}
+	s.IPPortMapLock.Lock()
+	defer s.IPPortMapLock.Unlock()
+	s.ServiceMap[key] = fp
+}
+
+// GetServiceFingerprint 返回服务详情
+func (s *Scan) GetServiceFingerprint(key string) ServiceFingerprint {
+	s.IPPortMapLock.Lock()
+	defer s.IPPortMapLock.Unlock()
+	return s.ServiceMap[key]
+}
+
+// SetCertificate 记录证书，已记录时返回false
+func (s *Scan) SetCertificate(key string, cert CertInfo) bool {
+	s.IPPortMapLock.Lock()
+	defer s.IPPortMapLock.Unlock()
+	if _, ok := s.CertMap[key]; ok {
+		return false
+	}
+	s.CertMap[key] = cert
+	return true
+}
+
+// GetCertificate 返回证书，没有时返回零值
+func (s *Scan) GetCertificate(key string) CertInfo {
+	s.IPPortMapLock.Lock()
+	defer s.IPPortMapLock.Unlock()
+	return s.CertMap[key]
+}
+
+// FlagHost 标记主机，已标记的主机保留最初的原因
+func (s *Scan) FlagHost(host string, reason string) bool {
+	s.FlaggedHostsLock.Lock()
+	defer s.FlaggedHostsLock.Unlock()
+	if _, ok := s.FlaggedHosts[host]; ok {
+		return false
+	}
+	s.FlaggedHosts[host] = reason
+	return true
+}
+
+// HostFlagged 主机是否被标记为tarpit或蜜罐
+func (s *Scan) HostFlagged(host string) bool {
+	s.FlaggedHostsLock.Lock()
+	defer s.FlaggedHostsLock.Unlock()
+	_, ok := s.FlaggedHosts[host]
+	return ok
+}
+
+// IsExcluded 判断IP、域名、host:port或URL是否在排除列表中
+func (s *Scan) IsExcluded(target string) bool {
+	return s.Exclude != nil && s.Exclude.IsExcluded(target)
+}
+
+// FilterExcluded 移除排除列表中的目标
+func (s *Scan) FilterExcluded(targets []string) []string {
+	if s.Exclude == nil {
+		return targets
+	}
+	var results []string
+	for _, target := range targets {
+		if !s.Exclude.IsExcluded(target) {
+			results = append(results, target)
+		}
+	}
+	return results
+}
@@ -2,9 +2,10 @@ package structs
 
 import (
 	"github.com/lcvvvv/gonmap"
-	"github.com/projectdiscovery/hmap/store/hybrid"
+	"github.com/projectdiscovery/nuclei/v3/pkg/output"
 	"net"
-	"sync"
+	"strings"
+	"time"
 )
 
 const (
@@ -30,11 +31,22 @@ type Config struct {
 	GetBannerThreads           int
 	TCPPortScanThreads         int
 	SYNPortScanThreads         int
+	SYNRetries                 int
 	PortsThreshold             int
 	TCPPortScanTimeout         int
+	TCPPortScanRetries         int
+	NoTarpitCheck              bool
+	NoHoneypotCheck            bool
+	HoneypotFingerThreshold    int
 	MasscanPath                string
 	AllowLocalAreaDomain       bool
 	HTTPProxy                  string
+	TCPProxy                   string
+	SSHJump                    string // user@host[:port]
+	SSHJumpPassword            string
+	SSHJumpKey                 string
+	SSHJumpConns               int
+	SSHJumpFingerprint         string // 跳板主机密钥的SHA256指纹，指定时不使用known_hosts
 	Hunter                     bool
 	HunterPageSize             int
 	HunterMaxPageCount         int
@@ -52,7 +64,35 @@ type Config struct {
 	Quake                      bool
 	QuakeSize                  int
 	NoICMPPing                 bool
+	ICMPTimestamp              bool
 	TCPPing                    bool
+	TCPACKPing                 bool
+	TCPPingPorts               string
+	UDPPing                    bool
+	UDPPingPorts               string
+	NoARPPing                  bool
+	NoPipeline                 bool
+	NoShuffle                  bool
+	ResumeDir                  string
+	JSONLOutput                string
+	Stages                     []string
+	ImportDir                  string
+	ImportService              string
+	ImportScan                 string
+	UDPScan                    bool
+	UDPPorts                   string
+	UDPScanThreads             int
+	Exclude                    []string
+	CertRootDomains            []string // 证书中属于这些根域名的域名重新进行CDN识别
+	RateLimit                  int
+	HostRateLimit              int
+	HostConcurrency            int
+	ConfigDir                  string
+	Project                    string
+	ProjectDB                  string
+	Diff                       bool
+	ProgressInterval           int
+	StatusAddr                 string
 }
 
 type CDNResult struct {
@@ -62,13 +102,104 @@ type CDNResult struct {
 	IPs     []net.IP
 }
 
-var GlobalConfig Config
+// ServiceFingerprint 协议识别得到的服务详情，来自nmap-service-probes匹配规则中的 p/ v/ i/ h/ o/ d/ cpe:/
+type ServiceFingerprint struct {
+	ProductName     string   `json:"product,omitempty"`
+	Version         string   `json:"version,omitempty"`
+	Info            string   `json:"info,omitempty"`
+	Hostname        string   `json:"hostname,omitempty"`
+	OperatingSystem string   `json:"os,omitempty"`
+	DeviceType      string   `json:"device_type,omitempty"`
+	CPE             []string `json:"cpe,omitempty"`
+}
+
+// String 产品、版本与附加信息，如 OpenSSH 8.2p1 (Ubuntu Linux; protocol 2.0)
+func (f ServiceFingerprint) String() string {
+	s := strings.TrimSpace(f.ProductName + " " + f.Version)
+	if f.Info != "" {
+		s = strings.TrimSpace(s + " (" + f.Info + ")")
+	}
+	return s
+}
+
+// CertInfo TLS证书，Web与其他TLS服务(LDAPS、SMTPS、RDP、MSSQL等)均会记录
+type CertInfo struct {
+	SubjectCN   string    `json:"subject_cn,omitempty"`
+	SubjectDN   string    `json:"subject_dn,omitempty"`
+	SubjectOrg  []string  `json:"subject_org,omitempty"`
+	DNSNames    []string  `json:"dns_names,omitempty"`
+	IPAddresses []string  `json:"ip_addresses,omitempty"`
+	Emails      []string  `json:"emails,omitempty"`
+	IssuerCN    string    `json:"issuer_cn,omitempty"`
+	IssuerDN    string    `json:"issuer_dn,omitempty"`
+	IssuerOrg   []string  `json:"issuer_org,omitempty"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Serial      string    `json:"serial"`
+	SHA256      string    `json:"sha256"`
+	KeyType     string    `json:"key_type"` // 如 RSA-2048、ECDSA-P256、Ed25519
+	SelfSigned  bool      `json:"self_signed"`
+	TLSVersion  string    `json:"tls_version,omitempty"`
+}
+
+// String 供cert=规则匹配的文本，每行一个字段
+func (c CertInfo) String() string {
+	if c.SHA256 == "" {
+		return ""
+	}
+	list := func(name string, values []string) string {
+		s := name + ": \n"
+		for _, v := range values {
+			s += "    - " + v + "\n"
+		}
+		return s
+	}
+	result := "SubjectCN: " + c.SubjectCN + "\n"
+	result += "SubjectDN: " + c.SubjectDN + "\n"
+	result += list("SubjectOrg", c.SubjectOrg)
+	result += list("SubjectAN", append(append(append([]string{}, c.DNSNames...), c.IPAddresses...), c.Emails...))
+	result += "IssuerCN: " + c.IssuerCN + "\n"
+	result += "IssuerDN: " + c.IssuerDN + "\n"
+	result += list("IssuerOrg", c.IssuerOrg)
+	result += "NotBefore: " + c.NotBefore.Format(time.DateTime) + "\n"
+	result += "NotAfter: " + c.NotAfter.Format(time.DateTime) + "\n"
+	result += "Serial: " + c.Serial + "\n"
+	result += "SHA256: " + c.SHA256 + "\n"
+	result += "KeyType: " + c.KeyType + "\n"
+	return result
+}
+
+// Summary 控制台输出的证书摘要，如 CN=dc01.corp.local, SAN=dc01.corp.local,corp.local, 2024-01-01~2025-01-01, RSA-2048
+func (c CertInfo) Summary() string {
+	s := "CN=" + c.SubjectCN
+	if names := append(append([]string{}, c.DNSNames...), c.IPAddresses...); len(names) > 0 {
+		s += ", SAN=" + strings.Join(names, ",")
+	}
+	if len(c.IssuerOrg) > 0 {
+		s += ", " + strings.Join(c.IssuerOrg, ",")
+	}
+	s += ", " + c.NotBefore.Format(time.DateOnly) + "~" + c.NotAfter.Format(time.DateOnly)
+	if c.KeyType != "" {
+		s += ", " + c.KeyType
+	}
+	return s
+}
+
+// UDPServicePrefix Scan.IPPortMap中UDP服务键的前缀
+const UDPServicePrefix = "udp/"
 
-var GlobalBannerHMap *hybrid.HybridMap
+// UDPServiceKey 返回UDP服务在Scan.IPPortMap中的键
+func UDPServiceKey(hostPort string) string {
+	return UDPServicePrefix + hostPort
+}
 
-// GlobalIPPortMap IP:Port : Protocol
-var GlobalIPPortMap map[string]string
-var GlobalIPPortMapLock sync.Mutex
+// SplitServiceKey 拆分Scan.IPPortMap的键，返回tcp或udp与IP:Port
+func SplitServiceKey(key string) (network string, hostPort string) {
+	if strings.HasPrefix(key, UDPServicePrefix) {
+		return "udp", strings.TrimPrefix(key, UDPServicePrefix)
+	}
+	return "tcp", key
+}
 
 type PortEntity struct {
 	Protocol   string // 协议
@@ -82,20 +213,16 @@ type ProtocolResult struct {
 	Response *gonmap.Response
 }
 
-// GlobalIPDomainMap 存储ip->domains的关系
-var GlobalIPDomainMap map[string][]string
-var GlobalIPDomainMapLock sync.Mutex
-
 type UrlPathEntity struct {
 	// Path             string // 根目录为/
-	Hash             string // md5
-	IconHash         string //mmh3
-	Title            string
-	StatusCode       int
-	ContentType      string
-	Server           string
-	ContentLength    int
-	HeaderHashString string
+	Hash             string `json:"hash"`      // md5
+	IconHash         string `json:"icon_hash"` //mmh3
+	Title            string `json:"title"`
+	StatusCode       int    `json:"status_code"`
+	ContentType      string `json:"content_type"`
+	Server           string `json:"server"`
+	ContentLength    int    `json:"content_length"`
+	HeaderHashString string `json:"header_hash"`
 }
 
 type URLEntity struct {
@@ -105,17 +232,10 @@ type URLEntity struct {
 	Cert     string // TLS证书
 }
 
-// GlobalURLMap RootURL:URLEntity
-var GlobalURLMap map[string]URLEntity
-var GlobalURLMapLock sync.Mutex
-
-var GlobalHttpBodyHMap *hybrid.HybridMap
-var GlobalHttpHeaderHMap *hybrid.HybridMap
-
 type RuleData struct {
 	Start int
 	End   int
-	Op    int16  // 0= 1!= 2== 3>= 4<= 5~=
+	Op    int16  // 0= 1!= 2== 3>= 4<= 5~= 6> 7<
 	Key   string // body="123"中的body
 	Value string // body="123"中的123
 	All   string // body="123"
@@ -145,27 +265,22 @@ var FingerprintDB []FingerPEntity
 var WorkFlowDB map[string]WorkFlowEntity
 var DirDB map[string][]string
 
-// GlobalResultMap 存储识别到的指纹
-var GlobalResultMap map[string][]string
-
 type GoPocsResultType struct {
-	PocName     string
-	Security    string
-	Description string
-	Target      string
-	InfoLeft    string
-	InfoRight   string
+	PocName     string `json:"poc_name"`
+	Security    string `json:"security"`
+	Description string `json:"description"`
+	Target      string `json:"target"`
+	InfoLeft    string `json:"info_left"`
+	InfoRight   string `json:"info_right"`
 }
 
-// GoPocsResults 存储Go Poc的输出
-var GoPocsResults []GoPocsResultType
-
 type UserPasswd struct {
 	UserName string
 	Password string
 }
 
 type HostInfo struct {
+	Scan     *Scan // 所属的扫描，结果写入其中
 	Host     string
 	Ports    string
 	Url      string
@@ -173,7 +288,96 @@ type HostInfo struct {
 	UserPass []string
 }
 
-var AddScanNum int
-var AddScanEnd int
-
 var ShiroKeys []string
+
+// CheckpointState 断点续扫保存的资产状态
+type CheckpointState struct {
+	FinishedStages []string
+	ReportName     string
+
+	Domains    []string
+	DomainPort []string
+	URLs       []string
+	IPs        []string
+	IPRanges   []string // 未展开的网段与IP范围，主机发现后只保留存活主机
+	IPPort     []string
+	AliveURLs  []string
+	AliveHosts []string // 存活探测与端口扫描确认存活的主机
+
+	IPPortMap     map[string]string
+	ServiceMap    map[string]ServiceFingerprint
+	CertMap       map[string]CertInfo
+	IPDomainMap   map[string][]string
+	URLMap        map[string]URLEntity
+	ResultMap     map[string][]string
+	FlaggedHosts  map[string]string
+	NucleiResults []output.ResultEvent
+}
+
+// JSONL 输出记录
+
+type HostRecord struct {
+	IP    string  `json:"ip"`
+	Probe string  `json:"probe"`            // 首个成功的存活探测 icmp-echo/icmp-timestamp/ping/tcp-syn/tcp-ack/udp/arp
+	RTT   float64 `json:"rtt_ms,omitempty"` // 该探测测得的往返时延(毫秒)
+}
+
+type PortRecord struct {
+	IP        string `json:"ip"`
+	Port      int    `json:"port"`
+	Transport string `json:"transport,omitempty"` // 为空时为tcp
+}
+
+type ServiceRecord struct {
+	IP              string   `json:"ip"`
+	Port            int      `json:"port"`
+	Service         string   `json:"service"`
+	ProductName     string   `json:"product,omitempty"`
+	Version         string   `json:"version,omitempty"`
+	Info            string   `json:"info,omitempty"`
+	Hostname        string   `json:"hostname,omitempty"`
+	OperatingSystem string   `json:"os,omitempty"`
+	DeviceType      string   `json:"device_type,omitempty"`
+	CPE             []string `json:"cpe,omitempty"`
+	TLS             bool     `json:"tls"`
+	Transport       string   `json:"transport,omitempty"` // 为空时为tcp
+}
+
+// CertRecord 服务或Web的TLS证书，同一IP:Port只记录一次
+type CertRecord struct {
+	Target string `json:"target"` // 如 ldaps://1.1.1.1:636、https://1.1.1.1:443
+	IP     string `json:"ip,omitempty"`
+	Port   int    `json:"port"`
+	CertInfo
+}
+
+type WebRecord struct {
+	URL     string `json:"url"`
+	RootURL string `json:"root_url"`
+	Path    string `json:"path"`
+	Source  string `json:"source"` // 来源 web/active-finger/domain-bind
+	UrlPathEntity
+}
+
+type FingerRecord struct {
+	Target     string   `json:"target"`
+	Products   []string `json:"products"`
+	StatusCode int      `json:"status_code,omitempty"`
+	Title      string   `json:"title,omitempty"`
+}
+
+// HostFlagRecord 被判定为tarpit或蜜罐而跳过漏洞探测的主机，os-conflict只作提示，不跳过漏洞探测
+type HostFlagRecord struct {
+	Host   string `json:"host"`
+	Kind   string `json:"kind"` // tarpit/honeypot/os-conflict
+	Reason string `json:"reason"`
+}
+
+// DiffRecord 与项目上一次扫描相比的资产变化
+type DiffRecord struct {
+	Kind   string `json:"kind"`   // host/port/service/version/web/finger/vuln
+	Change string `json:"change"` // new/closed/changed/fixed
+	Target string `json:"target"`
+	Detail string `json:"detail,omitempty"`
+	Old    string `json:"old,omitempty"` // 变化前的值
+}
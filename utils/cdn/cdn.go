@@ -89,17 +89,10 @@ func CheckCDN(domain string) (bool, string, []net.IP) {
 		return true, "CNAME&&多IP解析", ips
 	}
 
-	// 不喜欢这一段可以注释掉，会默认过滤所有IPv6的域名资产
-	for _, ip := range ips {
-		if utils.IsIPv6(ip.String()) {
-			return true, "IPv6", ips
-		}
-	}
-
 	return false, "", ips
 }
 
-func CheckCDNs(domains []string, threads int) (rCDNDomains []string, normalDomains []string, rIPs []string) {
+func CheckCDNs(scan *structs.Scan, domains []string, threads int) (rCDNDomains []string, normalDomains []string, rIPs []string) {
 	domains = utils.RemoveDuplicateElement(domains)
 
 	Addrs := make(chan string, len(domains))
@@ -129,6 +122,11 @@ func CheckCDNs(domains []string, threads int) (rCDNDomains []string, normalDomai
 				normalDomainsLock.Lock()
 				normalDomains = append(normalDomains, result.Domain)
 				normalDomainsLock.Unlock()
+				if len(result.IPs) == 0 {
+					// 域名无法解析
+					wg.Done()
+					continue
+				}
 
 				for _, each := range result.IPs {
 					rIPsLock.Lock()
@@ -140,14 +138,14 @@ func CheckCDNs(domains []string, threads int) (rCDNDomains []string, normalDomai
 				for _, ip := range result.IPs {
 					show += ip.String() + ","
 
-					structs.GlobalIPDomainMapLock.Lock()
-					_, ok := structs.GlobalIPDomainMap[ip.String()]
-					structs.GlobalIPDomainMapLock.Unlock()
+					scan.IPDomainMapLock.Lock()
+					_, ok := scan.IPDomainMap[ip.String()]
+					scan.IPDomainMapLock.Unlock()
 					if ok {
 						// 存在于这个Map中
-						structs.GlobalIPDomainMapLock.Lock()
-						dms, _ := structs.GlobalIPDomainMap[ip.String()]
-						structs.GlobalIPDomainMapLock.Unlock()
+						scan.IPDomainMapLock.Lock()
+						dms, _ := scan.IPDomainMap[ip.String()]
+						scan.IPDomainMapLock.Unlock()
 						flag := false
 						for _, dm := range dms {
 							if dm == result.Domain {
@@ -156,15 +154,15 @@ func CheckCDNs(domains []string, threads int) (rCDNDomains []string, normalDomai
 							}
 						}
 						if !flag { // 没有这个域名
-							structs.GlobalIPDomainMapLock.Lock()
-							structs.GlobalIPDomainMap[ip.String()] = append(structs.GlobalIPDomainMap[ip.String()],
+							scan.IPDomainMapLock.Lock()
+							scan.IPDomainMap[ip.String()] = append(scan.IPDomainMap[ip.String()],
 								result.Domain)
-							structs.GlobalIPDomainMapLock.Unlock()
+							scan.IPDomainMapLock.Unlock()
 						}
 					} else {
-						structs.GlobalIPDomainMapLock.Lock()
-						structs.GlobalIPDomainMap[ip.String()] = []string{result.Domain}
-						structs.GlobalIPDomainMapLock.Unlock()
+						scan.IPDomainMapLock.Lock()
+						scan.IPDomainMap[ip.String()] = []string{result.Domain}
+						scan.IPDomainMapLock.Unlock()
 					}
 				}
 				show = show[:len(show)-1]
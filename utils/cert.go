@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"dddd/structs"
+	"encoding/hex"
+	"encoding/pem"
+	"github.com/projectdiscovery/tlsx/pkg/tlsx/clients"
+	"strconv"
+	"strings"
+)
+
+// NewCertInfo 提取证书中的主体、SAN、有效期、序列号、指纹与公钥类型
+// DN与httpx的格式相同，已有的cert=规则不受影响
+func NewCertInfo(cert *x509.Certificate, version uint16) structs.CertInfo {
+	sum := sha256.Sum256(cert.Raw)
+	info := structs.CertInfo{
+		SubjectCN:  cert.Subject.CommonName,
+		SubjectDN:  clients.ParseASN1DNSequenceWithZpkixOrDefault(cert.RawSubject, cert.Subject.String()),
+		SubjectOrg: cert.Subject.Organization,
+		DNSNames:   cert.DNSNames,
+		Emails:     cert.EmailAddresses,
+		IssuerCN:   cert.Issuer.CommonName,
+		IssuerDN:   clients.ParseASN1DNSequenceWithZpkixOrDefault(cert.RawIssuer, cert.Issuer.String()),
+		IssuerOrg:  cert.Issuer.Organization,
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		SHA256:     hex.EncodeToString(sum[:]),
+		KeyType:    certKeyType(cert),
+		SelfSigned: len(cert.AuthorityKeyId) == 0 || bytes.Equal(cert.AuthorityKeyId, cert.SubjectKeyId),
+	}
+	if cert.SerialNumber != nil {
+		info.Serial = strings.ToUpper(cert.SerialNumber.Text(16))
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	if version != 0 {
+		info.TLSVersion = tls.VersionName(version)
+	}
+	return info
+}
+
+// ParsePEMCertInfo 解析PEM格式的证书，失败时返回false
+func ParsePEMCertInfo(data string, version uint16) (structs.CertInfo, bool) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return structs.CertInfo{}, false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return structs.CertInfo{}, false
+	}
+	return NewCertInfo(cert, version), true
+}
+
+func certKeyType(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA-" + strconv.Itoa(key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + strings.ReplaceAll(key.Curve.Params().Name, "-", "")
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
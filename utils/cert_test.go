@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate 生成自签名证书，OU中带有Cisco设备证书常见的PID
+func testCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := pkix.Name{
+		CommonName:         "C220-WZP22330ABC",
+		Organization:       []string{"Cisco Systems Inc."},
+		OrganizationalUnit: []string{"PID:UCSC-C220-M5SN SN:WZP22330ABC"},
+		Country:            []string{"US"},
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x1a2b),
+		Subject:      name,
+		Issuer:       name,
+		DNSNames:     []string{"cimc.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2034, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestCertInfoString(t *testing.T) {
+	cert := testCertificate(t)
+	pemData := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	info, ok := ParsePEMCertInfo(pemData, tls.VersionTLS12)
+	if !ok {
+		t.Fatal("ParsePEMCertInfo 解析失败")
+	}
+	if info.KeyType != "ECDSA-P256" || info.Serial != "1A2B" || info.TLSVersion != "TLS 1.2" || !info.SelfSigned {
+		t.Errorf("证书信息 = %+v", info)
+	}
+
+	// 以前由httpx的证书字段拼接的cert=规则匹配文本，每一行都须原样出现在新的文本中
+	legacy := []string{
+		"SubjectCN: C220-WZP22330ABC\n",
+		"SubjectDN: CN=C220-WZP22330ABC, OU=PID:UCSC-C220-M5SN SN:WZP22330ABC, O=Cisco Systems Inc., C=US\n",
+		"IssuerCN: C220-WZP22330ABC\n",
+		"IssuerDN: CN=C220-WZP22330ABC, OU=PID:UCSC-C220-M5SN SN:WZP22330ABC, O=Cisco Systems Inc., C=US\n",
+		"IssuerOrg: \n    - Cisco Systems Inc.\n",
+	}
+	text := info.String()
+	for _, line := range legacy {
+		if !strings.Contains(text, line) {
+			t.Errorf("证书文本缺少 %q:\n%s", line, text)
+		}
+	}
+
+	// finger.yaml中已有的规则
+	for _, rule := range []string{"PID:UCSC-C220-M5SN", "cisco systems inc."} {
+		if !strings.Contains(strings.ToLower(text), strings.ToLower(rule)) {
+			t.Errorf("cert=%q 不再匹配:\n%s", rule, text)
+		}
+	}
+
+	for _, line := range []string{"SubjectAN: \n    - cimc.example.com\n    - 10.0.0.1\n", "NotAfter: 2034-01-01 00:00:00\n"} {
+		if !strings.Contains(text, line) {
+			t.Errorf("证书文本缺少 %q:\n%s", line, text)
+		}
+	}
+}
+
+func TestCertInfoStringEmpty(t *testing.T) {
+	if _, ok := ParsePEMCertInfo("not a certificate", 0); ok {
+		t.Errorf("无效的PEM应解析失败")
+	}
+	info, _ := ParsePEMCertInfo("", 0)
+	if info.String() != "" {
+		t.Errorf("没有证书时cert=规则匹配文本应为空")
+	}
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ExcludeScope 不允许触碰的目标范围，为nil时不排除任何目标
+type ExcludeScope struct {
+	ips       map[string]struct{}
+	nets      []*net.IPNet
+	ranges    [][2]net.IP
+	domains   map[string]struct{}
+	wildcards []string // 以.开头的域名后缀
+	hostPorts map[string]struct{}
+	networks  []string // 传给masscan的IP/CIDR/IP段
+}
+
+// NewExcludeScope 解析排除列表，支持IP、CIDR、IP段、域名、*.域名、host:port与URL，列表为空时返回nil
+func NewExcludeScope(entries []string) (*ExcludeScope, error) {
+	scope := &ExcludeScope{
+		ips:       make(map[string]struct{}),
+		domains:   make(map[string]struct{}),
+		hostPorts: make(map[string]struct{}),
+	}
+	count := 0
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		count++
+
+		if strings.Contains(entry, "://") {
+			host, port, ok := splitTarget(entry)
+			if !ok {
+				return nil, fmt.Errorf("无法解析的排除项: %s", entry)
+			}
+			entry = host
+			if port != "" {
+				entry = net.JoinHostPort(host, port)
+			}
+		}
+
+		if ip := net.ParseIP(entry); ip != nil {
+			scope.ips[ip.String()] = struct{}{}
+			scope.networks = append(scope.networks, ip.String())
+		} else if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			scope.nets = append(scope.nets, ipNet)
+			scope.networks = append(scope.networks, ipNet.String())
+		} else if block, err := parseIPBlock(entry); err == nil && strings.Contains(entry, "-") {
+			// 与-t相同，支持192.168.0.1-192.168.0.20与192.168.0.1-20
+			start := block.base
+			end := net.ParseIP(block.at(block.size - 1)).To4()
+			scope.ranges = append(scope.ranges, [2]net.IP{start, end})
+			scope.networks = append(scope.networks, start.String()+"-"+end.String())
+		} else if strings.HasPrefix(entry, "*.") && IsDomain(entry[2:]) {
+			scope.wildcards = append(scope.wildcards, entry[1:])
+		} else if IsDomain(entry) {
+			scope.domains[entry] = struct{}{}
+		} else if host, port, err := net.SplitHostPort(entry); err == nil &&
+			IsPort(port) && (net.ParseIP(host) != nil || IsDomain(host)) {
+			scope.hostPorts[net.JoinHostPort(normalizeHost(host), port)] = struct{}{}
+		} else {
+			return nil, fmt.Errorf("无法解析的排除项: %s", entry)
+		}
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	gologger.Info().Msgf("已加载排除项 %d 条", count)
+	return scope, nil
+}
+
+// normalizeHost 统一IP的书写形式与域名大小写
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+// splitTarget 从IP、域名、host:port或URL中提取主机与端口，URL未指定端口时按协议补全
+func splitTarget(target string) (host string, port string, ok bool) {
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil || u.Hostname() == "" {
+			return "", "", false
+		}
+		host, port = u.Hostname(), u.Port()
+		if port == "" {
+			switch strings.ToLower(u.Scheme) {
+			case "http":
+				port = "80"
+			case "https":
+				port = "443"
+			}
+		}
+		return normalizeHost(host), port, true
+	}
+	if h, p, err := net.SplitHostPort(target); err == nil {
+		return normalizeHost(h), p, true
+	}
+	return normalizeHost(target), "", true
+}
+
+func (s *ExcludeScope) matchIP(ip net.IP) bool {
+	if _, ok := s.ips[ip.String()]; ok {
+		return true
+	}
+	for _, ipNet := range s.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		for _, r := range s.ranges {
+			if bytes.Compare(ip4, r[0]) >= 0 && bytes.Compare(ip4, r[1]) <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *ExcludeScope) match(target string) bool {
+	host, port, ok := splitTarget(target)
+	if !ok {
+		return false
+	}
+	if port != "" {
+		if _, ok := s.hostPorts[net.JoinHostPort(host, port)]; ok {
+			return true
+		}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return s.matchIP(ip)
+	}
+	if _, ok := s.domains[host]; ok {
+		return true
+	}
+	for _, suffix := range s.wildcards {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExcluded 判断IP、域名、host:port或URL是否在排除列表中
+func (s *ExcludeScope) IsExcluded(target string) bool {
+	if s == nil {
+		return false
+	}
+	if s.match(target) {
+		gologger.Debug().Msgf("排除目标: %s", target)
+		return true
+	}
+	return false
+}
+
+// Networks 返回排除列表中的IP、CIDR与IP段，用于masscan的--exclude参数
+func (s *ExcludeScope) Networks() []string {
+	if s == nil {
+		return nil
+	}
+	return s.networks
+}
@@ -0,0 +1,86 @@
+package utils
+
+import "testing"
+
+func TestExcludeScopeIsExcluded(t *testing.T) {
+	scope, err := NewExcludeScope([]string{
+		"# 注释",
+		"10.0.0.1",
+		"192.168.1.0/24",
+		"172.16.0.10-172.16.0.20",
+		"10.1.1.5-9",
+		"Admin.Example.com",
+		"*.internal.example.com",
+		"8.8.8.8:53",
+		"https://pay.example.com/login",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"10.0.0.1", true},
+		{"10.0.0.2", false},
+		{"10.0.0.1:8080", true},
+		{"http://10.0.0.1/index", true},
+		{"192.168.1.200", true},
+		{"192.168.2.1", false},
+		{"172.16.0.15", true},
+		{"172.16.0.21", false},
+		{"10.1.1.5", true},
+		{"10.1.1.9:22", true},
+		{"10.1.1.10", false},
+		{"admin.example.com", true},
+		{"ADMIN.example.com:443", true},
+		{"www.example.com", false},
+		{"a.internal.example.com", true},
+		{"http://b.a.internal.example.com:8080/", true},
+		{"internal.example.com", false},
+		{"8.8.8.8:53", true},
+		{"8.8.8.8:80", false},
+		{"8.8.8.8", false},
+		{"pay.example.com:443", true},
+		{"https://pay.example.com/", true},
+		{"http://pay.example.com/", false},
+	}
+	for _, tt := range tests {
+		if got := scope.IsExcluded(tt.target); got != tt.want {
+			t.Errorf("IsExcluded(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+
+	want := []string{"10.0.0.1", "192.168.1.0/24", "172.16.0.10-172.16.0.20", "10.1.1.5-10.1.1.9"}
+	networks := scope.Networks()
+	if len(networks) != len(want) {
+		t.Fatalf("Networks() = %v, want %v", networks, want)
+	}
+	for i := range want {
+		if networks[i] != want[i] {
+			t.Errorf("Networks()[%d] = %q, want %q", i, networks[i], want[i])
+		}
+	}
+}
+
+func TestExcludeScopeEmpty(t *testing.T) {
+	scope, err := NewExcludeScope([]string{"", "  ", "# 注释"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scope != nil {
+		t.Fatalf("NewExcludeScope 空列表应返回nil")
+	}
+	if scope.IsExcluded("10.0.0.1") {
+		t.Errorf("nil ExcludeScope 不应排除任何目标")
+	}
+}
+
+func TestExcludeScopeInvalid(t *testing.T) {
+	for _, entry := range []string{"not a target", "10.0.0.1:99999", "http://", "10.0.0.20-5"} {
+		if _, err := NewExcludeScope([]string{entry}); err == nil {
+			t.Errorf("NewExcludeScope(%q) 应返回错误", entry)
+		}
+	}
+}
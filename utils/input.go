@@ -2,6 +2,7 @@ package utils
 
 import (
 	"net"
+	"net/url"
 	"os"
 	"regexp"
 	"strconv"
@@ -36,18 +37,16 @@ func IsCIDR(str string) bool {
 	return err == nil
 }
 
+// IsIPPort checks if a string is IPv4:Port or [IPv6]:Port
 func IsIPPort(str string) bool {
-	if !strings.Contains(str, ":") {
+	host, port, err := net.SplitHostPort(str)
+	if err != nil {
 		return false
 	}
-	t := strings.Split(str, ":")
-	if len(t) != 2 {
+	if !IsIPv4(host) && !(IsIPv6(host) && strings.HasPrefix(str, "[")) {
 		return false
 	}
-	if !IsIPv4(t[0]) {
-		return false
-	}
-	if !IsPort(t[1]) {
+	if !IsPort(port) {
 		return false
 	}
 	return true
@@ -108,18 +107,23 @@ func IsProtocol(str string) bool {
 	return ok
 }
 
+// IsNetloc checks if a string is Domain, IPv4 or [IPv6]
 func IsNetloc(str string) bool {
+	if strings.HasPrefix(str, "[") && strings.HasSuffix(str, "]") {
+		return IsIPv6(str[1 : len(str)-1])
+	}
 	return IsDomain(str) || IsIPv4(str)
 }
 
 // IsNetlocPort checks if a string is [Domain or IP]:Port
 func IsNetlocPort(str string) bool {
-	r := strings.Split(str, ":")
-	if len(r) != 2 {
+	netloc, port, err := net.SplitHostPort(str)
+	if err != nil {
 		return false
 	}
-	netloc := r[0]
-	port := r[1]
+	if IsIPv6(netloc) && strings.HasPrefix(str, "[") {
+		return IsPort(port)
+	}
 	return IsNetloc(netloc) && IsPort(port)
 }
 
@@ -132,12 +136,7 @@ func IsHostPath(str string) bool {
 		return false
 	}
 	str = str[:index]
-	if strings.Contains(str, ":") == true {
-		return IsNetlocPort(str)
-	} else {
-		return IsNetloc(str)
-
-	}
+	return IsNetlocPort(str) || IsNetloc(str)
 }
 
 // IsURL checks if a string is :
@@ -202,7 +201,7 @@ func IsFileNameValid(name string) bool {
 
 func GetInputType(input string) int {
 	if IsIPv6(input) {
-		return structs.TypeUnSupport
+		return structs.TypeIP
 	} else if IsIPv4(input) {
 		return structs.TypeIP
 	} else if IsIPRange(input) {
@@ -231,13 +230,23 @@ func GetItemInArray(a []string, s string) int {
 	return -1
 }
 
-func DeleteReportWithNoResult() {
-	fileInfo, err := os.Stat(structs.GlobalConfig.ReportName)
+func DeleteReportWithNoResult(reportName string) {
+	fileInfo, err := os.Stat(reportName)
 	if err == nil {
 		fileSize := fileInfo.Size()
 		// 简单粗暴判断文件大小
 		if fileSize < 99360 {
-			_ = os.Remove(structs.GlobalConfig.ReportName)
+			_ = os.Remove(reportName)
 		}
 	}
 }
+
+// TargetHost 返回指纹结果目标(URL、协议://Host:Port 或 udp/协议://Host:Port)中的主机
+func TargetHost(target string) string {
+	target = strings.TrimPrefix(target, structs.UDPServicePrefix)
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
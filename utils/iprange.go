@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+)
+
+// ipBlock 连续的地址段，地址为base加上偏移
+type ipBlock struct {
+	base net.IP // IPv4为4字节，IPv6为16字节
+	size uint64
+}
+
+// at 返回段内第offset个地址
+func (b ipBlock) at(offset uint64) string {
+	ip := make(net.IP, len(b.base))
+	copy(ip, b.base)
+	if len(ip) == net.IPv4len {
+		binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(ip)+uint32(offset))
+		return ip.String()
+	}
+	low := binary.BigEndian.Uint64(ip[8:])
+	sum := low + offset
+	binary.BigEndian.PutUint64(ip[8:], sum)
+	if sum < low {
+		binary.BigEndian.PutUint64(ip[:8], binary.BigEndian.Uint64(ip[:8])+1)
+	}
+	return ip.String()
+}
+
+// IPIterator 按需生成IP、网段与IP范围中的地址，不展开整个网段，内存占用与地址数量无关
+type IPIterator struct {
+	blocks []ipBlock
+	ends   []uint64 // 每段结束时的累计地址数
+	total  uint64
+
+	// 打乱顺序时使用线性同余生成[0,mask]的排列，跳过不小于total的值
+	shuffle bool
+	mask    uint64
+	a, c    uint64
+	x       uint64
+	count   uint64
+}
+
+// NewIPIterator 解析IP、CIDR与IP范围(192.168.0.1-192.168.2.255、192.168.0.1-255)，shuffle为true时以随机顺序遍历全部地址
+func NewIPIterator(inputs []string, shuffle bool) (*IPIterator, error) {
+	it := &IPIterator{shuffle: shuffle}
+	for _, input := range inputs {
+		block, err := parseIPBlock(input)
+		if err != nil {
+			return nil, err
+		}
+		it.blocks = append(it.blocks, block)
+		it.total += block.size
+		it.ends = append(it.ends, it.total)
+	}
+	it.Reset()
+	return it, nil
+}
+
+func parseIPBlock(input string) (ipBlock, error) {
+	if ip := net.ParseIP(input); ip != nil {
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		return ipBlock{base: ip, size: 1}, nil
+	}
+
+	if _, network, err := net.ParseCIDR(input); err == nil {
+		ones, bits := network.Mask.Size()
+		if bits == 8*net.IPv6len && bits-ones > MaxIPv6CIDRBits {
+			return ipBlock{}, fmt.Errorf("IPv6网段 %s 过大，最大支持/%d", network.String(), bits-MaxIPv6CIDRBits)
+		}
+		base := network.IP
+		if v4 := base.To4(); v4 != nil {
+			base = v4
+		}
+		return ipBlock{base: base, size: 1 << uint(bits-ones)}, nil
+	}
+
+	first, last := parseIPPairs(input)
+	if first == nil || last == nil || first.To4() == nil || last.To4() == nil {
+		return ipBlock{}, fmt.Errorf("无法解析的IP范围: %s", input)
+	}
+	if toInt(first) > toInt(last) {
+		return ipBlock{}, fmt.Errorf("IP范围的起始地址大于结束地址: %s", input)
+	}
+	return ipBlock{base: first.To4(), size: uint64(toInt(last)-toInt(first)) + 1}, nil
+}
+
+// Len 地址总数，重复的地址按次数计算
+func (it *IPIterator) Len() uint64 {
+	return it.total
+}
+
+// Reset 从头开始遍历，打乱顺序时重新生成随机顺序
+func (it *IPIterator) Reset() {
+	it.x, it.count = 0, 0
+	if !it.shuffle || it.total == 0 {
+		return
+	}
+	it.mask = 1
+	for it.mask < it.total {
+		it.mask <<= 1
+	}
+	// 模为2的幂时，a%4==1且c为奇数的线性同余周期为模本身
+	it.a = (rand.Uint64()<<2 | 1) & (it.mask - 1)
+	if it.mask < 4 {
+		it.a = 1
+	}
+	it.c = rand.Uint64() | 1
+	it.mask--
+	it.x = rand.Uint64() & it.mask
+}
+
+// Next 返回下一个地址，遍历结束时返回false
+func (it *IPIterator) Next() (string, bool) {
+	if it.count >= it.total {
+		return "", false
+	}
+	index := it.count
+	if it.shuffle {
+		for {
+			it.x = (it.a*it.x + it.c) & it.mask
+			if it.x < it.total {
+				break
+			}
+		}
+		index = it.x
+	}
+	it.count++
+
+	i := sort.Search(len(it.ends), func(i int) bool {
+		return it.ends[i] > index
+	})
+	start := it.ends[i] - it.blocks[i].size
+	return it.blocks[i].at(index - start), true
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// collect 遍历迭代器中的全部地址
+func collect(it *IPIterator) []string {
+	var ips []string
+	for ip, ok := it.Next(); ok; ip, ok = it.Next() {
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+func TestIPIterator(t *testing.T) {
+	it, err := NewIPIterator([]string{
+		"10.0.0.1",
+		"192.168.1.254/31",
+		"172.16.0.254-172.16.1.1",
+		"172.16.2.1-3",
+		"2001:db8::fffe/127",
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"10.0.0.1",
+		"192.168.1.254", "192.168.1.255",
+		"172.16.0.254", "172.16.0.255", "172.16.1.0", "172.16.1.1",
+		"172.16.2.1", "172.16.2.2", "172.16.2.3",
+		"2001:db8::fffe", "2001:db8::ffff",
+	}
+	if it.Len() != uint64(len(want)) {
+		t.Errorf("Len() = %d, want %d", it.Len(), len(want))
+	}
+	if got := collect(it); !reflect.DeepEqual(got, want) {
+		t.Errorf("遍历结果 = %v, want %v", got, want)
+	}
+	if _, ok := it.Next(); ok {
+		t.Errorf("遍历结束后Next应返回false")
+	}
+
+	it.Reset()
+	if got := collect(it); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reset后遍历结果 = %v, want %v", got, want)
+	}
+}
+
+func TestIPIteratorIPv6Carry(t *testing.T) {
+	it, err := NewIPIterator([]string{"2001:db8::ffff:ffff:ffff:fffe/127"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it.blocks[0].size = 3
+	it.ends[0], it.total = 3, 3
+	want := []string{"2001:db8::ffff:ffff:ffff:fffe", "2001:db8::ffff:ffff:ffff:ffff", "2001:db8:0:1::"}
+	if got := collect(it); !reflect.DeepEqual(got, want) {
+		t.Errorf("遍历结果 = %v, want %v", got, want)
+	}
+}
+
+func TestIPIteratorShuffle(t *testing.T) {
+	for _, inputs := range [][]string{
+		{"10.0.0.1"},
+		{"10.0.0.0/30"},
+		{"10.0.0.0/24", "10.0.1.1-10.0.1.37", "10.0.2.5"},
+	} {
+		ordered, err := NewIPIterator(inputs, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := collect(ordered)
+		sort.Strings(want)
+
+		it, err := NewIPIterator(inputs, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for round := 0; round < 3; round++ {
+			got := collect(it)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%v 打乱后的地址集合与原地址不同: %d 个, want %d 个", inputs, len(got), len(want))
+			}
+			it.Reset()
+		}
+	}
+}
+
+func TestIPIteratorInvalid(t *testing.T) {
+	for _, input := range []string{
+		"example.com",
+		"10.0.0.1-",
+		"10.0.0.10-5",
+		"10.0.1.0-10.0.0.255",
+		"2001:db8::/64",
+	} {
+		if _, err := NewIPIterator([]string{input}, false); err == nil {
+			t.Errorf("NewIPIterator(%q) 应返回错误", input)
+		}
+	}
+
+	it, err := NewIPIterator(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := it.Next(); ok || it.Len() != 0 {
+		t.Errorf("空输入不应返回地址")
+	}
+}
@@ -3,23 +3,10 @@ package utils
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
-	"math"
 	"net"
 	"strings"
 )
 
-func FirstIP(network *net.IPNet) net.IP {
-	return network.IP
-}
-
-func toIP(i uint32) string {
-	buf := bytes.NewBuffer([]byte{})
-	_ = binary.Write(buf, binary.BigEndian, i)
-	b := buf.Bytes()
-	return fmt.Sprintf("%v.%v.%v.%v", b[0], b[1], b[2], b[3])
-}
-
 // IPToInteger converts an IP address to its integer representation.
 // It supports both IPv4
 func toInt(ip net.IP) uint32 {
@@ -33,29 +20,8 @@ func toInt(ip net.IP) uint32 {
 	return i
 }
 
-func pairsToIP(ip1, ip2 net.IP) (IPs []net.IP) {
-	start := toInt(ip1)
-	end := toInt(ip2)
-	for i := start; i <= end; i++ {
-		IPs = append(IPs, net.ParseIP(toIP(i)))
-	}
-	return IPs
-}
-
-func LastIP(network *net.IPNet) net.IP {
-	firstIP := FirstIP(network)
-	mask, _ := network.Mask.Size()
-	size := math.Pow(2, float64(32-mask))
-	lastIP := toIP(toInt(firstIP) + uint32(size) - 1)
-	return net.ParseIP(lastIP)
-}
-
-func CIDRToIP(cidr string) (IPs []net.IP) {
-	_, network, _ := net.ParseCIDR(cidr)
-	first := FirstIP(network)
-	last := LastIP(network)
-	return pairsToIP(first, last)
-}
+// MaxIPv6CIDRBits IPv6网段最多展开2^16个地址，即前缀不小于/112
+const MaxIPv6CIDRBits = 16
 
 // IsIPRanger parse the string is an ip pairs
 // 192.168.0.1-192.168.2.255
@@ -87,11 +53,6 @@ func parseIPPairs(str string) (ip1 net.IP, ip2 net.IP) {
 	return ip1, ip2
 }
 
-func RangerToIP(ranger string) (IPs []net.IP) {
-	first, last := parseIPPairs(ranger)
-	return pairsToIP(first, last)
-}
-
 func IsLocalIP(input string) bool {
 	ip := net.ParseIP(input)
 	return ip.IsPrivate() || ip.IsLoopback()